@@ -14,6 +14,22 @@ type NewsItem struct {
 	Channel     string `json:"channel"`
 }
 
+// VerificationCodeRequest matches the request sent by julia-profile-api
+type VerificationCodeRequest struct {
+	Channel     string `json:"channel"`
+	Destination string `json:"destination"`
+	Code        string `json:"code"`
+}
+
+// InstallationRequest matches the installation registration sent by julia-profile-api
+type InstallationRequest struct {
+	InstallationID string   `json:"installationId"`
+	UserID         string   `json:"userId"`
+	Platform       string   `json:"platform"`
+	PushChannel    string   `json:"pushChannel"`
+	Tags           []string `json:"tags"`
+}
+
 func main() {
 	http.HandleFunc("/api/v1/news", func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Received %s request for %s", r.Method, r.URL.Path)
@@ -44,6 +60,45 @@ func main() {
 		fmt.Fprintf(w, "News updated successfully")
 	})
 
+	http.HandleFunc("/api/v1/verification-codes", func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Received %s request for %s", r.Method, r.URL.Path)
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req VerificationCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("Error decoding body: %v", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Verification code %s for %s via %s", req.Code, req.Destination, req.Channel)
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	http.HandleFunc("/api/v1/installations/", func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Received %s request for %s", r.Method, r.URL.Path)
+
+		switch r.Method {
+		case http.MethodPut:
+			var req InstallationRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				log.Printf("Error decoding body: %v", err)
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+			log.Printf("Installation %s of user %s on %s with tags %v", req.InstallationID, req.UserID, req.Platform, req.Tags)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
 	port := ":8095"
 	fmt.Printf("Mock Internal Notification Server starting on port %s...\n", port)
 	log.Fatal(http.ListenAndServe(port, nil))
@@ -15,7 +15,11 @@ import (
 	"github.com/comune-roma/bff-julia-mobile-api/internal/repository"
 	"github.com/comune-roma/bff-julia-mobile-api/internal/service"
 	"github.com/comune-roma/bff-julia-mobile-api/pkg/azure"
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/health"
 	"github.com/comune-roma/bff-julia-mobile-api/pkg/logger"
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/problem"
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/ratelimit"
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/telemetry"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
@@ -33,11 +37,33 @@ func main() {
 	defer log.Sync()
 
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfig(context.Background())
 	if err != nil {
 		log.Fatal("Failed to load configuration", zap.Error(err))
 	}
 
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration", zap.Error(err))
+	}
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatal("Invalid log level", zap.Error(err))
+	}
+	log.Info("Configuration loaded", zap.String("file", cfg.File()), zap.Strings("values", cfg.Dump()))
+
+	// Initialize tracing
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Warn("Failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	// Initialize Azure clients
 	cosmosClient, err := azure.NewCosmosClient(cfg)
 	if err != nil {
@@ -52,11 +78,62 @@ func main() {
 	// Initialize repository
 	repo := repository.NewCosmosRepository(cosmosClient, cfg.CosmosDB.Database)
 
+	// Initialize rate limiting
+	rateLimitStore, err := ratelimit.New(cfg.RateLimit, cfg.Redis)
+	if err != nil {
+		log.Fatal("Failed to initialize rate limit store", zap.Error(err))
+	}
+	defer rateLimitStore.Close()
+	rateLimiter, err := middleware.NewRateLimiter(rateLimitStore, cfg.RateLimit, log)
+	if err != nil {
+		log.Fatal("Failed to initialize rate limiter", zap.Error(err))
+	}
+
 	// Initialize service
 	appConfigService := service.NewAppConfigService(appConfigClient, repo, cfg, log)
 
+	// Initialize health checks
+	healthRegistry := health.NewRegistry("bff-julia-mobile-api",
+		time.Duration(cfg.Health.Interval)*time.Second,
+		time.Duration(cfg.Health.Timeout)*time.Second,
+		log,
+	)
+	healthRegistry.Register("cosmosdb", true, repo.Ping)
+	if cfg.AppConfig.Endpoint != "" {
+		// Defaults from configuration are served when App Configuration is unavailable
+		healthRegistry.Register("appconfig", false, health.HTTPCheck(&http.Client{}, cfg.AppConfig.Endpoint))
+	}
+	if store, ok := rateLimitStore.(*ratelimit.RedisStore); ok && cfg.RateLimit.Enabled {
+		// Requests are let through when the rate limit store is unavailable
+		healthRegistry.Register("ratelimit-store", false, store.Ping)
+	}
+	healthRegistry.Start()
+	defer healthRegistry.Stop()
+
 	// Initialize handler
 	appConfigHandler := handler.NewAppConfigHandler(appConfigService, log)
+	healthHandler := handler.NewHealthHandler(healthRegistry)
+
+	// Apply configuration file edits to the settings that are safe to change at runtime
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	err = cfg.Watch(watchCtx, func(next *config.Config, changed []string, err error) {
+		if err != nil {
+			log.Warn("Configuration reload rejected", zap.Error(err))
+			return
+		}
+		if len(changed) == 0 {
+			return
+		}
+		if err := logger.SetLevel(next.LogLevel); err != nil {
+			log.Warn("Failed to apply log level", zap.Error(err))
+		}
+		rateLimiter.SetGroups(next.RateLimit.Groups)
+		log.Info("Configuration reloaded", zap.Strings("keys", changed))
+	})
+	if err != nil {
+		log.Warn("Configuration file changes will not be applied", zap.Error(err))
+	}
 
 	// Setup Gin router
 	if cfg.Environment == "production" {
@@ -64,20 +141,23 @@ func main() {
 	}
 
 	router := gin.New()
+	// The client IP keys rate limits and exemptions: only trust X-Forwarded-For from known proxies
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 
 	// Middlewares
+	router.Use(telemetry.Middleware())
+	router.Use(middleware.RequestID())
 	router.Use(middleware.Logger(log))
+	router.Use(middleware.Metrics())
 	router.Use(middleware.Recovery(log))
-	router.Use(middleware.CORS())
-	router.Use(middleware.RequestID())
+	router.Use(middleware.CORS(cfg.CORS))
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "UP",
-			"service": "bff-julia-mobile-api",
-		})
-	})
+	// Health check endpoints
+	router.GET("/health/live", healthHandler.Live)
+	router.GET("/health/ready", healthHandler.Ready)
+	router.GET("/health", healthHandler.Ready)
 
 	// Metrics endpoint
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
@@ -85,12 +165,15 @@ func main() {
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
-		v1.GET("/app-config", appConfigHandler.GetAppConfig)
+		v1.GET("/app-config", rateLimiter.Limit(config.RateLimitGroupAppConfig), appConfigHandler.GetAppConfig)
 	}
 
 	// Swagger documentation
 	// router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Unknown routes get a problem response as well
+	router.NoRoute(problem.NoRoute)
+
 	// Start server with graceful shutdown
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", cfg.Server.Port),
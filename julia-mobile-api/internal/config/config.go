@@ -1,19 +1,33 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"sort"
+	"strings"
+
+	"github.com/comune-roma/bff-julia-shared/configloader"
 )
 
-// Config holds all application configuration
+// Config holds all application configuration.
+// Values come from the defaults below, then application.yaml (or CONFIG_FILE), then the environment variables named
+// by the env tags; see pkg/configloader for the tag semantics.
 type Config struct {
 	Server      ServerConfig
 	CosmosDB    CosmosDBConfig
 	AppConfig   AppConfigConfig
-	Environment string
-	LogLevel    string
-	Defaults    DefaultConfig
+	Environment string `env:"ENVIRONMENT"`
+	LogLevel    string `env:"LOG_LEVEL" reload:"true"`
+	Redis       RedisConfig
+	RateLimit   RateLimitConfig
+	CORS        CORSConfig
+	Health      HealthConfig
+	Telemetry   TelemetryConfig
+	Defaults    DefaultConfig `mapstructure:"-"`
+
+	loader *configloader.Loader
 }
 
 // DefaultConfig holds default values for app configuration
@@ -23,47 +37,177 @@ type DefaultConfig struct {
 	Locale   map[string]string
 }
 
+// RedisConfig holds Redis configuration
+type RedisConfig struct {
+	Addr     string `env:"REDIS_ADDR"`
+	Password string `env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `env:"REDIS_DB"`
+	Enabled  bool   `env:"REDIS_ENABLED"`
+}
+
+// CORSConfig holds the cross-origin policy applied to browser clients
+type CORSConfig struct {
+	AllowedOrigins   []string `env:"CORS_ALLOWED_ORIGINS"` // exact origins, "https://*.example.org" wildcards or "*"
+	AllowedMethods   []string `env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string `env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string `env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool     `env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           int      `env:"CORS_MAX_AGE"` // in seconds, how long browsers may cache a preflight response
+}
+
+// Rate limit stores
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// Rate limited route groups
+const (
+	RateLimitGroupAppConfig = "appconfig"
+)
+
+// RateLimitConfig holds the per-client rate limiting settings
+type RateLimitConfig struct {
+	Enabled     bool     `env:"RATE_LIMIT_ENABLED"`
+	Store       string   `env:"RATE_LIMIT_STORE"`      // one of the RateLimitStore* values
+	KeyPrefix   string   `env:"RATE_LIMIT_KEY_PREFIX"` // Redis key prefix
+	MaxKeys     int      `env:"RATE_LIMIT_MAX_KEYS"`   // buckets kept by the memory store
+	ExemptCIDRs []string `env:"RATE_LIMIT_EXEMPT_CIDRS"`
+	// Read from RATE_LIMIT_<GROUP>_RATE and RATE_LIMIT_<GROUP>_BURST
+	Groups map[string]RateLimitGroup `env:"RATE_LIMIT" reload:"true"`
+}
+
+// RateLimitGroup is the token bucket applied to each client of a route group
+type RateLimitGroup struct {
+	Rate  float64 `env:"RATE"` // tokens per second
+	Burst int     `env:"BURST"`
+}
+
+// HealthConfig holds the background health check settings
+type HealthConfig struct {
+	Interval int `env:"HEALTH_CHECK_INTERVAL"` // in seconds
+	Timeout  int `env:"HEALTH_CHECK_TIMEOUT"`  // in seconds, per check
+}
+
+// Trace exporters
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterFile   = "file"
+	TraceExporterOTLP   = "otlp"
+)
+
+// TelemetryConfig holds the OpenTelemetry tracing settings
+type TelemetryConfig struct {
+	Exporter    string  `env:"OTEL_TRACES_EXPORTER"`               // one of the TraceExporter* values
+	Endpoint    string  `env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"` // OTLP/HTTP traces URL, e.g. http://otel-collector:4318/v1/traces
+	FilePath    string  `env:"OTEL_TRACES_FILE"`                   // destination of the "file" exporter
+	ServiceName string  `env:"OTEL_SERVICE_NAME"`
+	Environment string  `env:"ENVIRONMENT"` // defaults to the service environment
+	SampleRatio float64 `env:"OTEL_TRACES_SAMPLER_ARG"`
+}
+
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	Port string
+	Port           string   `env:"SERVER_PORT"`
+	TrustedProxies []string `env:"TRUSTED_PROXIES"` // proxies whose X-Forwarded-For is trusted to report the client IP
 }
 
 // CosmosDBConfig holds Cosmos DB configuration
 type CosmosDBConfig struct {
-	Endpoint  string
-	Key       string
-	Database  string
-	Container string
-	Emulator  bool
+	Endpoint  string `env:"COSMOS_DB_ENDPOINT"`
+	Key       string `env:"COSMOS_DB_KEY" secret:"true"`
+	Database  string `env:"COSMOS_DB_DATABASE"`
+	Container string `env:"COSMOS_DB_CONTAINER"`
+	Emulator  bool   `env:"COSMOS_EMULATOR_ENABLED"`
 }
 
 // AppConfigConfig holds Azure App Configuration settings
 type AppConfigConfig struct {
-	Endpoint      string
-	ConnectionStr string
-	LabelFilter   string
+	Endpoint      string `env:"AZURE_APPCONFIG_ENDPOINT"`
+	ConnectionStr string `env:"AZURE_APPCONFIG_CONNECTION_STRING" secret:"true"`
+	LabelFilter   string `env:"AZURE_APPCONFIG_LABEL"`
+}
+
+// LoadConfig loads configuration from the defaults, the configuration file, secret providers and environment variables
+func LoadConfig(ctx context.Context) (*Config, error) {
+	opts, err := loaderOptions()
+	if err != nil {
+		return nil, err
+	}
+	loader := configloader.New(opts)
+	cfg := defaultConfig()
+	if err := loader.Load(ctx, cfg); err != nil {
+		return nil, err
+	}
+	cfg.resolve(loader)
+	cfg.loader = loader
+	return cfg, nil
+}
+
+// loaderOptions reads CONFIG_FILE when set, otherwise an optional application.yaml in the working directory.
+// Secrets may also come from the providers selected by the SECRETS_* variables.
+func loaderOptions() (configloader.Options, error) {
+	providers, refresh, err := configloader.SecretProvidersFromEnv()
+	if err != nil {
+		return configloader.Options{}, err
+	}
+	return configloader.Options{
+		File:            os.Getenv("CONFIG_FILE"),
+		Name:            "application",
+		Paths:           []string{"."},
+		Providers:       providers,
+		RefreshInterval: refresh,
+	}, nil
 }
 
-// LoadConfig loads configuration from environment variables
-func LoadConfig() (*Config, error) {
-	cfg := &Config{
+// defaultConfig returns the configuration used when no source overrides a value.
+// Local emulator endpoints and keys live in application.yaml, not here.
+func defaultConfig() *Config {
+	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
+			Port: "8080",
 		},
 		CosmosDB: CosmosDBConfig{
-			Endpoint:  getEnv("COSMOS_DB_ENDPOINT", "https://localhost:8182"),
-			Key:       getEnv("COSMOS_DB_KEY", "C2y6yDjf5/R+ob0N8A7Cgv30VRDJIWEHLM+4QDU5DE2nQ9nDuVTqobD4b8mGGyPMbIZnqyMsEcaGQy67XIw/Jw=="),
-			Database:  getEnv("COSMOS_DB_DATABASE", "bff_julia_db"),
-			Container: getEnv("COSMOS_DB_CONTAINER", "app_config"),
-			Emulator:  getEnvBool("COSMOS_EMULATOR_ENABLED", true),
+			Database:  "bff_julia_db",
+			Container: "app_config",
 		},
 		AppConfig: AppConfigConfig{
-			Endpoint:      getEnv("AZURE_APPCONFIG_ENDPOINT", "http://localhost:8484"),
-			ConnectionStr: getEnv("AZURE_APPCONFIG_CONNECTION_STRING", "Endpoint=http://localhost:8484;Id=local;Secret=c2VjcmV0"),
-			LabelFilter:   getEnv("AZURE_APPCONFIG_LABEL", "local"),
+			LabelFilter: "local",
+		},
+		Environment: "development",
+		LogLevel:    "info",
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		CORS: CORSConfig{
+			AllowedMethods:   []string{"GET", "OPTIONS"},
+			AllowedHeaders:   []string{"Content-Type", "Authorization", "Accept-Language", "X-App-Platform", "X-App-Version", "X-Request-ID", "X-Correlation-ID", "X-Installation-ID"},
+			ExposedHeaders:   []string{"X-Request-ID", "X-Correlation-ID", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining"},
+			AllowCredentials: false,
+			MaxAge:           600,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:   true,
+			Store:     RateLimitStoreMemory,
+			KeyPrefix: "ratelimit:mobile-api:",
+			MaxKeys:   100000,
+			Groups: map[string]RateLimitGroup{
+				// app-config is called at every app start; carrier NAT can put many devices behind one IP
+				RateLimitGroupAppConfig: {Rate: 5, Burst: 30},
+			},
+		},
+		Health: HealthConfig{
+			Interval: 15,
+			Timeout:  3,
+		},
+		Telemetry: TelemetryConfig{
+			Exporter:    TraceExporterNone,
+			FilePath:    "traces.jsonl",
+			ServiceName: "bff-julia-mobile-api",
+			Environment: "development",
+			SampleRatio: 1.0,
 		},
-		Environment: getEnv("ENVIRONMENT", "development"),
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
 		Defaults: DefaultConfig{
 			Features: map[string]bool{
 				"newUI":         true,
@@ -76,40 +220,113 @@ func LoadConfig() (*Config, error) {
 			},
 		},
 	}
+}
+
+// resolve fills the defaults that depend on other values once every source has been read
+func (c *Config) resolve(loader *configloader.Loader) {
+	// Any origin is allowed outside production; production origins must be listed explicitly
+	if loader.Source("CORS.AllowedOrigins") == configloader.SourceDefault && c.Environment != "production" {
+		c.CORS.AllowedOrigins = []string{"*"}
+	}
+	if loader.Source("Telemetry.Environment") == configloader.SourceDefault {
+		c.Telemetry.Environment = c.Environment
+	}
+}
 
-	return cfg, nil
+// File returns the configuration file in use, empty when the configuration comes from the environment only
+func (c *Config) File() string {
+	if c.loader == nil {
+		return ""
+	}
+	return c.loader.File()
 }
 
-// getEnv gets an environment variable with a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Dump returns the effective configuration, one "key=value (source)" line per value, with secrets redacted
+func (c *Config) Dump() []string {
+	if c.loader == nil {
+		return configloader.New(configloader.Options{}).Dump(c)
 	}
-	return defaultValue
+	return c.loader.Dump(c)
 }
 
-// getEnvBool gets a boolean environment variable with a default value
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		boolVal, err := strconv.ParseBool(value)
+// Watch reloads the configuration when the file changes and at every SECRETS_REFRESH_INTERVAL, until ctx is done.
+// onReload receives the new configuration and the keys that changed, or the reason the change was rejected:
+// only keys tagged reload:"true" may change while the service runs, the others need a restart.
+func (c *Config) Watch(ctx context.Context, onReload func(next *Config, changed []string, err error)) error {
+	current := c
+	return c.loader.Watch(ctx, func() {
+		next := defaultConfig()
+		changed, err := c.loader.Reload(ctx, current, next, func(loader *configloader.Loader) error {
+			next.resolve(loader)
+			return next.Validate()
+		})
 		if err != nil {
-			return defaultValue
+			onReload(nil, nil, err)
+			return
 		}
-		return boolVal
-	}
-	return defaultValue
+		next.loader = c.loader
+		current = next
+		onReload(next, changed, nil)
+	})
 }
 
-// Validate validates the configuration
+// Validate validates the configuration and reports every problem found, not only the first one
 func (c *Config) Validate() error {
+	var errs []error
 	if c.Server.Port == "" {
-		return fmt.Errorf("server port is required")
+		errs = append(errs, fmt.Errorf("SERVER_PORT is required"))
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be one of debug, info, warn, error"))
 	}
 	if c.CosmosDB.Endpoint == "" {
-		return fmt.Errorf("cosmos DB endpoint is required")
+		errs = append(errs, fmt.Errorf("COSMOS_DB_ENDPOINT is required"))
+	}
+	if c.CosmosDB.Key == "" {
+		errs = append(errs, fmt.Errorf("COSMOS_DB_KEY is required"))
 	}
 	if c.CosmosDB.Database == "" {
-		return fmt.Errorf("cosmos DB database is required")
+		errs = append(errs, fmt.Errorf("COSMOS_DB_DATABASE is required"))
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" && c.CORS.AllowCredentials {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS must list explicit origins when CORS_ALLOW_CREDENTIALS is true"))
+		}
+		if origin == "*" && c.Environment == "production" {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS must not be * in production"))
+		}
+	}
+	switch c.RateLimit.Store {
+	case RateLimitStoreMemory:
+	case RateLimitStoreRedis:
+		if c.RateLimit.Enabled && !c.Redis.Enabled {
+			errs = append(errs, fmt.Errorf("REDIS_ENABLED must be true when RATE_LIMIT_STORE is redis"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE must be one of memory, redis"))
+	}
+	groups := make([]string, 0, len(c.RateLimit.Groups))
+	for name := range c.RateLimit.Groups {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	for _, name := range groups {
+		if group := c.RateLimit.Groups[name]; group.Rate <= 0 || group.Burst < 1 {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_%s_RATE and RATE_LIMIT_%s_BURST must be positive", strings.ToUpper(name), strings.ToUpper(name)))
+		}
+	}
+	if c.Health.Interval <= 0 || c.Health.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("HEALTH_CHECK_INTERVAL and HEALTH_CHECK_TIMEOUT must be positive"))
+	}
+	switch c.Telemetry.Exporter {
+	case TraceExporterNone, TraceExporterStdout, TraceExporterFile, TraceExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_EXPORTER must be one of none, stdout, file, otlp"))
+	}
+	if c.Telemetry.SampleRatio < 0 || c.Telemetry.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be between 0 and 1"))
 	}
-	return nil
+	return errors.Join(errs...)
 }
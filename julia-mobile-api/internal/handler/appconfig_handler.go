@@ -3,8 +3,8 @@ package handler
 import (
 	"net/http"
 
-	"github.com/comune-roma/bff-julia-mobile-api/internal/model"
 	"github.com/comune-roma/bff-julia-mobile-api/internal/service"
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/reqctx"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -31,46 +31,34 @@ func NewAppConfigHandler(service *service.AppConfigService, log *zap.Logger) *Ap
 // @Produce json
 // @Param X-App-Platform header string true "App Platform (iOS/Android)"
 // @Param X-App-Version header string true "App Version (semver)"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Success 200 {object} model.AppConfigResponse
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /app-config [get]
 func (h *AppConfigHandler) GetAppConfig(c *gin.Context) {
 	platform := c.GetHeader("X-App-Platform")
 	version := c.GetHeader("X-App-Version")
-	requestID := c.GetHeader("X-Request-Id")
-	correlationID := c.GetHeader("X-Correlation-Id")
 
 	if platform == "" {
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "X-App-Platform header is required",
-		})
+		respondMissingHeader(c, "X-App-Platform")
 		return
 	}
 
 	if version == "" {
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "Bad Request",
-			Message: "X-App-Version header is required",
-		})
+		respondMissingHeader(c, "X-App-Version")
 		return
 	}
 
-	h.log.Info("Getting app config",
+	reqctx.Logger(c.Request.Context(), h.log).Info("Getting app config",
 		zap.String("platform", platform),
 		zap.String("version", version),
-		zap.String("requestID", requestID),
-		zap.String("correlationID", correlationID),
 	)
 
-	config, err := h.service.GetAppConfig(c.Request.Context(), platform, version, requestID, correlationID)
+	config, err := h.service.GetAppConfig(c.Request.Context(), platform, version)
 	if err != nil {
-		h.log.Error("Failed to get app config", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to retrieve app configuration",
-		})
+		respondError(c, h.log, "Failed to get app config", err)
 		return
 	}
 
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/problem"
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/reqctx"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Error codes returned by the handlers
+const (
+	CodeMissingHeader = "MISSING_HEADER"
+)
+
+// respondError maps err to a problem response.
+// Unclassified errors are logged with their cause and returned to the client without internal details.
+func respondError(c *gin.Context, log *zap.Logger, msg string, err error) {
+	p := classify(err)
+	if p.Status() >= 500 {
+		reqctx.Logger(c.Request.Context(), log).Error(msg, zap.String("code", p.Code), zap.Error(err))
+	}
+	problem.Respond(c, p)
+}
+
+// classify returns the problem describing err
+func classify(err error) *problem.Error {
+	var p *problem.Error
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.Is(err, context.DeadlineExceeded):
+		return problem.Wrap(problem.KindDependencyUnavailable, problem.CodeDependencyUnavailable, "A backing service is temporarily unavailable, retry later", err)
+	default:
+		return problem.Wrap(problem.KindInternal, problem.CodeInternal, "", err)
+	}
+}
+
+// respondMissingHeader writes a validation problem for a required request header
+func respondMissingHeader(c *gin.Context, header string) {
+	problem.Respond(c, &problem.Error{
+		Kind:       problem.KindValidation,
+		Code:       CodeMissingHeader,
+		Detail:     header + " header is required",
+		Violations: []problem.Violation{{Field: header, Rule: "required", Message: "is required"}},
+	})
+}
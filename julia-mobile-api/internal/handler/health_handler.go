@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/health"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+// NewHealthHandler creates a new HealthHandler
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{
+		registry: registry,
+	}
+}
+
+// Live godoc
+// @Summary Liveness probe
+// @Description Reports whether the process is running. Dependencies are not checked.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Router /health/live [get]
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, h.registry.Liveness())
+}
+
+// Ready godoc
+// @Summary Readiness probe
+// @Description Reports the last result of each dependency check. Returns 503 when a critical dependency is down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /health/ready [get]
+func (h *HealthHandler) Ready(c *gin.Context) {
+	report, ready := h.registry.Readiness()
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,48 @@
+// Package metrics defines the Prometheus collectors exposed on /metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// HTTPRequests counts requests by method, route template and status class (2xx, 4xx...)
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status class.",
+	}, []string{"method", "route", "status_class"})
+
+	// HTTPRequestDuration observes request latency by method and route template
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route template.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+
+	// HTTPRequestsInFlight reports the requests currently being served
+	HTTPRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+)
+
+// AppConfigResponses counts app-config responses by platform and update action
+var AppConfigResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "appconfig_responses_total",
+	Help: "App configuration responses by platform and update action (NONE, RECOMMEND, REQUIRE).",
+}, []string{"platform", "update_action"})
+
+// Rate limit decisions
+const (
+	RateLimitAllowed = "allowed"
+	RateLimitLimited = "limited"
+	RateLimitExempt  = "exempt"
+	RateLimitError   = "error"
+)
+
+// RateLimitDecisions counts rate limiter decisions by route group and outcome
+var RateLimitDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rate_limit_decisions_total",
+	Help: "Rate limiter decisions by route group and outcome (allowed, limited, exempt, error).",
+}, []string{"group", "outcome"})
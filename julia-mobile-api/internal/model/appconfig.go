@@ -35,12 +35,6 @@ const (
 	ActionNone      UpdateAction = "NONE"
 )
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
-}
-
 // AppPlatform represents the app platform
 type AppPlatform string
 
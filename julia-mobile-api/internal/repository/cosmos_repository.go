@@ -95,3 +95,13 @@ func (r *CosmosRepository) DeleteItem(ctx context.Context, container, id, partit
 
 	return nil
 }
+
+// Ping checks the connection to Cosmos DB
+func (r *CosmosRepository) Ping(ctx context.Context) error {
+	database, err := r.client.NewDatabase(r.database)
+	if err != nil {
+		return err
+	}
+	_, err = database.Read(ctx, nil)
+	return err
+}
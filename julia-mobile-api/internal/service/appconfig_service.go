@@ -6,8 +6,10 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/comune-roma/bff-julia-mobile-api/internal/config"
+	"github.com/comune-roma/bff-julia-mobile-api/internal/metrics"
 	"github.com/comune-roma/bff-julia-mobile-api/internal/model"
 	"github.com/comune-roma/bff-julia-mobile-api/internal/repository"
+	"github.com/comune-roma/bff-julia-mobile-api/pkg/reqctx"
 	"go.uber.org/zap"
 )
 
@@ -30,12 +32,10 @@ func NewAppConfigService(appConfigClient interface{}, repo *repository.CosmosRep
 }
 
 // GetAppConfig retrieves app configuration based on platform and version
-func (s *AppConfigService) GetAppConfig(ctx context.Context, platformStr, versionStr, requestID, correlationID string) (*model.AppConfigResponse, error) {
-	s.log.Info("Fetching app config",
+func (s *AppConfigService) GetAppConfig(ctx context.Context, platformStr, versionStr string) (*model.AppConfigResponse, error) {
+	reqctx.Logger(ctx, s.log).Info("Fetching app config",
 		zap.String("platform", platformStr),
 		zap.String("version", versionStr),
-		zap.String("requestID", requestID),
-		zap.String("correlationID", correlationID),
 	)
 
 	platform := model.AppPlatform(platformStr)
@@ -60,17 +60,28 @@ func (s *AppConfigService) GetAppConfig(ctx context.Context, platformStr, versio
 			Enabled:           maintenanceEnabled,
 			RetryAfterSeconds: &retryAfter,
 		},
-		Update:   s.buildUpdatePolicy(platform, versionStr, minVersionStr, latestVersionStr, storeURL),
+		Update:   s.buildUpdatePolicy(ctx, platform, versionStr, minVersionStr, latestVersionStr, storeURL),
 		Config:   s.cfg.Defaults.Config,
 		Locale:   s.cfg.Defaults.Locale,
 		Features: s.cfg.Defaults.Features,
 	}
 
+	metrics.AppConfigResponses.WithLabelValues(platformLabel(platform), string(response.Update.Action)).Inc()
 	return response, nil
 }
 
-func (s *AppConfigService) buildUpdatePolicy(platform model.AppPlatform, version, minVersion, latestVersion, storeURL string) model.UpdatePolicy {
-	action := s.computeUpdateAction(version, minVersion, latestVersion)
+// platformLabel maps the client-supplied platform to a bounded metric label
+func platformLabel(platform model.AppPlatform) string {
+	switch platform {
+	case model.PlatformIOS, model.PlatformAndroid:
+		return string(platform)
+	default:
+		return "OTHER"
+	}
+}
+
+func (s *AppConfigService) buildUpdatePolicy(ctx context.Context, platform model.AppPlatform, version, minVersion, latestVersion, storeURL string) model.UpdatePolicy {
+	action := s.computeUpdateAction(ctx, version, minVersion, latestVersion)
 
 	return model.UpdatePolicy{
 		StoreURL: storeURL,
@@ -78,10 +89,10 @@ func (s *AppConfigService) buildUpdatePolicy(platform model.AppPlatform, version
 	}
 }
 
-func (s *AppConfigService) computeUpdateAction(versionStr, minVersionStr, latestVersionStr string) model.UpdateAction {
+func (s *AppConfigService) computeUpdateAction(ctx context.Context, versionStr, minVersionStr, latestVersionStr string) model.UpdateAction {
 	v, err := semver.NewVersion(versionStr)
 	if err != nil {
-		s.log.Error("Error parsing app version", zap.String("version", versionStr), zap.Error(err))
+		reqctx.Logger(ctx, s.log).Error("Error parsing app version", zap.String("version", versionStr), zap.Error(err))
 		return model.ActionNone
 	}
 
@@ -15,8 +15,10 @@ func NewCosmosClient(cfg *config.Config) (*azcosmos.Client, error) {
 		return nil, err
 	}
 
-	// Disable SSL verification for emulator
 	clientOptions := &azcosmos.ClientOptions{}
+	clientOptions.PerCallPolicies = append(clientOptions.PerCallPolicies, &tracingPipelinePolicy{})
+
+	// Disable SSL verification for emulator
 	if cfg.CosmosDB.Emulator {
 		clientOptions.ClientOptions.Transport = &http.Client{
 			Transport: &http.Transport{
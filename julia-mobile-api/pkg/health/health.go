@@ -0,0 +1,223 @@
+// Package health runs named dependency checks in the background and serves liveness and readiness reports
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Status of a component or of the whole service
+type Status string
+
+const (
+	StatusUp       Status = "UP"
+	StatusDown     Status = "DOWN"
+	StatusDegraded Status = "DEGRADED" // only non-critical components are down
+	StatusUnknown  Status = "UNKNOWN"  // the check has not completed yet
+)
+
+// CheckFunc returns nil when the component is healthy
+type CheckFunc func(ctx context.Context) error
+
+// check is a registered component check
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// ComponentStatus is the last result of a component check
+type ComponentStatus struct {
+	Status    Status    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt,omitempty"`
+}
+
+// Report is the readiness breakdown returned by the health endpoints
+type Report struct {
+	Status     Status                     `json:"status"`
+	Service    string                     `json:"service"`
+	Time       time.Time                  `json:"time"`
+	Uptime     string                     `json:"uptime,omitempty"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// Registry holds the component checks and their cached results.
+// Checks run periodically in the background so that probes never wait on a dependency.
+type Registry struct {
+	service  string
+	interval time.Duration
+	timeout  time.Duration
+	started  time.Time
+	checks   []check
+	mu       sync.RWMutex
+	results  map[string]ComponentStatus
+	cancel   context.CancelFunc
+	done     chan struct{}
+	log      *zap.Logger
+}
+
+// NewRegistry creates a new Registry
+func NewRegistry(service string, interval, timeout time.Duration, log *zap.Logger) *Registry {
+	return &Registry{
+		service:  service,
+		interval: interval,
+		timeout:  timeout,
+		started:  time.Now(),
+		results:  make(map[string]ComponentStatus),
+		log:      log,
+	}
+}
+
+// Register adds a named check. Critical components make the service not ready when down;
+// the others only degrade the report. Must be called before Start.
+func (r *Registry) Register(name string, critical bool, fn CheckFunc) {
+	r.checks = append(r.checks, check{name: name, critical: critical, fn: fn})
+	r.results[name] = ComponentStatus{Status: StatusUnknown, Critical: critical}
+}
+
+// Start runs all checks immediately and then every interval until Stop is called
+func (r *Registry) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			r.runChecks(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the background checks
+func (r *Registry) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+func (r *Registry) runChecks(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range r.checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			result := r.runCheck(ctx, c)
+
+			r.mu.Lock()
+			previous := r.results[c.name]
+			r.results[c.name] = result
+			r.mu.Unlock()
+
+			if previous.Status != result.Status {
+				r.log.Info("Health check status changed",
+					zap.String("component", c.name),
+					zap.String("from", string(previous.Status)),
+					zap.String("to", string(result.Status)),
+					zap.String("error", result.Error),
+				)
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (r *Registry) runCheck(ctx context.Context, c check) (result ComponentStatus) {
+	checkCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result = ComponentStatus{Status: StatusUp, Critical: c.critical}
+	defer func() {
+		if rec := recover(); rec != nil {
+			result.Status = StatusDown
+			result.Error = fmt.Sprintf("check panicked: %v", rec)
+		}
+		result.LatencyMs = time.Since(start).Milliseconds()
+		result.CheckedAt = start.UTC()
+	}()
+
+	if err := c.fn(checkCtx); err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Liveness reports whether the process itself is working. Dependencies are deliberately
+// not considered: restarting the pod would not fix them.
+func (r *Registry) Liveness() Report {
+	return Report{
+		Status:  StatusUp,
+		Service: r.service,
+		Time:    time.Now().UTC(),
+		Uptime:  time.Since(r.started).Round(time.Second).String(),
+	}
+}
+
+// Readiness returns the cached component breakdown. The service is ready when every
+// critical component is up; non-critical failures only mark it as degraded.
+func (r *Registry) Readiness() (Report, bool) {
+	r.mu.RLock()
+	components := make(map[string]ComponentStatus, len(r.results))
+	for name, result := range r.results {
+		components[name] = result
+	}
+	r.mu.RUnlock()
+
+	status := StatusUp
+	for _, result := range components {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			status = StatusDown
+			break
+		}
+		status = StatusDegraded
+	}
+
+	return Report{
+		Status:     status,
+		Service:    r.service,
+		Time:       time.Now().UTC(),
+		Components: components,
+	}, status != StatusDown
+}
+
+// HTTPCheck returns a check that succeeds when url answers with a status below 500
+func HTTPCheck(client *http.Client, url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
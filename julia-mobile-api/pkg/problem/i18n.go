@@ -0,0 +1,42 @@
+package problem
+
+import "strings"
+
+// defaultLanguage is used when the client does not ask for a supported language
+const defaultLanguage = "en"
+
+// titles holds the localized problem titles per kind and language
+var titles = map[Kind]map[string]string{
+	KindValidation:            {"en": "Invalid request", "it": "Richiesta non valida"},
+	KindUnprocessable:         {"en": "Request cannot be processed", "it": "Richiesta non elaborabile"},
+	KindUnauthorized:          {"en": "Authentication required", "it": "Autenticazione richiesta"},
+	KindForbidden:             {"en": "Access denied", "it": "Accesso negato"},
+	KindNotFound:              {"en": "Resource not found", "it": "Risorsa non trovata"},
+	KindConflict:              {"en": "Conflicting request", "it": "Richiesta in conflitto"},
+	KindGone:                  {"en": "Resource no longer available", "it": "Risorsa non più disponibile"},
+	KindTooManyRequests:       {"en": "Too many requests", "it": "Troppe richieste"},
+	KindDependencyUnavailable: {"en": "Service temporarily unavailable", "it": "Servizio temporaneamente non disponibile"},
+	KindInternal:              {"en": "Internal error", "it": "Errore interno"},
+}
+
+// Title returns the title of kind in the language preferred by the Accept-Language header
+func Title(kind Kind, acceptLanguage string) string {
+	localized, ok := titles[kind]
+	if !ok {
+		localized = titles[KindInternal]
+	}
+	return localized[negotiateLanguage(acceptLanguage)]
+}
+
+// negotiateLanguage picks the first supported language of an Accept-Language header.
+// Quality values are ignored: clients list languages in order of preference.
+func negotiateLanguage(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		base := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		if _, ok := titles[KindInternal][base]; ok {
+			return base
+		}
+	}
+	return defaultLanguage
+}
@@ -0,0 +1,63 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func respond(t *testing.T, err error, acceptLanguage string) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/app-config", nil)
+	c.Request.Header.Set("Accept-Language", acceptLanguage)
+
+	Respond(c, err)
+
+	var body Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	return rec, body
+}
+
+func TestRespondRendersProblem(t *testing.T) {
+	rec, body := respond(t, New(KindNotFound, "CONFIG_NOT_FOUND", "App configuration not found"), "it-IT,it;q=0.9")
+
+	if rec.Code != http.StatusNotFound || body.Status != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d (body %d)", rec.Code, body.Status)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, ContentType) {
+		t.Errorf("expected content type %s, got %s", ContentType, ct)
+	}
+	if body.Type != "urn:julia:problem:config-not-found" || body.Code != "CONFIG_NOT_FOUND" {
+		t.Errorf("unexpected type/code: %s %s", body.Type, body.Code)
+	}
+	if body.Title != "Risorsa non trovata" {
+		t.Errorf("expected Italian title, got %q", body.Title)
+	}
+	if body.Instance != "/api/v1/app-config" {
+		t.Errorf("unexpected instance: %s", body.Instance)
+	}
+}
+
+func TestRespondHidesInternalErrors(t *testing.T) {
+	rec, body := respond(t, errors.New("dial tcp 10.0.0.4:443: connection refused"), "")
+
+	if rec.Code != http.StatusInternalServerError || body.Code != CodeInternal {
+		t.Errorf("expected internal error, got %d %s", rec.Code, body.Code)
+	}
+	if strings.Contains(rec.Body.String(), "10.0.0.4") {
+		t.Errorf("response leaks the internal error: %s", rec.Body.String())
+	}
+	if body.Title != "Internal error" {
+		t.Errorf("expected English title by default, got %q", body.Title)
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTakesUpToBurst(t *testing.T) {
+	s := NewMemoryStore(10)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		res, _ := s.Take(context.Background(), "user:1", limit)
+		if !res.Allowed {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+
+	res, _ := s.Take(context.Background(), "user:1", limit)
+	if res.Allowed {
+		t.Fatal("request over the burst should be limited")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+		t.Errorf("expected retry after within 1s, got %v", res.RetryAfter)
+	}
+
+	// Other clients have their own bucket
+	if res, _ := s.Take(context.Background(), "user:2", limit); !res.Allowed {
+		t.Error("another key should not be limited")
+	}
+}
+
+func TestMemoryStoreRefills(t *testing.T) {
+	s := NewMemoryStore(10)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 1}
+
+	if res, _ := s.Take(context.Background(), "ip:10.0.0.1", limit); !res.Allowed {
+		t.Fatal("first request should be allowed")
+	}
+	if res, _ := s.Take(context.Background(), "ip:10.0.0.1", limit); res.Allowed {
+		t.Fatal("second request should be limited")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res, _ := s.Take(context.Background(), "ip:10.0.0.1", limit); !res.Allowed {
+		t.Error("request after the refill interval should be allowed")
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewMemoryStore(2)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	s.Take(context.Background(), "a", limit)
+	s.Take(context.Background(), "b", limit)
+	s.Take(context.Background(), "c", limit)
+
+	if len(s.buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(s.buckets))
+	}
+	if _, ok := s.buckets["a"]; ok {
+		t.Error("least recently used bucket should have been evicted")
+	}
+}
@@ -0,0 +1,64 @@
+// Package reqctx carries the request and correlation IDs of an incoming request on its context
+package reqctx
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Headers used to exchange the IDs with clients and downstream services
+const (
+	RequestIDHeader     = "X-Request-ID"
+	CorrelationIDHeader = "X-Correlation-ID"
+)
+
+type requestIDKey struct{}
+type correlationIDKey struct{}
+
+// NewID returns a random (version 4) UUID
+func NewID() string {
+	return uuid.NewString()
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithCorrelationID returns a copy of ctx carrying the correlation ID
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, or an empty string
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// Logger returns log enriched with the request, correlation and trace IDs carried by ctx
+func Logger(ctx context.Context, log *zap.Logger) *zap.Logger {
+	fields := make([]zap.Field, 0, 3)
+	if id := RequestID(ctx); id != "" {
+		fields = append(fields, zap.String("requestID", id))
+	}
+	if id := CorrelationID(ctx); id != "" {
+		fields = append(fields, zap.String("correlationID", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		fields = append(fields, zap.String("traceID", sc.TraceID().String()))
+	}
+	if len(fields) == 0 {
+		return log
+	}
+	return log.With(fields...)
+}
@@ -1,20 +1,30 @@
 package config
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
-	"github.com/spf13/viper"
+	"github.com/comune-roma/bff-julia-shared/configloader"
+	"github.com/robfig/cron/v3"
 )
 
+// Config holds the batch configuration.
+// Values come from the defaults below, then application.yaml (or CONFIG_FILE), then environment variables named
+// after the keys, e.g. JULIA_BATCH_JOBS_NOTIFICATION_CRON; see internal/configloader for the tag semantics.
 type Config struct {
-	Batch JobConfig  `mapstructure:"julia_batch_jobs_notification"`
-	Rest  RestConfig `mapstructure:"julia_rest_services"`
+	Batch     JobConfig       `mapstructure:"julia_batch_jobs_notification"`
+	Rest      RestConfig      `mapstructure:"julia_rest_services"`
+	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+
+	loader *configloader.Loader
 }
 
 type JobConfig struct {
 	Cron       string `mapstructure:"cron"`
-	MaxRetries int    `mapstructure:"maxRetries"`
+	MaxRetries int    `mapstructure:"maxRetries" reload:"true"` // applied from the next run
 }
 
 type RestConfig struct {
@@ -28,20 +38,123 @@ type RestService struct {
 	ConnectTimeout time.Duration `mapstructure:"connectTimeout"`
 }
 
-func LoadConfig() *Config {
-	viper.SetConfigName("application") // name of config file (without extension)
-	viper.SetConfigType("yaml")        // REQUIRED if the config file does not have the extension in the name
-	viper.AddConfigPath(".")           // look for config in the working directory
-	viper.AutomaticEnv()               // read in environment variables that match
+// Trace exporters
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterFile   = "file"
+	TraceExporterOTLP   = "otlp"
+)
+
+type TelemetryConfig struct {
+	Exporter    string  `mapstructure:"exporter"` // none, stdout, file or otlp
+	Endpoint    string  `mapstructure:"endpoint"` // OTLP/HTTP traces URL
+	FilePath    string  `mapstructure:"filePath"`
+	ServiceName string  `mapstructure:"serviceName"`
+	SampleRatio float64 `mapstructure:"sampleRatio"`
+}
+
+// LoadConfig loads the configuration from the defaults, the configuration file and environment variables
+func LoadConfig(ctx context.Context) (*Config, error) {
+	loader := configloader.New(configloader.Options{
+		File:  os.Getenv("CONFIG_FILE"),
+		Name:  "application", // name of config file (without extension)
+		Paths: []string{"."}, // look for config in the working directory
+	})
+	cfg := defaultConfig()
+	if err := loader.Load(ctx, cfg); err != nil {
+		return nil, err
+	}
+	cfg.loader = loader
+	return cfg, nil
+}
 
-	if err := viper.ReadInConfig(); err != nil {
-		log.Printf("Warning: No config file found. Using environment variables.")
+// defaultConfig returns the configuration used when no source overrides a value
+func defaultConfig() *Config {
+	return &Config{
+		Batch: JobConfig{
+			Cron:       "* * * * *", // every minute
+			MaxRetries: 3,
+		},
+		Rest: RestConfig{
+			Notification: RestService{RequestTimeout: 30 * time.Second, ConnectTimeout: 5 * time.Second},
+			External:     RestService{RequestTimeout: 30 * time.Second, ConnectTimeout: 5 * time.Second},
+		},
+		Telemetry: TelemetryConfig{
+			Exporter:    TraceExporterNone,
+			FilePath:    "traces.jsonl",
+			ServiceName: "julia-notification-batch",
+			SampleRatio: 1,
+		},
 	}
+}
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		log.Fatalf("Unable to decode into struct, %v", err)
+// File returns the configuration file in use, empty when the configuration comes from the environment only
+func (c *Config) File() string {
+	if c.loader == nil {
+		return ""
 	}
+	return c.loader.File()
+}
 
-	return &config
+// Dump returns the effective configuration, one "key=value (source)" line per value, with secrets redacted
+func (c *Config) Dump() []string {
+	if c.loader == nil {
+		return configloader.New(configloader.Options{}).Dump(c)
+	}
+	return c.loader.Dump(c)
+}
+
+// Watch reloads the configuration file when it changes, until ctx is done. onReload receives the new
+// configuration and the keys that changed, or the reason the change was rejected: only keys tagged
+// reload:"true" may change while the batch runs, the others need a restart.
+func (c *Config) Watch(ctx context.Context, onReload func(next *Config, changed []string, err error)) error {
+	current := c
+	return c.loader.Watch(ctx, func() {
+		next := defaultConfig()
+		changed, err := c.loader.Reload(ctx, current, next, func(*configloader.Loader) error {
+			return next.Validate()
+		})
+		if err != nil {
+			onReload(nil, nil, err)
+			return
+		}
+		next.loader = c.loader
+		current = next
+		onReload(next, changed, nil)
+	})
+}
+
+// Validate checks the configuration and reports every problem found, not only the first one
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := cron.ParseStandard(c.Batch.Cron); err != nil {
+		errs = append(errs, fmt.Errorf("julia_batch_jobs_notification.cron is invalid: %w", err))
+	}
+	if c.Batch.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("julia_batch_jobs_notification.maxRetries must not be negative"))
+	}
+	for _, svc := range []struct {
+		name string
+		cfg  RestService
+	}{
+		{"notification", c.Rest.Notification},
+		{"external", c.Rest.External},
+	} {
+		if svc.cfg.BaseURL == "" {
+			errs = append(errs, fmt.Errorf("julia_rest_services.%s.baseUrl is required", svc.name))
+		}
+		if svc.cfg.RequestTimeout <= 0 || svc.cfg.ConnectTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("julia_rest_services.%s.requestTimeout and connectTimeout must be positive", svc.name))
+		}
+	}
+	switch c.Telemetry.Exporter {
+	case TraceExporterNone, TraceExporterStdout, TraceExporterFile, TraceExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("telemetry.exporter must be one of none, stdout, file, otlp"))
+	}
+	if c.Telemetry.SampleRatio < 0 || c.Telemetry.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("telemetry.sampleRatio must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
 }
@@ -1,11 +1,16 @@
 package gateway
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"julia-notification-batch/internal/config"
 	"julia-notification-batch/internal/service"
+	"julia-notification-batch/internal/telemetry"
 	"net/http"
+
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type ExternalNewsGateway struct {
@@ -22,19 +27,27 @@ func NewExternalNewsGateway(cfg config.RestService) *ExternalNewsGateway {
 	}
 }
 
-func (g *ExternalNewsGateway) GetNotificationPreferences() ([]service.NewsExternalItem, error) {
+func (g *ExternalNewsGateway) GetNotificationPreferences(ctx context.Context) (news []service.NewsExternalItem, err error) {
 	url := fmt.Sprintf("%s/api/v1/external/news", g.baseURL)
-	resp, err := g.client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	_, span := telemetry.StartHTTPClientSpan(ctx, "external-news", req)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	resp, err := g.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error calling external news API: %w", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("external news API returned status: %s", resp.Status)
 	}
 
-	var news []service.NewsExternalItem
 	if err := json.NewDecoder(resp.Body).Decode(&news); err != nil {
 		return nil, fmt.Errorf("error decoding external news response: %w", err)
 	}
@@ -2,11 +2,16 @@ package gateway
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"julia-notification-batch/internal/config"
 	"julia-notification-batch/internal/service"
+	"julia-notification-batch/internal/telemetry"
 	"net/http"
+
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type NotificationNewsGateway struct {
@@ -23,7 +28,7 @@ func NewNotificationNewsGateway(cfg config.RestService) *NotificationNewsGateway
 	}
 }
 
-func (g *NotificationNewsGateway) UpdateNotificationNewsPreferences(news []service.NewsItem) error {
+func (g *NotificationNewsGateway) UpdateNotificationNewsPreferences(ctx context.Context, news []service.NewsItem) (err error) {
 	url := fmt.Sprintf("%s/api/v1/news", g.baseURL)
 
 	body, err := json.Marshal(news)
@@ -31,17 +36,21 @@ func (g *NotificationNewsGateway) UpdateNotificationNewsPreferences(news []servi
 		return fmt.Errorf("error marshaling news request: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
+	_, span := telemetry.StartHTTPClientSpan(ctx, "notification-news", req)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	resp, err := g.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("error calling notification news API: %w", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("notification news API returned status: %s", resp.Status)
@@ -1,37 +1,56 @@
 package jobs
 
 import (
+	"context"
 	"log"
+	"sync/atomic"
 	"time"
+
+	"julia-notification-batch/internal/telemetry"
+
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Orchestrator interface {
-	OrchestrateNotificationPreferencesUpdate() error
+	OrchestrateNotificationPreferencesUpdate(ctx context.Context) error
 }
 
 type NotificationJob struct {
 	orchestrator Orchestrator
-	maxRetries   int
+	maxRetries   atomic.Int64
 }
 
 func NewNotificationJob(orchestrator Orchestrator, maxRetries int) *NotificationJob {
-	return &NotificationJob{
+	j := &NotificationJob{
 		orchestrator: orchestrator,
-		maxRetries:   maxRetries,
 	}
+	j.maxRetries.Store(int64(maxRetries))
+	return j
+}
+
+// SetMaxRetries changes the retries of the next runs, e.g. after a configuration reload
+func (j *NotificationJob) SetMaxRetries(maxRetries int) {
+	j.maxRetries.Store(int64(maxRetries))
 }
 
 func (j *NotificationJob) Run() {
 	log.Println("*************** Starting NotificationJob execution *****************")
-	maxRetries := j.maxRetries
+	maxRetries := int(j.maxRetries.Load())
 	retryCount := 0
 
+	// Each run is the root of its own trace; attempts and gateway calls are children
+	ctx, span := telemetry.Tracer().Start(context.Background(), "NotificationJob run")
+	var err error
+	defer func() { tracing.EndSpan(span, err) }()
+
 	for {
 		log.Printf("Max Retries Property: %d", maxRetries)
 		log.Printf("Retry Count Property: %d", retryCount)
 		log.Printf("*************** Actual Retry Count Property: %d ********************", retryCount)
 
-		err := j.orchestrator.OrchestrateNotificationPreferencesUpdate()
+		span.SetAttributes(attribute.Int("job.attempt", retryCount+1))
+		err = j.orchestrator.OrchestrateNotificationPreferencesUpdate(ctx)
 		if err == nil {
 			log.Println("NotificationJob completed successfully")
 			return
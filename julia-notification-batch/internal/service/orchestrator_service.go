@@ -1,16 +1,17 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"log"
 )
 
 type ExternalGateway interface {
-	GetNotificationPreferences() ([]NewsExternalItem, error)
+	GetNotificationPreferences(ctx context.Context) ([]NewsExternalItem, error)
 }
 
 type InternalGateway interface {
-	UpdateNotificationNewsPreferences(news []NewsItem) error
+	UpdateNotificationNewsPreferences(ctx context.Context, news []NewsItem) error
 }
 
 type OrchestratorService struct {
@@ -25,17 +26,17 @@ func NewOrchestratorService(ext ExternalGateway, int InternalGateway) *Orchestra
 	}
 }
 
-func (s *OrchestratorService) OrchestrateNotificationPreferencesUpdate() error {
+func (s *OrchestratorService) OrchestrateNotificationPreferencesUpdate(ctx context.Context) error {
 	log.Println("Starting orchestration of notification preferences update")
 
-	externalNews, err := s.externalGateway.GetNotificationPreferences()
+	externalNews, err := s.externalGateway.GetNotificationPreferences(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to fetch external news: %w", err)
 	}
 
 	newsItems := MapExternalToInternal(externalNews)
 
-	err = s.internalGateway.UpdateNotificationNewsPreferences(newsItems)
+	err = s.internalGateway.UpdateNotificationNewsPreferences(ctx, newsItems)
 	if err != nil {
 		return fmt.Errorf("failed to update notification news: %w", err)
 	}
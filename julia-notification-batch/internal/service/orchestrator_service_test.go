@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"testing"
 )
 
@@ -34,7 +35,7 @@ type MockExternalGateway struct {
 	err  error
 }
 
-func (m *MockExternalGateway) GetNotificationPreferences() ([]NewsExternalItem, error) {
+func (m *MockExternalGateway) GetNotificationPreferences(ctx context.Context) ([]NewsExternalItem, error) {
 	return m.data, m.err
 }
 
@@ -43,7 +44,7 @@ type MockInternalGateway struct {
 	err      error
 }
 
-func (m *MockInternalGateway) UpdateNotificationNewsPreferences(news []NewsItem) error {
+func (m *MockInternalGateway) UpdateNotificationNewsPreferences(ctx context.Context, news []NewsItem) error {
 	m.lastData = news
 	return m.err
 }
@@ -56,7 +57,7 @@ func TestOrchestrateNotificationPreferencesUpdate(t *testing.T) {
 
 	svc := NewOrchestratorService(mockExt, mockInt)
 
-	err := svc.OrchestrateNotificationPreferencesUpdate()
+	err := svc.OrchestrateNotificationPreferencesUpdate(context.Background())
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
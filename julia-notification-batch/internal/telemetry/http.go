@@ -0,0 +1,25 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartHTTPClientSpan starts a client span for req and injects the W3C trace context into its headers.
+// The caller must end the span, typically with tracing.EndSpan.
+func StartHTTPClientSpan(ctx context.Context, peer string, req *http.Request) (context.Context, trace.Span) {
+	ctx, span := tracing.StartClientSpan(ctx, Tracer(), fmt.Sprintf("%s %s", req.Method, peer),
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.URLFull(req.URL.String()),
+		semconv.PeerService(peer),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return ctx, span
+}
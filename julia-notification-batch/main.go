@@ -1,23 +1,49 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"julia-notification-batch/internal/config"
 	"julia-notification-batch/internal/gateway"
 	"julia-notification-batch/internal/jobs"
 	"julia-notification-batch/internal/scheduler"
 	"julia-notification-batch/internal/service"
+	"julia-notification-batch/internal/telemetry"
 )
 
 func main() {
 	log.Println("Initializing Julia Notification Batch (Go version)...")
 
 	// Load configuration
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig(context.Background())
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	log.Printf("Configuration loaded from %q:", cfg.File())
+	for _, line := range cfg.Dump() {
+		log.Printf("  %s", line)
+	}
+
+	// Initialize tracing
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry)
+	if err != nil {
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}()
 
 	// Initialize Gateways
 	extGateway := gateway.NewExternalNewsGateway(cfg.Rest.External)
@@ -27,23 +53,31 @@ func main() {
 	orchestrator := service.NewOrchestratorService(extGateway, intGateway)
 
 	// Initialize job
-	maxRetries := cfg.Batch.MaxRetries
-	if maxRetries == 0 {
-		maxRetries = 3 // Default
+	job := jobs.NewNotificationJob(orchestrator, cfg.Batch.MaxRetries)
+
+	// Apply configuration file edits to the settings that are safe to change at runtime
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	err = cfg.Watch(watchCtx, func(next *config.Config, changed []string, err error) {
+		if err != nil {
+			log.Printf("Configuration reload rejected: %v", err)
+			return
+		}
+		if len(changed) == 0 {
+			return
+		}
+		job.SetMaxRetries(next.Batch.MaxRetries)
+		log.Printf("Configuration reloaded: %v", changed)
+	})
+	if err != nil {
+		log.Printf("Warning: configuration file changes will not be applied: %v", err)
 	}
-	job := jobs.NewNotificationJob(orchestrator, maxRetries)
 
 	// Initialize scheduler
 	s := scheduler.NewScheduler()
 
 	// Add job to scheduler
-	cronExpr := cfg.Batch.Cron
-	if cronExpr == "" {
-		log.Println("No cron expression found in config, using default (every minute)")
-		cronExpr = "* * * * *"
-	}
-
-	_, err := s.AddJob(cronExpr, job)
+	_, err = s.AddJob(cfg.Batch.Cron, job)
 	if err != nil {
 		log.Fatalf("Error adding job to scheduler: %v", err)
 	}
@@ -0,0 +1,409 @@
+// Command dlq inspects and replays the dead-letter queue of the worker subscription.
+//
+//	go run ./cmd/dlq list [-reason ValidationFailed] [-since 24h] [-until 2026-01-02T15:04:05Z]
+//	go run ./cmd/dlq export -out dlq.jsonl [filters]
+//	go run ./cmd/dlq resubmit [-in dlq.jsonl | filters] [-seq 12,15] [-dry-run]
+//
+// resubmit sends the selected messages back to the topic, with the body from -in when given so that exported
+// records can be fixed by hand first, then removes them from the dead-letter queue.
+// Connection settings come from the worker configuration.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"julia-notification-worker/internal/config"
+	"julia-notification-worker/internal/worker"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+)
+
+const (
+	peekPageSize = 100
+	// receiveWait is how long resubmit waits for more dead-lettered messages before giving up on missing ones
+	receiveWait = 5 * time.Second
+	// lockRenewMargin is how close to its expiry the lock of a held message is renewed between two receives
+	lockRenewMargin = 30 * time.Second
+	// ReplayedFromProperty marks resubmitted messages with the subscription whose dead-letter queue they come from
+	ReplayedFromProperty = "replayedFromDeadLetter"
+)
+
+// record is a dead-lettered message as listed and exported, one JSON document per line
+type record struct {
+	SequenceNumber        int64           `json:"sequenceNumber"`
+	MessageID             string          `json:"messageId"`
+	EnqueuedTime          *time.Time      `json:"enqueuedTime,omitempty"`
+	DeliveryCount         uint32          `json:"deliveryCount"`
+	Reason                string          `json:"reason,omitempty"`
+	Description           string          `json:"description,omitempty"`
+	ContentType           string          `json:"contentType,omitempty"`
+	Subject               string          `json:"subject,omitempty"`
+	CorrelationID         string          `json:"correlationId,omitempty"`
+	ApplicationProperties map[string]any  `json:"applicationProperties,omitempty"`
+	Body                  json.RawMessage `json:"body"` // the JSON payload as is, or a JSON string when it is not JSON
+}
+
+// filter selects dead-lettered messages
+type filter struct {
+	reason    string
+	since     time.Time
+	until     time.Time
+	sequences map[int64]bool
+}
+
+func (f filter) match(r record) bool {
+	if f.reason != "" && r.Reason != f.reason {
+		return false
+	}
+	if r.EnqueuedTime != nil && (!f.since.IsZero() && r.EnqueuedTime.Before(f.since) || !f.until.IsZero() && r.EnqueuedTime.After(f.until)) {
+		return false
+	}
+	return len(f.sequences) == 0 || f.sequences[r.SequenceNumber]
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("Usage: dlq list|export|resubmit [flags]")
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	reason := flags.String("reason", "", "only messages dead-lettered with this reason, e.g. ValidationFailed")
+	since := flags.String("since", "", "only messages enqueued after this time (RFC 3339) or duration ago (e.g. 24h)")
+	until := flags.String("until", "", "only messages enqueued before this time (RFC 3339) or duration ago")
+	sequences := flags.String("seq", "", "only these comma-separated sequence numbers")
+	maxMessages := flags.Int("max", 1000, "maximum number of messages to read")
+	out := flags.String("out", "", "export: destination JSONL file, standard output when empty")
+	in := flags.String("in", "", "resubmit: JSONL file of records to send instead of the dead-lettered bodies")
+	dryRun := flags.Bool("dry-run", false, "resubmit: print what would be sent without sending or removing anything")
+	flags.Parse(args)
+
+	f, err := parseFilter(*reason, *since, *until, *sequences)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	client, err := azservicebus.NewClientFromConnectionString(cfg.ServiceBus.ConnectionString, nil)
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close(ctx)
+
+	receiver, err := client.NewReceiverForSubscription(cfg.ServiceBus.TopicName, cfg.ServiceBus.SubscriptionName, &azservicebus.ReceiverOptions{
+		SubQueue: azservicebus.SubQueueDeadLetter,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create dead-letter receiver: %v", err)
+	}
+	defer receiver.Close(ctx)
+
+	switch command {
+	case "list":
+		records, err := peek(ctx, receiver, f, *maxMessages)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		for _, r := range records {
+			fmt.Printf("#%d %s enqueued=%s deliveries=%d reason=%s\n  %s\n  %s\n",
+				r.SequenceNumber, r.MessageID, formatTime(r.EnqueuedTime), r.DeliveryCount, r.Reason, r.Description, r.Body)
+		}
+		fmt.Printf("%d messages\n", len(records))
+
+	case "export":
+		records, err := peek(ctx, receiver, f, *maxMessages)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := export(records, *out); err != nil {
+			log.Fatalf("%v", err)
+		}
+		log.Printf("%d messages exported", len(records))
+
+	case "resubmit":
+		var records []record
+		if *in != "" {
+			records, err = readRecords(*in, f)
+		} else {
+			records, err = peek(ctx, receiver, f, *maxMessages)
+		}
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := resubmit(ctx, client, receiver, cfg.ServiceBus, records, *dryRun); err != nil {
+			log.Fatalf("%v", err)
+		}
+
+	default:
+		log.Fatalf("Unknown command %q, expected list, export or resubmit", command)
+	}
+}
+
+func parseFilter(reason, since, until, sequences string) (filter, error) {
+	f := filter{reason: reason}
+	var err error
+	if f.since, err = parseTime(since); err != nil {
+		return f, fmt.Errorf("invalid -since: %w", err)
+	}
+	if f.until, err = parseTime(until); err != nil {
+		return f, fmt.Errorf("invalid -until: %w", err)
+	}
+	if sequences != "" {
+		f.sequences = make(map[int64]bool)
+		for _, s := range strings.Split(sequences, ",") {
+			n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return f, fmt.Errorf("invalid -seq %q: %w", s, err)
+			}
+			f.sequences[n] = true
+		}
+	}
+	return f, nil
+}
+
+// parseTime accepts an RFC 3339 time or a duration before now
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// peek reads up to limit messages of the dead-letter queue without locking them and returns the matching ones
+func peek(ctx context.Context, receiver *azservicebus.Receiver, f filter, limit int) ([]record, error) {
+	var (
+		records []record
+		from    int64
+		read    int
+	)
+	for read < limit {
+		messages, err := receiver.PeekMessages(ctx, min(peekPageSize, limit-read), &azservicebus.PeekMessagesOptions{FromSequenceNumber: &from})
+		if err != nil {
+			return nil, fmt.Errorf("failed to peek dead-letter queue: %w", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+		for _, m := range messages {
+			r := toRecord(m)
+			if f.match(r) {
+				records = append(records, r)
+			}
+			from = r.SequenceNumber + 1
+		}
+		read += len(messages)
+	}
+	return records, nil
+}
+
+func toRecord(m *azservicebus.ReceivedMessage) record {
+	r := record{
+		MessageID:             m.MessageID,
+		EnqueuedTime:          m.EnqueuedTime,
+		DeliveryCount:         m.DeliveryCount,
+		Reason:                deref(m.DeadLetterReason),
+		Description:           deref(m.DeadLetterErrorDescription),
+		ContentType:           deref(m.ContentType),
+		Subject:               deref(m.Subject),
+		CorrelationID:         deref(m.CorrelationID),
+		ApplicationProperties: m.ApplicationProperties,
+		Body:                  m.Body,
+	}
+	if m.SequenceNumber != nil {
+		r.SequenceNumber = *m.SequenceNumber
+	}
+	if !json.Valid(m.Body) {
+		r.Body, _ = json.Marshal(string(m.Body))
+	}
+	return r
+}
+
+func export(records []record, path string) error {
+	w := os.Stdout
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to write record %d: %w", r.SequenceNumber, err)
+		}
+	}
+	return nil
+}
+
+func readRecords(path string, f filter) ([]record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if f.match(r) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// resubmit sends records to the topic and completes the dead-lettered originals, matched by sequence number.
+// Messages received while looking for them are held, with their locks renewed between receives, and abandoned
+// at the end so that each is seen once: abandoning them earlier would make the next receive return them again.
+func resubmit(ctx context.Context, client *azservicebus.Client, receiver *azservicebus.Receiver, cfg config.ServiceBusConfig, records []record, dryRun bool) error {
+	pending := make(map[int64]record, len(records))
+	for _, r := range records {
+		pending[r.SequenceNumber] = r
+	}
+	if dryRun {
+		for _, r := range records {
+			fmt.Printf("would resubmit #%d %s to %s: %s\n", r.SequenceNumber, r.MessageID, cfg.TopicName, r.Body)
+		}
+		fmt.Printf("%d messages\n", len(records))
+		return nil
+	}
+
+	sender, err := client.NewSender(cfg.TopicName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create sender: %w", err)
+	}
+	defer sender.Close(ctx)
+
+	var held []*azservicebus.ReceivedMessage
+	defer func() {
+		for _, m := range held {
+			if err := receiver.AbandonMessage(ctx, m, nil); err != nil {
+				log.Printf("Error releasing message #%d: %v", *m.SequenceNumber, err)
+			}
+		}
+	}()
+
+	resubmitted := 0
+	for len(pending) > 0 {
+		renewLocks(ctx, receiver, held)
+		receiveCtx, cancel := context.WithTimeout(ctx, receiveWait)
+		messages, err := receiver.ReceiveMessages(receiveCtx, peekPageSize, nil)
+		cancel()
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("failed to receive from dead-letter queue: %w", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+		for _, m := range messages {
+			r, ok := pending[*m.SequenceNumber]
+			if !ok {
+				held = append(held, m)
+				continue
+			}
+			if err := sender.SendMessage(ctx, toMessage(r, cfg.SubscriptionName), nil); err != nil {
+				held = append(held, m)
+				return fmt.Errorf("failed to resubmit message #%d: %w", r.SequenceNumber, err)
+			}
+			if err := receiver.CompleteMessage(ctx, m, nil); err != nil {
+				log.Printf("Message #%d resubmitted but not removed from the dead-letter queue: %v", r.SequenceNumber, err)
+			}
+			delete(pending, r.SequenceNumber)
+			resubmitted++
+			log.Printf("Resubmitted #%d %s", r.SequenceNumber, r.MessageID)
+		}
+	}
+
+	for seq := range pending {
+		log.Printf("Message #%d not found in the dead-letter queue, not resubmitted", seq)
+	}
+	log.Printf("%d messages resubmitted to %s", resubmitted, cfg.TopicName)
+	return nil
+}
+
+// renewLocks renews the locks of the held messages that expire within lockRenewMargin
+func renewLocks(ctx context.Context, receiver *azservicebus.Receiver, held []*azservicebus.ReceivedMessage) {
+	for _, m := range held {
+		if m.LockedUntil == nil || time.Until(*m.LockedUntil) > lockRenewMargin {
+			continue
+		}
+		if err := receiver.RenewMessageLock(ctx, m, nil); err != nil {
+			log.Printf("Error renewing lock of message #%d: %v", *m.SequenceNumber, err)
+		}
+	}
+}
+
+// toMessage rebuilds the message to send from a record, keeping its ID so that the worker deduplication applies.
+// Like the scheduled copies, it is addressed to the subscription it was dead-lettered from through the scheduledFor
+// property, which the other subscriptions of the topic filter out, and it starts again with a full delivery count.
+func toMessage(r record, subscription string) *azservicebus.Message {
+	body := []byte(r.Body)
+	var text string
+	if json.Unmarshal(r.Body, &text) == nil {
+		body = []byte(text)
+	}
+	properties := make(map[string]any, len(r.ApplicationProperties)+2)
+	for k, v := range r.ApplicationProperties {
+		properties[k] = v
+	}
+	delete(properties, worker.RetryAttemptsProperty)
+	properties[worker.ScheduledForProperty] = subscription
+	properties[ReplayedFromProperty] = subscription
+
+	m := &azservicebus.Message{
+		MessageID:             &r.MessageID,
+		Body:                  body,
+		ApplicationProperties: properties,
+	}
+	if r.ContentType != "" {
+		m.ContentType = &r.ContentType
+	}
+	if r.Subject != "" {
+		m.Subject = &r.Subject
+	}
+	if r.CorrelationID != "" {
+		m.CorrelationID = &r.CorrelationID
+	}
+	return m
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"julia-notification-worker/internal/worker"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+)
+
+func TestParseFilter(t *testing.T) {
+	f, err := parseFilter("ValidationFailed", "24h", "2026-01-02T15:04:05Z", "12, 15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.reason != "ValidationFailed" {
+		t.Errorf("reason = %q", f.reason)
+	}
+	if d := time.Since(f.since); d < 24*time.Hour || d > 25*time.Hour {
+		t.Errorf("since should be 24h ago, got %s", f.since)
+	}
+	if !f.until.Equal(time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)) {
+		t.Errorf("until = %s", f.until)
+	}
+	if len(f.sequences) != 2 || !f.sequences[12] || !f.sequences[15] {
+		t.Errorf("sequences = %v", f.sequences)
+	}
+
+	for _, args := range [][4]string{
+		{"", "yesterday", "", ""},
+		{"", "", "2026-01-02", ""},
+		{"", "", "", "12,x"},
+	} {
+		if _, err := parseFilter(args[0], args[1], args[2], args[3]); err == nil {
+			t.Errorf("parseFilter%q should fail", args)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	enqueued := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	r := record{SequenceNumber: 12, Reason: "ValidationFailed", EnqueuedTime: &enqueued}
+
+	tests := []struct {
+		name   string
+		filter filter
+		want   bool
+	}{
+		{"no filter", filter{}, true},
+		{"reason", filter{reason: "ValidationFailed"}, true},
+		{"other reason", filter{reason: "MaxDeliveryExceeded"}, false},
+		{"in range", filter{since: enqueued.Add(-time.Hour), until: enqueued.Add(time.Hour)}, true},
+		{"too old", filter{since: enqueued.Add(time.Hour)}, false},
+		{"too recent", filter{until: enqueued.Add(-time.Hour)}, false},
+		{"sequence", filter{sequences: map[int64]bool{12: true}}, true},
+		{"other sequence", filter{sequences: map[int64]bool{15: true}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.match(r); got != tt.want {
+			t.Errorf("%s: match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToMessage(t *testing.T) {
+	properties := map[string]any{"traceparent": "00-abc-def-01", worker.RetryAttemptsProperty: int64(9)}
+	r := record{
+		MessageID:             "m1",
+		ContentType:           "application/json",
+		CorrelationID:         "c1",
+		ApplicationProperties: properties,
+		Body:                  json.RawMessage(`{"title":"A","body":"B"}`),
+	}
+
+	m := toMessage(r, "notification-subscription")
+	if *m.MessageID != "m1" || *m.ContentType != "application/json" || *m.CorrelationID != "c1" || m.Subject != nil {
+		t.Errorf("unexpected message fields %+v", m)
+	}
+	if string(m.Body) != `{"title":"A","body":"B"}` {
+		t.Errorf("a JSON body should be sent as is, got %s", m.Body)
+	}
+	if m.ApplicationProperties[worker.ScheduledForProperty] != "notification-subscription" ||
+		m.ApplicationProperties[ReplayedFromProperty] != "notification-subscription" {
+		t.Errorf("the replay should be addressed to its subscription, got %v", m.ApplicationProperties)
+	}
+	if m.ApplicationProperties["traceparent"] != "00-abc-def-01" {
+		t.Errorf("the original properties should be kept, got %v", m.ApplicationProperties)
+	}
+	if _, ok := m.ApplicationProperties[worker.RetryAttemptsProperty]; ok {
+		t.Error("the replay should start again with a full delivery count")
+	}
+	if len(properties) != 2 {
+		t.Errorf("the record properties should not be modified, got %v", properties)
+	}
+
+	// Bodies that are not JSON are exported as JSON strings and sent back as the original text
+	text := toRecord(&azservicebus.ReceivedMessage{MessageID: "m2", Body: []byte("not json")})
+	if m := toMessage(text, "notification-subscription"); string(m.Body) != "not json" {
+		t.Errorf("a string body should be unwrapped, got %s", m.Body)
+	}
+}
@@ -6,15 +6,27 @@ import (
 	"log"
 
 	"julia-notification-worker/internal/config"
+	"julia-notification-worker/internal/telemetry"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func main() {
 	fmt.Println("Service Bus Test Publisher")
 
 	// Load configuration
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	connectionString := cfg.ServiceBus.ConnectionString
 	topicName := cfg.ServiceBus.TopicName
@@ -39,7 +51,12 @@ func main() {
 		ContentType: &contentType,
 	}
 
-	err = sender.SendMessage(context.Background(), message, nil)
+	// Start a trace so that the worker processing can be correlated with this publish
+	ctx, span := telemetry.Tracer().Start(context.Background(), fmt.Sprintf("%s publish", topicName), trace.WithSpanKind(trace.SpanKindProducer))
+	telemetry.InjectMessage(ctx, message)
+
+	err = sender.SendMessage(ctx, message, nil)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		log.Fatalf("Failed to send message: %v", err)
 	}
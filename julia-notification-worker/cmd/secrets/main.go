@@ -0,0 +1,90 @@
+// Command secrets manages the encrypted secrets file read through SECRETS_FILE and SECRETS_KEY.
+//
+//	go run ./cmd/secrets -genkey
+//	SECRETS_KEY=... go run ./cmd/secrets -file secrets.enc servicebus-connection-string='Endpoint=...'
+//	SECRETS_KEY=... go run ./cmd/secrets -file secrets.enc -list
+//
+// Pairs are merged into the existing file; an empty value removes the secret.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/comune-roma/bff-julia-shared/configloader"
+)
+
+func main() {
+	genKey := flag.Bool("genkey", false, "print a new random key for SECRETS_KEY")
+	file := flag.String("file", os.Getenv(configloader.SecretsFileEnv), "encrypted secrets file")
+	list := flag.Bool("list", false, "list the secret names held by the file")
+	flag.Parse()
+
+	if *genKey {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(key))
+		return
+	}
+
+	if *file == "" {
+		log.Fatalf("-file or %s is required", configloader.SecretsFileEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(os.Getenv(configloader.SecretsKeyEnv))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", configloader.SecretsKeyEnv, err)
+	}
+
+	secrets, err := configloader.OpenSecretsFile(*file, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		secrets = make(map[string]string)
+	} else if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if *list {
+		names := make([]string, 0, len(secrets))
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	if flag.NArg() == 0 {
+		log.Fatal("Nothing to do: pass name=value pairs, -list or -genkey")
+	}
+	for _, pair := range flag.Args() {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			log.Fatalf("Invalid pair %q, expected name=value", pair)
+		}
+		if value == "" {
+			delete(secrets, name)
+		} else {
+			secrets[name] = value
+		}
+	}
+
+	sealed, err := configloader.SealSecrets(key, secrets)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := os.WriteFile(*file, sealed, 0o600); err != nil {
+		log.Fatalf("Failed to write secrets file: %v", err)
+	}
+	fmt.Printf("%d secrets written to %s\n", len(secrets), *file)
+}
@@ -1,52 +1,332 @@
 package config
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
 
-	"github.com/spf13/viper"
+	"github.com/comune-roma/bff-julia-shared/configloader"
 )
 
+// Config holds the worker configuration.
+// Values come from the defaults below, then application.yaml (or CONFIG_FILE), then the secret providers selected by
+// the SECRETS_* variables, then environment variables named after the keys, e.g. AZURE_SERVICEBUS_CONNECTIONSTRING;
+// see internal/configloader for the tag semantics.
 type Config struct {
 	ServiceBus      ServiceBusConfig      `mapstructure:"azure_servicebus"`
 	NotificationHub NotificationHubConfig `mapstructure:"azure_notificationhub"`
+	Deduplication   DeduplicationConfig   `mapstructure:"deduplication"`
+	Idempotency     IdempotencyConfig     `mapstructure:"idempotency"`
+	Scheduling      SchedulingConfig      `mapstructure:"scheduling"`
+	Tracking        TrackingConfig        `mapstructure:"tracking"`
+	Metrics         MetricsConfig         `mapstructure:"metrics"`
+	Telemetry       TelemetryConfig       `mapstructure:"telemetry"`
+
+	loader *configloader.Loader
 }
 
 type ServiceBusConfig struct {
-	ConnectionString   string `mapstructure:"connectionString"`
-	TopicName          string `mapstructure:"topicName"`
-	SubscriptionName   string `mapstructure:"subscriptionName"`
-	MaxConcurrentCalls int    `mapstructure:"maxConcurrentCalls"`
+	ConnectionString      string `mapstructure:"connectionString" secret:"servicebus-connection-string"`
+	TopicName             string `mapstructure:"topicName"`
+	SubscriptionName      string `mapstructure:"subscriptionName"`
+	MaxConcurrentCalls    int    `mapstructure:"maxConcurrentCalls"`    // messages processed in parallel
+	PrefetchCount         int    `mapstructure:"prefetchCount"`         // messages received ahead of a free worker
+	MaxLockRenewalSeconds int    `mapstructure:"maxLockRenewalSeconds"` // how long a message lock is kept alive, 0 disables renewal
+	DrainTimeoutSeconds   int    `mapstructure:"drainTimeoutSeconds"`   // time given to in-flight messages on shutdown
+	// MaxDeliveryCount is the delivery after which a transiently failing message is dead-lettered by the worker;
+	// keep it at or below the subscription's own max delivery count so that the reason is recorded
+	MaxDeliveryCount       int `mapstructure:"maxDeliveryCount"`
+	RetryBackoffSeconds    int `mapstructure:"retryBackoffSeconds"`    // delay before a failed message is delivered again, doubled at each delivery
+	MaxRetryBackoffSeconds int `mapstructure:"maxRetryBackoffSeconds"` // upper bound of that wait
 }
 
 type NotificationHubConfig struct {
-	ConnectionString   string `mapstructure:"connectionString"`
+	ConnectionString   string `mapstructure:"connectionString" secret:"notificationhub-connection-string" reload:"true"` // rotated without restart
 	HubName            string `mapstructure:"hubName"`
-	Enabled            bool   `mapstructure:"enabled"`
+	Enabled            bool   `mapstructure:"enabled" reload:"true"` // kill switch, applied without restart
 	SendTimeoutSeconds int    `mapstructure:"sendTimeoutSeconds"`
+	DefaultFormat      string `mapstructure:"defaultFormat"` // format of messages without one: template, apple, fcmv1 or native
+}
+
+// Deduplication stores
+const (
+	DeduplicationStoreMemory = "memory"
+	DeduplicationStoreRedis  = "redis"
+)
+
+type DeduplicationConfig struct {
+	Store           string      `mapstructure:"store"`           // memory (single replica) or redis (shared by all replicas)
+	TTLSeconds      int         `mapstructure:"ttlSeconds"`      // how long a sent notification is remembered
+	ClaimTTLSeconds int         `mapstructure:"claimTtlSeconds"` // how long a send in progress blocks other deliveries
+	MaxEntries      int         `mapstructure:"maxEntries"`      // memory store bound, the oldest entries are evicted first
+	Redis           RedisConfig `mapstructure:"redis"`
+}
+
+type RedisConfig struct {
+	Addr      string `mapstructure:"addr"`
+	Password  string `mapstructure:"password" secret:"redis-password"`
+	DB        int    `mapstructure:"db"`
+	KeyPrefix string `mapstructure:"keyPrefix"`
+}
+
+// Idempotency key sources
+const (
+	IdempotencySourceMessageID = "messageId" // the Service Bus MessageID
+	IdempotencySourceProperty  = "property"  // an application property set by the publisher
+	IdempotencySourceContent   = "content"   // a hash of title, body, tag expression and data
+)
+
+// IdempotencyConfig selects the deduplication key of each message type, the "type" field of the message.
+// When the selected source yields no key the content hash is used, so that no message escapes deduplication.
+type IdempotencyConfig struct {
+	Default IdempotencyRule            `mapstructure:"default"`
+	Types   map[string]IdempotencyRule `mapstructure:"types"` // by message type, in lower case
+}
+
+type IdempotencyRule struct {
+	Source   string `mapstructure:"source"`   // one of the IdempotencySource* values
+	Property string `mapstructure:"property"` // application property holding the key, with the property source
 }
 
-func LoadConfig() *Config {
-	viper.SetConfigName("application")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AutomaticEnv()
+// SchedulingConfig controls the notifications carrying a sendAt, deferred as Service Bus scheduled messages.
+// Schedules and cancellations are recorded in the deduplication store, so redis is needed with more than one replica.
+type SchedulingConfig struct {
+	DefaultTimeZone  string `mapstructure:"defaultTimeZone"`  // IANA zone of sendAt values without offset nor timeZone
+	MinDelaySeconds  int    `mapstructure:"minDelaySeconds"`  // a sendAt closer than this is sent at once
+	CancelTTLSeconds int    `mapstructure:"cancelTtlSeconds"` // how long records outlive their sendAt, and cancellations of unknown messages are kept
+	KeyPrefix        string `mapstructure:"keyPrefix"`        // Redis prefix of the records, with the redis store
+}
+
+// TrackingConfig controls the delivery records kept for every message sent, in the deduplication store
+type TrackingConfig struct {
+	Enabled             bool   `mapstructure:"enabled"`
+	TTLSeconds          int    `mapstructure:"ttlSeconds"`          // how long a delivery record is kept
+	MaxRecords          int    `mapstructure:"maxRecords"`          // memory store bound, the oldest records are evicted first
+	KeyPrefix           string `mapstructure:"keyPrefix"`           // Redis prefix of the records, with the redis store
+	PollOutcome         bool   `mapstructure:"pollOutcome"`         // read the outcome from the hub telemetry, Standard tier only
+	PollDelaySeconds    int    `mapstructure:"pollDelaySeconds"`    // wait before the first outcome read
+	PollIntervalSeconds int    `mapstructure:"pollIntervalSeconds"` // wait between reads while the hub is still sending
+	MaxPollAttempts     int    `mapstructure:"maxPollAttempts"`
+}
+
+type MetricsConfig struct {
+	Address string `mapstructure:"address"` // listen address of the Prometheus /metrics endpoint, empty disables it
+}
+
+// Trace exporters
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterFile   = "file"
+	TraceExporterOTLP   = "otlp"
+)
 
-	if err := viper.ReadInConfig(); err != nil {
-		log.Printf("Warning: No config file found. Using environment variables.")
+type TelemetryConfig struct {
+	Exporter    string  `mapstructure:"exporter"` // none, stdout, file or otlp
+	Endpoint    string  `mapstructure:"endpoint"` // OTLP/HTTP traces URL
+	FilePath    string  `mapstructure:"filePath"`
+	ServiceName string  `mapstructure:"serviceName"`
+	SampleRatio float64 `mapstructure:"sampleRatio"`
+}
+
+// LoadConfig loads the configuration from the defaults, the configuration file, secret providers and environment variables
+func LoadConfig(ctx context.Context) (*Config, error) {
+	providers, refresh, err := configloader.SecretProvidersFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	loader := configloader.New(configloader.Options{
+		File:            os.Getenv("CONFIG_FILE"),
+		Name:            "application",
+		Paths:           []string{"."},
+		Providers:       providers,
+		RefreshInterval: refresh,
+	})
+	cfg := defaultConfig()
+	if err := loader.Load(ctx, cfg); err != nil {
+		return nil, err
 	}
+	cfg.loader = loader
+	return cfg, nil
+}
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		log.Fatalf("Unable to decode into struct, %v", err)
+// defaultConfig returns the configuration used when no source overrides a value
+func defaultConfig() *Config {
+	return &Config{
+		ServiceBus: ServiceBusConfig{
+			MaxConcurrentCalls:     1,
+			MaxLockRenewalSeconds:  300,
+			DrainTimeoutSeconds:    30,
+			MaxDeliveryCount:       10,
+			RetryBackoffSeconds:    5,
+			MaxRetryBackoffSeconds: 120,
+		},
+		NotificationHub: NotificationHubConfig{
+			SendTimeoutSeconds: 60,
+			DefaultFormat:      "template",
+		},
+		Deduplication: DeduplicationConfig{
+			Store:           DeduplicationStoreMemory,
+			TTLSeconds:      24 * 60 * 60,
+			ClaimTTLSeconds: 10 * 60,
+			MaxEntries:      100000,
+			Redis: RedisConfig{
+				Addr:      "localhost:6379",
+				KeyPrefix: "julia:notification:dedup:",
+			},
+		},
+		Idempotency: IdempotencyConfig{
+			Default: IdempotencyRule{Source: IdempotencySourceMessageID},
+		},
+		Scheduling: SchedulingConfig{
+			DefaultTimeZone:  "Europe/Rome",
+			MinDelaySeconds:  60,
+			CancelTTLSeconds: 7 * 24 * 60 * 60,
+			KeyPrefix:        "julia:notification:schedule:",
+		},
+		Tracking: TrackingConfig{
+			Enabled:             true,
+			TTLSeconds:          7 * 24 * 60 * 60,
+			MaxRecords:          10000,
+			KeyPrefix:           "julia:notification:delivery:",
+			PollDelaySeconds:    10,
+			PollIntervalSeconds: 30,
+			MaxPollAttempts:     10,
+		},
+		Metrics: MetricsConfig{
+			Address: ":9090",
+		},
+		Telemetry: TelemetryConfig{
+			Exporter:    TraceExporterNone,
+			FilePath:    "traces.jsonl",
+			ServiceName: "julia-notification-worker",
+			SampleRatio: 1,
+		},
 	}
+}
 
-	if config.ServiceBus.MaxConcurrentCalls == 0 {
-		config.ServiceBus.MaxConcurrentCalls = 1
+// File returns the configuration file in use, empty when the configuration comes from the environment only
+func (c *Config) File() string {
+	if c.loader == nil {
+		return ""
 	}
+	return c.loader.File()
+}
 
-	if config.NotificationHub.SendTimeoutSeconds == 0 {
-		config.NotificationHub.SendTimeoutSeconds = 60
+// Dump returns the effective configuration, one "key=value (source)" line per value, with secrets redacted
+func (c *Config) Dump() []string {
+	if c.loader == nil {
+		return configloader.New(configloader.Options{}).Dump(c)
 	}
+	return c.loader.Dump(c)
+}
+
+// Watch reloads the configuration when the file changes and at every SECRETS_REFRESH_INTERVAL, until ctx is done.
+// onReload receives the new configuration and the keys that changed, or the reason the change was rejected:
+// only keys tagged reload:"true" may change while the worker runs, the others need a restart.
+func (c *Config) Watch(ctx context.Context, onReload func(next *Config, changed []string, err error)) error {
+	current := c
+	return c.loader.Watch(ctx, func() {
+		next := defaultConfig()
+		changed, err := c.loader.Reload(ctx, current, next, func(*configloader.Loader) error {
+			return next.Validate()
+		})
+		if err != nil {
+			onReload(nil, nil, err)
+			return
+		}
+		next.loader = c.loader
+		current = next
+		onReload(next, changed, nil)
+	})
+}
 
-	return &config
+// Validate checks the configuration and reports every problem found, not only the first one
+func (c *Config) Validate() error {
+	var errs []error
+	if c.ServiceBus.ConnectionString == "" {
+		errs = append(errs, fmt.Errorf("azure_servicebus.connectionString is required"))
+	}
+	if c.ServiceBus.TopicName == "" || c.ServiceBus.SubscriptionName == "" {
+		errs = append(errs, fmt.Errorf("azure_servicebus.topicName and azure_servicebus.subscriptionName are required"))
+	}
+	if c.ServiceBus.MaxConcurrentCalls < 1 {
+		errs = append(errs, fmt.Errorf("azure_servicebus.maxConcurrentCalls must be at least 1"))
+	}
+	if c.ServiceBus.PrefetchCount < 0 || c.ServiceBus.MaxLockRenewalSeconds < 0 || c.ServiceBus.DrainTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("azure_servicebus.prefetchCount, maxLockRenewalSeconds and drainTimeoutSeconds must not be negative"))
+	}
+	if c.ServiceBus.MaxDeliveryCount < 1 {
+		errs = append(errs, fmt.Errorf("azure_servicebus.maxDeliveryCount must be at least 1"))
+	}
+	if c.ServiceBus.RetryBackoffSeconds < 0 || c.ServiceBus.MaxRetryBackoffSeconds < c.ServiceBus.RetryBackoffSeconds {
+		errs = append(errs, fmt.Errorf("azure_servicebus.retryBackoffSeconds must not be negative nor exceed maxRetryBackoffSeconds"))
+	}
+	if c.NotificationHub.Enabled && (c.NotificationHub.ConnectionString == "" || c.NotificationHub.HubName == "") {
+		errs = append(errs, fmt.Errorf("azure_notificationhub.connectionString and azure_notificationhub.hubName are required when the hub is enabled"))
+	}
+	switch c.NotificationHub.DefaultFormat {
+	case "template", "apple", "fcmv1", "native":
+	default:
+		errs = append(errs, fmt.Errorf("azure_notificationhub.defaultFormat must be one of template, apple, fcmv1, native"))
+	}
+	if c.NotificationHub.SendTimeoutSeconds < 1 {
+		errs = append(errs, fmt.Errorf("azure_notificationhub.sendTimeoutSeconds must be positive"))
+	}
+	switch c.Deduplication.Store {
+	case DeduplicationStoreMemory, DeduplicationStoreRedis:
+	default:
+		errs = append(errs, fmt.Errorf("deduplication.store must be one of memory, redis"))
+	}
+	if c.Deduplication.TTLSeconds < 1 || c.Deduplication.ClaimTTLSeconds < 1 {
+		errs = append(errs, fmt.Errorf("deduplication.ttlSeconds and deduplication.claimTtlSeconds must be positive"))
+	}
+	if c.Deduplication.Store == DeduplicationStoreMemory && c.Deduplication.MaxEntries < 1 {
+		errs = append(errs, fmt.Errorf("deduplication.maxEntries must be positive"))
+	}
+	if c.Deduplication.Store == DeduplicationStoreRedis && c.Deduplication.Redis.Addr == "" {
+		errs = append(errs, fmt.Errorf("deduplication.redis.addr is required with the redis store"))
+	}
+	rules := map[string]IdempotencyRule{"default": c.Idempotency.Default}
+	for name, rule := range c.Idempotency.Types {
+		rules["types."+name] = rule
+	}
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rule := rules[name]
+		switch {
+		case rule.Source == IdempotencySourceProperty && rule.Property == "":
+			errs = append(errs, fmt.Errorf("idempotency.%s.property is required with the property source", name))
+		case rule.Source != IdempotencySourceMessageID && rule.Source != IdempotencySourceProperty && rule.Source != IdempotencySourceContent:
+			errs = append(errs, fmt.Errorf("idempotency.%s.source must be one of messageId, property, content", name))
+		}
+	}
+	if _, err := time.LoadLocation(c.Scheduling.DefaultTimeZone); err != nil || c.Scheduling.DefaultTimeZone == "" {
+		errs = append(errs, fmt.Errorf("scheduling.defaultTimeZone must be an IANA time zone, e.g. Europe/Rome"))
+	}
+	if c.Scheduling.MinDelaySeconds < 0 || c.Scheduling.CancelTTLSeconds < 1 {
+		errs = append(errs, fmt.Errorf("scheduling.minDelaySeconds must not be negative and scheduling.cancelTtlSeconds must be positive"))
+	}
+	if c.Tracking.Enabled && (c.Tracking.TTLSeconds < 1 || c.Tracking.MaxRecords < 1) {
+		errs = append(errs, fmt.Errorf("tracking.ttlSeconds and tracking.maxRecords must be positive"))
+	}
+	if c.Tracking.PollOutcome && (c.Tracking.PollDelaySeconds < 0 || c.Tracking.PollIntervalSeconds < 1 || c.Tracking.MaxPollAttempts < 1) {
+		errs = append(errs, fmt.Errorf("tracking.pollDelaySeconds must not be negative, pollIntervalSeconds and maxPollAttempts must be positive"))
+	}
+	switch c.Telemetry.Exporter {
+	case TraceExporterNone, TraceExporterStdout, TraceExporterFile, TraceExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("telemetry.exporter must be one of none, stdout, file, otlp"))
+	}
+	if c.Telemetry.SampleRatio < 0 || c.Telemetry.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("telemetry.sampleRatio must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,110 @@
+// Package metrics defines the Prometheus collectors of the worker and serves them on /metrics
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Deduplication claim results
+const (
+	DeduplicationAcquired   = "acquired"
+	DeduplicationInProgress = "in_progress"
+	DeduplicationDuplicate  = "duplicate"
+	DeduplicationError      = "error"
+)
+
+// DeduplicationClaims counts deduplication claims by result
+var DeduplicationClaims = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "notification_deduplication_claims_total",
+	Help: "Deduplication claims by result.",
+}, []string{"result"})
+
+// Send results
+const (
+	SendSuccess = "success"
+	SendFailure = "failure"
+)
+
+// NoTopic labels the notifications whose tag expression addresses no topic, e.g. those sent to a user
+const NoTopic = "none"
+
+// NotificationSends counts the notifications sent to the hub, by topic and result; a notification addressing
+// several topics is counted once for each
+var NotificationSends = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "notification_sends_total",
+	Help: "Notifications sent to Notification Hub by topic and result.",
+}, []string{"topic", "result"})
+
+// NotificationSendDuration observes the time taken by the hub to accept a notification, all requests included
+var NotificationSendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "notification_send_duration_seconds",
+	Help:    "Time taken to send a notification to Notification Hub, by topic.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"topic"})
+
+// NotificationOutcomes counts the final states reported by the hub telemetry, e.g. Completed or NoTargetFound,
+// one per hub request
+var NotificationOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "notification_outcomes_total",
+	Help: "Final notification states reported by Notification Hub, by topic and state.",
+}, []string{"topic", "state"})
+
+// NotificationPlatformOutcomes adds up the per-device results reported by the hub telemetry, e.g. Successful
+// or InvalidToken
+var NotificationPlatformOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "notification_platform_outcomes_total",
+	Help: "Per-device push results reported by Notification Hub, by topic and outcome.",
+}, []string{"topic", "outcome"})
+
+// sizeTimeout bounds the store lookup done at every scrape
+const sizeTimeout = 2 * time.Second
+
+// ObserveDeduplicationEntries exposes the number of entries held by the deduplication store
+func ObserveDeduplicationEntries(size func(ctx context.Context) (int, error)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "notification_deduplication_entries",
+		Help: "Entries held by the deduplication store, claimed or sent.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), sizeTimeout)
+		defer cancel()
+		n, err := size(ctx)
+		if err != nil {
+			log.Printf("Error reading deduplication store size: %v", err)
+			return -1
+		}
+		return float64(n)
+	})
+}
+
+// Serve exposes /metrics on addr until ctx is done
+func Serve(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
+	return nil
+}
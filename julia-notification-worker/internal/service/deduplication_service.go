@@ -1,63 +1,144 @@
 package service
 
 import (
+	"container/list"
+	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
+
+	"julia-notification-worker/internal/config"
 )
 
-const deduplicationTTL = 24 * time.Hour
+// ClaimStatus is the outcome of Deduplicator.Claim
+type ClaimStatus int
+
+const (
+	// ClaimAcquired means the caller owns the key and must Confirm or Release it
+	ClaimAcquired ClaimStatus = iota
+	// ClaimInProgress means another delivery holds the key and has not finished yet
+	ClaimInProgress
+	// ClaimProcessed means the key was already sent within the TTL
+	ClaimProcessed
+)
 
-type DeduplicationService struct {
-	processedMessages map[string]time.Time
-	mu                sync.RWMutex
+// Deduplicator guarantees that a notification is sent once even when it is delivered several times,
+// possibly to different replicas at the same time. A key is claimed before sending, then confirmed once
+// sent or released if the send failed; a claim that is neither expires after the claim TTL.
+type Deduplicator interface {
+	Claim(ctx context.Context, key string) (ClaimStatus, error)
+	Confirm(ctx context.Context, key string) error
+	Release(ctx context.Context, key string) error
+	// Size returns the number of keys held, claimed or processed
+	Size(ctx context.Context) (int, error)
 }
 
-func NewDeduplicationService() *DeduplicationService {
-	s := &DeduplicationService{
-		processedMessages: make(map[string]time.Time),
+// NewDeduplicator creates the store selected by cfg.Store
+func NewDeduplicator(cfg config.DeduplicationConfig) (Deduplicator, error) {
+	switch cfg.Store {
+	case config.DeduplicationStoreMemory:
+		return NewMemoryDeduplicator(cfg), nil
+	case config.DeduplicationStoreRedis:
+		return NewRedisDeduplicator(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown deduplication store: %s", cfg.Store)
 	}
-	go s.startCleanupTask()
-	return s
 }
 
-// IsDuplicate checks if a messageId has already been processed within the TTL.
-func (s *DeduplicationService) IsDuplicate(messageID string) bool {
-	if messageID == "" {
-		return false
+type memoryEntry struct {
+	key       string
+	processed bool
+	expiresAt time.Time
+}
+
+// MemoryDeduplicator keeps keys in process memory, so it only protects a single replica and forgets
+// everything on restart. At most MaxEntries keys are kept, the oldest being evicted first.
+type MemoryDeduplicator struct {
+	ttl        time.Duration
+	claimTTL   time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // oldest first
+}
+
+func NewMemoryDeduplicator(cfg config.DeduplicationConfig) *MemoryDeduplicator {
+	s := &MemoryDeduplicator{
+		ttl:        time.Duration(cfg.TTLSeconds) * time.Second,
+		claimTTL:   time.Duration(cfg.ClaimTTLSeconds) * time.Second,
+		maxEntries: cfg.MaxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
 	}
+	go s.startCleanupTask()
+	return s
+}
 
-	s.mu.RLock()
-	processedAt, found := s.processedMessages[messageID]
-	s.mu.RUnlock()
+// Claim implements Deduplicator
+func (s *MemoryDeduplicator) Claim(_ context.Context, key string) (ClaimStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if !found {
-		return false
+	now := time.Now()
+	if el, found := s.entries[key]; found {
+		entry := el.Value.(*memoryEntry)
+		if now.Before(entry.expiresAt) {
+			if entry.processed {
+				return ClaimProcessed, nil
+			}
+			return ClaimInProgress, nil
+		}
+		s.remove(el)
 	}
 
-	// Double check TTL
-	if time.Since(processedAt) < deduplicationTTL {
-		log.Printf("Duplicate message detected: MessageId=%s, originallyProcessedAt=%v", messageID, processedAt)
-		return true
+	for s.order.Len() >= s.maxEntries {
+		s.remove(s.order.Front())
 	}
+	s.entries[key] = s.order.PushBack(&memoryEntry{key: key, expiresAt: now.Add(s.claimTTL)})
+	return ClaimAcquired, nil
+}
+
+// Confirm implements Deduplicator
+func (s *MemoryDeduplicator) Confirm(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Expired, should be removed by cleanup but we can ignore it here
-	return false
+	entry := &memoryEntry{key: key, processed: true, expiresAt: time.Now().Add(s.ttl)}
+	if el, found := s.entries[key]; found {
+		el.Value = entry
+		s.order.MoveToBack(el)
+		return nil
+	}
+	s.entries[key] = s.order.PushBack(entry)
+	return nil
 }
 
-// MarkAsProcessed marks a messageId as successfully processed.
-func (s *DeduplicationService) MarkAsProcessed(messageID string) {
-	if messageID == "" {
-		return
+// Release implements Deduplicator
+func (s *MemoryDeduplicator) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, found := s.entries[key]; found && !el.Value.(*memoryEntry).processed {
+		s.remove(el)
 	}
+	return nil
+}
 
+// Size implements Deduplicator
+func (s *MemoryDeduplicator) Size(context.Context) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.processedMessages[messageID] = time.Now()
-	log.Printf("Message marked as processed in deduplication cache: MessageId=%s", messageID)
+	return s.order.Len(), nil
+}
+
+func (s *MemoryDeduplicator) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*memoryEntry).key)
 }
 
-func (s *DeduplicationService) startCleanupTask() {
+func (s *MemoryDeduplicator) startCleanupTask() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
@@ -67,27 +148,22 @@ func (s *DeduplicationService) startCleanupTask() {
 	}
 }
 
-func (s *DeduplicationService) cleanup() {
+func (s *MemoryDeduplicator) cleanup() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	cutoff := time.Now().Add(-deduplicationTTL)
+	now := time.Now()
 	removed := 0
-
-	for id, processedAt := range s.processedMessages {
-		if processedAt.Before(cutoff) {
-			delete(s.processedMessages, id)
+	for el := s.order.Front(); el != nil; {
+		next := el.Next()
+		if !now.Before(el.Value.(*memoryEntry).expiresAt) {
+			s.remove(el)
 			removed++
 		}
+		el = next
 	}
 
 	if removed > 0 {
-		log.Printf("Cleaned up %d expired deduplication entries (older than %v)", removed, deduplicationTTL)
+		log.Printf("Cleaned up %d expired deduplication entries", removed)
 	}
 }
-
-func (s *DeduplicationService) GetCacheSize() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.processedMessages)
-}
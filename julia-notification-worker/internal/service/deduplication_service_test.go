@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"julia-notification-worker/internal/config"
+)
+
+func newTestDeduplicator(maxEntries int) *MemoryDeduplicator {
+	return NewMemoryDeduplicator(config.DeduplicationConfig{TTLSeconds: 60, ClaimTTLSeconds: 60, MaxEntries: maxEntries})
+}
+
+func TestMemoryDeduplicatorClaimConfirmRelease(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDeduplicator(10)
+
+	if status, _ := d.Claim(ctx, "m1"); status != ClaimAcquired {
+		t.Fatalf("first claim = %v, want acquired", status)
+	}
+	if status, _ := d.Claim(ctx, "m1"); status != ClaimInProgress {
+		t.Errorf("claim while held = %v, want in progress", status)
+	}
+
+	d.Release(ctx, "m1")
+	if status, _ := d.Claim(ctx, "m1"); status != ClaimAcquired {
+		t.Errorf("claim after release = %v, want acquired", status)
+	}
+
+	d.Confirm(ctx, "m1")
+	d.Release(ctx, "m1") // a late release must not forget a sent message
+	if status, _ := d.Claim(ctx, "m1"); status != ClaimProcessed {
+		t.Errorf("claim after confirm = %v, want processed", status)
+	}
+}
+
+func TestMemoryDeduplicatorEvictsOldest(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDeduplicator(2)
+	for _, key := range []string{"a", "b", "c"} {
+		d.Claim(ctx, key)
+		d.Confirm(ctx, key)
+	}
+	if size, _ := d.Size(ctx); size != 2 {
+		t.Errorf("size = %d, want 2", size)
+	}
+	if status, _ := d.Claim(ctx, "a"); status != ClaimAcquired {
+		t.Errorf("oldest entry should have been evicted, got %v", status)
+	}
+}
+
+func TestMemoryDeduplicatorSingleWinner(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDeduplicator(100)
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("m%d", i)
+		var acquired atomic.Int32
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if status, _ := d.Claim(ctx, key); status == ClaimAcquired {
+					acquired.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if acquired.Load() != 1 {
+			t.Errorf("%s claimed %d times", key, acquired.Load())
+		}
+	}
+}
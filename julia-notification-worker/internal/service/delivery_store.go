@@ -0,0 +1,122 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"julia-notification-worker/internal/config"
+)
+
+// Delivery statuses; the outcome of each request is the state reported by the hub telemetry
+const (
+	DeliverySent   = "sent"   // accepted by the hub
+	DeliveryFailed = "failed" // refused or unreachable, the message is retried or dead-lettered
+)
+
+// DeliveryRecord is what the worker knows about the last send of a message
+type DeliveryRecord struct {
+	MessageID  string            `json:"messageId"`
+	Topics     []string          `json:"topics,omitempty"`
+	Status     string            `json:"status"` // one of the Delivery* values
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"startedAt"`
+	DurationMs int64             `json:"durationMs"`
+	Requests   []DeliveryRequest `json:"requests"`
+}
+
+// DeliveryRequest is one request of a send, one per format and target group
+type DeliveryRequest struct {
+	Format         string `json:"format"`
+	StatusCode     int    `json:"statusCode,omitempty"`
+	TrackingID     string `json:"trackingId,omitempty"`     // TrackingId header, to quote to Azure support
+	NotificationID string `json:"notificationId,omitempty"` // from the Location header, Standard tier only
+	// Outcome is the final state from the hub telemetry, e.g. Completed or NoTargetFound, with the per-device
+	// results, e.g. Successful or InvalidToken, summed over the platforms
+	Outcome       string         `json:"outcome,omitempty"`
+	OutcomeCounts map[string]int `json:"outcomeCounts,omitempty"`
+}
+
+// DeliveryStore keeps the delivery records by message ID
+type DeliveryStore interface {
+	Save(ctx context.Context, record DeliveryRecord) error
+	// Get returns the record of messageID; found is false when there is none, or it expired
+	Get(ctx context.Context, messageID string) (record DeliveryRecord, found bool, err error)
+}
+
+// NewDeliveryStore creates the store selected by the deduplication store setting, Redis records using
+// tracking.keyPrefix on the deduplication Redis
+func NewDeliveryStore(dedup config.DeduplicationConfig, tracking config.TrackingConfig) (DeliveryStore, error) {
+	ttl := time.Duration(tracking.TTLSeconds) * time.Second
+	switch dedup.Store {
+	case config.DeduplicationStoreMemory:
+		return NewMemoryDeliveryStore(tracking.MaxRecords, ttl), nil
+	case config.DeduplicationStoreRedis:
+		return NewRedisDeliveryStore(dedup.Redis, tracking.KeyPrefix, ttl), nil
+	default:
+		return nil, fmt.Errorf("unknown delivery store: %s", dedup.Store)
+	}
+}
+
+type storedDelivery struct {
+	record    DeliveryRecord
+	expiresAt time.Time
+}
+
+// MemoryDeliveryStore keeps the last maxRecords records in process memory, lost on restart
+type MemoryDeliveryStore struct {
+	ttl        time.Duration
+	maxRecords int
+
+	mu      sync.Mutex
+	records map[string]*list.Element
+	order   *list.List // least recently saved first
+}
+
+func NewMemoryDeliveryStore(maxRecords int, ttl time.Duration) *MemoryDeliveryStore {
+	return &MemoryDeliveryStore{
+		ttl:        ttl,
+		maxRecords: maxRecords,
+		records:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Save implements DeliveryStore
+func (s *MemoryDeliveryStore) Save(_ context.Context, record DeliveryRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Requests is copied so that the record held cannot change under its readers, as if it were serialized
+	record.Requests = slices.Clone(record.Requests)
+	stored := &storedDelivery{record: record, expiresAt: time.Now().Add(s.ttl)}
+	if el, found := s.records[record.MessageID]; found {
+		el.Value = stored
+		s.order.MoveToBack(el)
+		return nil
+	}
+	for s.order.Len() >= s.maxRecords {
+		oldest := s.order.Front()
+		s.order.Remove(oldest)
+		delete(s.records, oldest.Value.(*storedDelivery).record.MessageID)
+	}
+	s.records[record.MessageID] = s.order.PushBack(stored)
+	return nil
+}
+
+// Get implements DeliveryStore
+func (s *MemoryDeliveryStore) Get(_ context.Context, messageID string) (DeliveryRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, found := s.records[messageID]
+	if !found || !time.Now().Before(el.Value.(*storedDelivery).expiresAt) {
+		return DeliveryRecord{}, false, nil
+	}
+	record := el.Value.(*storedDelivery).record
+	record.Requests = slices.Clone(record.Requests)
+	return record, true, nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"julia-notification-worker/internal/metrics"
+)
+
+// telemetryAPIVersion is the api-version of the notification telemetry endpoint
+const telemetryAPIVersion = "2016-07"
+
+// pendingStates are the telemetry states of a notification the hub is still sending
+var pendingStates = map[string]bool{"Enqueued": true, "Processing": true}
+
+// notificationID extracts the notification ID from the Location header of a send,
+// https://{namespace}.servicebus.windows.net/{hub}/messages/{id}?api-version=...; the hub returns it on the Standard tier only
+func notificationID(location string) string {
+	if location == "" {
+		return ""
+	}
+	u, err := url.Parse(location)
+	if err != nil || !strings.Contains(u.Path, "/messages/") {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+// topicLabels returns the metric labels of the topics of a delivery
+func topicLabels(topics []string) []string {
+	if len(topics) == 0 {
+		return []string{metrics.NoTopic}
+	}
+	return topics
+}
+
+// track counts a send attempt by topic and records it; sendErr is the error of the send, nil when accepted.
+// A record that cannot be saved is logged only: the notification is out, or will be retried, either way.
+func (s *NotificationHubService) track(ctx context.Context, delivery DeliveryRecord, sendErr error) {
+	duration := time.Since(delivery.StartedAt)
+	result := metrics.SendSuccess
+	if sendErr != nil {
+		result = metrics.SendFailure
+	}
+	for _, topic := range topicLabels(delivery.Topics) {
+		metrics.NotificationSends.WithLabelValues(topic, result).Inc()
+		if sendErr == nil {
+			metrics.NotificationSendDuration.WithLabelValues(topic).Observe(duration.Seconds())
+		}
+	}
+
+	if s.deliveries == nil {
+		return
+	}
+	delivery.Status = DeliverySent
+	if sendErr != nil {
+		delivery.Status = DeliveryFailed
+		delivery.Error = sendErr.Error()
+	}
+	delivery.DurationMs = duration.Milliseconds()
+	if err := s.deliveries.Save(ctx, delivery); err != nil {
+		log.Printf("Error saving delivery record: MessageId=%s, error=%v", delivery.MessageID, err)
+		return
+	}
+
+	if sendErr != nil || !s.tracking.PollOutcome {
+		return
+	}
+	for i, r := range delivery.Requests {
+		if r.NotificationID != "" {
+			s.polls.Add(1)
+			go s.pollOutcome(delivery.MessageID, i, r.NotificationID, delivery.Topics)
+		}
+	}
+}
+
+// pollOutcome reads the telemetry of a notification until the hub has finished sending it, then stores the
+// outcome in the delivery record and counts it. Polls stop on Close.
+func (s *NotificationHubService) pollOutcome(messageID string, index int, notificationID string, topics []string) {
+	defer s.polls.Done()
+
+	wait := time.Duration(s.tracking.PollDelaySeconds) * time.Second
+	for attempt := 1; attempt <= s.tracking.MaxPollAttempts; attempt++ {
+		select {
+		case <-s.pollCtx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = time.Duration(s.tracking.PollIntervalSeconds) * time.Second
+
+		details, err := s.readOutcome(s.pollCtx, notificationID)
+		if err != nil {
+			if s.pollCtx.Err() == nil {
+				log.Printf("Error reading outcome of notification %s (message %s): %v", notificationID, messageID, err)
+			}
+			continue
+		}
+		if pendingStates[details.State] {
+			continue
+		}
+
+		counts := details.counts()
+		for _, topic := range topicLabels(topics) {
+			metrics.NotificationOutcomes.WithLabelValues(topic, details.State).Inc()
+			for outcome, count := range counts {
+				metrics.NotificationPlatformOutcomes.WithLabelValues(topic, outcome).Add(float64(count))
+			}
+		}
+		s.saveOutcome(messageID, index, notificationID, details.State, counts)
+		return
+	}
+	log.Printf("Outcome of notification %s (message %s) still unknown after %d reads", notificationID, messageID, s.tracking.MaxPollAttempts)
+}
+
+// saveOutcome updates the request of the delivery record, unless the record was replaced by a later send
+func (s *NotificationHubService) saveOutcome(messageID string, index int, notificationID, state string, counts map[string]int) {
+	s.recordsMu.Lock()
+	defer s.recordsMu.Unlock()
+
+	record, found, err := s.deliveries.Get(s.pollCtx, messageID)
+	if err != nil {
+		log.Printf("Error reading delivery record: MessageId=%s, error=%v", messageID, err)
+		return
+	}
+	if !found || index >= len(record.Requests) || record.Requests[index].NotificationID != notificationID {
+		return
+	}
+	record.Requests[index].Outcome = state
+	record.Requests[index].OutcomeCounts = counts
+	if err := s.deliveries.Save(s.pollCtx, record); err != nil {
+		log.Printf("Error saving delivery record: MessageId=%s, error=%v", messageID, err)
+	}
+}
+
+type outcomeCounts struct {
+	Outcomes []struct {
+		Name  string `xml:"Name"`
+		Count int    `xml:"Count"`
+	} `xml:"Outcome"`
+}
+
+// notificationDetails is the NotificationDetails document of the telemetry endpoint, limited to the platforms
+// the worker sends to
+type notificationDetails struct {
+	State              string        `xml:"State"`
+	ApnsOutcomeCounts  outcomeCounts `xml:"ApnsOutcomeCounts"`
+	FcmOutcomeCounts   outcomeCounts `xml:"FcmOutcomeCounts"`
+	FcmV1OutcomeCounts outcomeCounts `xml:"FcmV1OutcomeCounts"`
+}
+
+// counts sums the per-device outcomes of all the platforms
+func (d notificationDetails) counts() map[string]int {
+	counts := make(map[string]int)
+	for _, platform := range []outcomeCounts{d.ApnsOutcomeCounts, d.FcmOutcomeCounts, d.FcmV1OutcomeCounts} {
+		for _, o := range platform.Outcomes {
+			counts[o.Name] += o.Count
+		}
+	}
+	return counts
+}
+
+// readOutcome reads the telemetry of a notification, with the current credentials
+func (s *NotificationHubService) readOutcome(ctx context.Context, notificationID string) (notificationDetails, error) {
+	endpoint, keyName, sasKey, err := parseConnectionString(*s.connectionString.Load())
+	if err != nil {
+		return notificationDetails{}, err
+	}
+	u := fmt.Sprintf("%s%s/messages/%s?api-version=%s", hubBaseURL(endpoint), s.cfg.HubName, url.PathEscape(notificationID), telemetryAPIVersion)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return notificationDetails{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", generateSasToken(u, keyName, sasKey))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return notificationDetails{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return notificationDetails{}, fmt.Errorf("notification hub returned error status: %s", resp.Status)
+	}
+
+	var details notificationDetails
+	if err := xml.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return notificationDetails{}, fmt.Errorf("failed to decode notification details: %w", err)
+	}
+	return details, nil
+}
+
+// Close stops the outcome polls and waits for them to return
+func (s *NotificationHubService) Close() {
+	s.cancelPolls()
+	s.polls.Wait()
+}
@@ -2,118 +2,236 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"julia-notification-worker/internal/config"
+	"julia-notification-worker/internal/metrics"
+	"julia-notification-worker/internal/telemetry"
 	"julia-notification-worker/internal/worker"
+	"julia-notification-worker/pkg/tagexpr"
+
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type NotificationHubService struct {
-	cfg                  config.NotificationHubConfig
-	httpClient           *http.Client
-	deduplicationService *DeduplicationService
+	cfg              config.NotificationHubConfig
+	enabled          atomic.Bool
+	connectionString atomic.Pointer[string]
+	httpClient       *http.Client
+	deduplicator     Deduplicator
+
+	// Delivery tracking, see delivery_tracking.go
+	deliveries  DeliveryStore // nil disables the records
+	tracking    config.TrackingConfig
+	recordsMu   sync.Mutex // serializes the outcome updates of a record
+	polls       sync.WaitGroup
+	pollCtx     context.Context
+	cancelPolls context.CancelFunc
 }
 
-func NewNotificationHubService(cfg config.NotificationHubConfig, dedupeService *DeduplicationService) *NotificationHubService {
-	return &NotificationHubService{
+// NewNotificationHubService creates the service; deliveries may be nil to keep no delivery records
+func NewNotificationHubService(cfg config.NotificationHubConfig, deduplicator Deduplicator, deliveries DeliveryStore, tracking config.TrackingConfig) *NotificationHubService {
+	s := &NotificationHubService{
 		cfg: cfg,
 		httpClient: &http.Client{
 			Timeout: time.Duration(cfg.SendTimeoutSeconds) * time.Second,
 		},
-		deduplicationService: dedupeService,
+		deduplicator: deduplicator,
+		deliveries:   deliveries,
+		tracking:     tracking,
 	}
+	s.enabled.Store(cfg.Enabled)
+	s.connectionString.Store(&cfg.ConnectionString)
+	s.pollCtx, s.cancelPolls = context.WithCancel(context.Background())
+	return s
+}
+
+// SetEnabled turns sending on or off while the worker runs; disabled sends are skipped and acknowledged
+func (s *NotificationHubService) SetEnabled(enabled bool) {
+	s.enabled.Store(enabled)
+}
+
+// SetConnectionString switches to rotated hub credentials; sends already in flight finish with the previous ones
+func (s *NotificationHubService) SetConnectionString(connectionString string) {
+	s.connectionString.Store(&connectionString)
 }
 
-// SendNotification sends a template notification to Azure Notification Hub.
-func (s *NotificationHubService) SendNotification(msg worker.NotificationMessage, messageId string) error {
-	if !s.cfg.Enabled {
+// SendNotification sends a notification to Azure Notification Hub, as a template or as native APNS and FCM v1 payloads.
+func (s *NotificationHubService) SendNotification(ctx context.Context, msg worker.NotificationMessage, messageId string) (err error) {
+	if !s.enabled.Load() {
 		log.Printf("Notification Hub is disabled, skipping notification: %s", messageId)
 		return nil
 	}
 
-	// Step 1: Deduplication check
-	if s.deduplicationService.IsDuplicate(messageId) {
-		return &worker.DuplicateMessageError{MessageID: messageId}
+	// Step 1: Deduplication claim, so that concurrent deliveries of the same notification do not both send
+	key := msg.IdempotencyKey
+	if key != "" {
+		if err := s.claim(ctx, key, messageId); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				if releaseErr := s.deduplicator.Release(ctx, key); releaseErr != nil {
+					log.Printf("Error releasing deduplication claim: MessageId=%s, IdempotencyKey=%s, error=%v", messageId, key, releaseErr)
+				}
+			}
+		}()
 	}
 
-	endpoint, _, _, err := s.parseConnectionString()
+	ctx, span := tracing.StartClientSpan(ctx, telemetry.Tracer(), "notificationhub send",
+		attribute.String("peer.service", "notification-hub"),
+		attribute.String("notificationhub.name", s.cfg.HubName),
+		semconv.MessagingMessageID(messageId),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	format := msg.Format
+	if format == "" {
+		format = s.cfg.DefaultFormat
+	}
+	span.SetAttributes(attribute.String("notificationhub.format", format))
+	requests, err := buildRequests(msg, format, messageId)
 	if err != nil {
-		return err
+		return worker.Permanent(worker.ReasonValidationFailed, err)
 	}
-
-	// Construct REST URL: https://{namespace}.servicebus.windows.net/{hubname}/messages/?api-version=2015-01
-	// For template notifications, we use the /messages/ endpoint.
-	baseUrl := strings.Replace(endpoint, "sb://", "https://", 1)
-	if !strings.HasSuffix(baseUrl, "/") {
-		baseUrl += "/"
+	targets, err := targetExpressions(msg)
+	if err != nil {
+		return worker.Permanent(worker.ReasonValidationFailed, err)
 	}
-	u := fmt.Sprintf("%s%s/messages/?api-version=2015-01", baseUrl, s.cfg.HubName)
 
-	sasToken, err := s.generateSasToken(u)
+	endpoint, keyName, sasKey, err := parseConnectionString(*s.connectionString.Load())
 	if err != nil {
-		return fmt.Errorf("failed to generate SAS token: %w", err)
+		return err
 	}
 
-	// Prepare payload (template properties)
-	properties := make(map[string]string)
-	properties["title"] = msg.Title
-	properties["message"] = msg.Body
-	properties["messageId"] = messageId
-
-	for k, v := range msg.Data {
-		properties[k] = fmt.Sprintf("%v", v)
+	// With several requests (native fan-out, many installations) a failure retries them all: a repeated alert
+	// is preferred to a lost one
+	span.SetAttributes(attribute.Int("notificationhub.requests", len(targets)*len(requests)))
+	delivery := DeliveryRecord{MessageID: messageId, Topics: tagexpr.Topics(msg.TagExpression), StartedAt: time.Now().UTC()}
+send:
+	for _, target := range targets {
+		for _, r := range requests {
+			sent, postErr := s.post(ctx, endpoint, keyName, sasKey, r, target)
+			delivery.Requests = append(delivery.Requests, sent)
+			if postErr != nil {
+				err = postErr
+				break send
+			}
+		}
 	}
-
-	body, err := json.Marshal(properties)
+	s.track(ctx, delivery, err)
 	if err != nil {
-		return fmt.Errorf("failed to marshal notification properties: %w", err)
+		return err
 	}
 
-	req, err := http.NewRequest("POST", u, bytes.NewBuffer(body))
+	// Step 5: Mark as processed
+	if key != "" {
+		if err := s.deduplicator.Confirm(ctx, key); err != nil {
+			// The notification is out: report success, a redelivery would only be caught by the claim TTL
+			log.Printf("Error confirming deduplication entry: MessageId=%s, IdempotencyKey=%s, error=%v", messageId, key, err)
+		}
+	}
+
+	return nil
+}
+
+// post sends one request to the hub messages endpoint and returns what the hub answered
+func (s *NotificationHubService) post(ctx context.Context, endpoint, keyName, sasKey string, r hubRequest, tagExpression string) (DeliveryRequest, error) {
+	sent := DeliveryRequest{Format: r.format}
+
+	// Construct REST URL: https://{namespace}.servicebus.windows.net/{hubname}/messages/?api-version=...
+	u := fmt.Sprintf("%s%s/messages/?api-version=%s", hubBaseURL(endpoint), s.cfg.HubName, r.apiVersion)
+
+	sasToken := generateSasToken(u, keyName, sasKey)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewBuffer(r.body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return sent, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", sasToken)
 	req.Header.Set("Content-Type", "application/json;charset=utf-8")
-	req.Header.Set("ServiceBusNotification-Format", "template")
+	req.Header.Set("ServiceBusNotification-Format", r.format)
+	for name, value := range r.headers {
+		req.Header.Set(name, value)
+	}
 
 	// Add TagExpression header if present
-	if msg.TagExpression != "" {
-		req.Header.Set("ServiceBusNotification-Tags", msg.TagExpression)
+	if tagExpression != "" {
+		req.Header.Set("ServiceBusNotification-Tags", tagExpression)
 	}
 
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send notification request: %w", err)
+		return sent, fmt.Errorf("failed to send %s notification request: %w", r.format, err)
 	}
 	defer resp.Body.Close()
+	sent.StatusCode = resp.StatusCode
+	sent.TrackingID = resp.Header.Get("TrackingId")
+	sent.NotificationID = notificationID(resp.Header.Get("Location"))
+	trace.SpanFromContext(ctx).SetAttributes(
+		semconv.HTTPResponseStatusCode(resp.StatusCode),
+		attribute.String("notificationhub.tracking_id", sent.TrackingID),
+	)
 
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("notification hub returned error status: %s", resp.Status)
+	switch {
+	case resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusOK:
+		return sent, nil
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge:
+		// The notification itself is invalid (malformed payload, payload too large): retrying cannot help
+		return sent, worker.Permanent(worker.ReasonRejectedByHub, fmt.Errorf("notification hub returned error status for %s: %s (tracking ID %s)", r.format, resp.Status, sent.TrackingID))
+	default:
+		return sent, fmt.Errorf("notification hub returned error status for %s: %s (tracking ID %s)", r.format, resp.Status, sent.TrackingID)
 	}
+}
 
-	// Step 5: Mark as processed
-	s.deduplicationService.MarkAsProcessed(messageId)
-
-	return nil
+// hubBaseURL turns the sb:// endpoint of the connection string into the https:// base of the REST API
+func hubBaseURL(endpoint string) string {
+	baseUrl := strings.Replace(endpoint, "sb://", "https://", 1)
+	if !strings.HasSuffix(baseUrl, "/") {
+		baseUrl += "/"
+	}
+	return baseUrl
 }
 
-func (s *NotificationHubService) generateSasToken(uri string) (string, error) {
-	_, keyName, key, err := s.parseConnectionString()
-	if err != nil {
-		return "", err
+// claim reserves the idempotency key for this delivery; a notification already sent is a DuplicateMessageError,
+// one being sent by another delivery a transient error so that it is retried once that delivery has finished
+func (s *NotificationHubService) claim(ctx context.Context, key, messageId string) error {
+	status, err := s.deduplicator.Claim(ctx, key)
+	switch {
+	case err != nil:
+		metrics.DeduplicationClaims.WithLabelValues(metrics.DeduplicationError).Inc()
+		return fmt.Errorf("failed to claim message for deduplication: %w", err)
+	case status == ClaimProcessed:
+		metrics.DeduplicationClaims.WithLabelValues(metrics.DeduplicationDuplicate).Inc()
+		return &worker.DuplicateMessageError{MessageID: messageId}
+	case status == ClaimInProgress:
+		metrics.DeduplicationClaims.WithLabelValues(metrics.DeduplicationInProgress).Inc()
+		return fmt.Errorf("message %s (idempotency key %s) is being sent by another delivery", messageId, key)
 	}
+	metrics.DeduplicationClaims.WithLabelValues(metrics.DeduplicationAcquired).Inc()
+	return nil
+}
 
+func generateSasToken(uri, keyName, key string) string {
 	// Target URI: convert to lowercase and URL-encode
 	targetUri := strings.ToLower(url.QueryEscape(uri))
 
@@ -133,11 +251,11 @@ func (s *NotificationHubService) generateSasToken(uri string) (string, error) {
 		expires,
 		keyName)
 
-	return token, nil
+	return token
 }
 
-func (s *NotificationHubService) parseConnectionString() (endpoint, keyName, key string, err error) {
-	parts := strings.Split(s.cfg.ConnectionString, ";")
+func parseConnectionString(connectionString string) (endpoint, keyName, key string, err error) {
+	parts := strings.Split(connectionString, ";")
 	for _, part := range parts {
 		if strings.HasPrefix(part, "Endpoint=") {
 			endpoint = strings.TrimPrefix(part, "Endpoint=")
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"julia-notification-worker/internal/config"
+	"julia-notification-worker/internal/worker"
+)
+
+type hubCall struct {
+	format     string
+	apiVersion string
+	pushType   string
+	tags       string
+	body       map[string]interface{}
+}
+
+// newTestHub starts a fake Notification Hub answering status and returns the service pointed at it
+func newTestHub(t *testing.T, status int) (*NotificationHubService, *[]hubCall) {
+	t.Helper()
+	var calls []hubCall
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "SharedAccessSignature ") {
+			t.Errorf("missing SAS token")
+		}
+		data, _ := io.ReadAll(r.Body)
+		call := hubCall{
+			format:     r.Header.Get("ServiceBusNotification-Format"),
+			apiVersion: r.URL.Query().Get("api-version"),
+			pushType:   r.Header.Get("apns-push-type"),
+			tags:       r.Header.Get("ServiceBusNotification-Tags"),
+		}
+		if err := json.Unmarshal(data, &call.body); err != nil {
+			t.Errorf("invalid payload %s: %v", data, err)
+		}
+		calls = append(calls, call)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	s := NewNotificationHubService(config.NotificationHubConfig{
+		ConnectionString:   "Endpoint=" + strings.Replace(server.URL, "https://", "sb://", 1) + "/;SharedAccessKeyName=test;SharedAccessKey=secret",
+		HubName:            "hub",
+		Enabled:            true,
+		SendTimeoutSeconds: 5,
+		DefaultFormat:      worker.FormatTemplate,
+	}, newTestDeduplicator(10), NewMemoryDeliveryStore(10, time.Hour), config.TrackingConfig{})
+	s.httpClient = server.Client()
+	return s, &calls
+}
+
+func TestSendNativeFanOut(t *testing.T) {
+	s, calls := newTestHub(t, http.StatusCreated)
+	badge := 3
+	msg := worker.NotificationMessage{
+		Title:          "Pagamento",
+		Body:           "Scadenza TARI",
+		Data:           map[string]interface{}{"amount": 12.5, "link": "julia://tari"},
+		Format:         worker.FormatNative,
+		Badge:          &badge,
+		Sound:          "default",
+		ThreadID:       "tari",
+		ChannelID:      "payments",
+		IdempotencyKey: "m1",
+	}
+	if err := s.SendNotification(context.Background(), msg, "m1"); err != nil {
+		t.Fatalf("SendNotification: %v", err)
+	}
+	if len(*calls) != 2 {
+		t.Fatalf("expected one request per platform, got %d", len(*calls))
+	}
+
+	apple := (*calls)[0]
+	aps := apple.body["aps"].(map[string]interface{})
+	if apple.format != "apple" || apple.pushType != "alert" || aps["thread-id"] != "tari" || aps["badge"] != 3.0 {
+		t.Errorf("unexpected APNS request: %+v", apple)
+	}
+	if apple.body["amount"] != 12.5 {
+		t.Errorf("APNS custom data should keep its JSON type, got %v", apple.body["amount"])
+	}
+
+	fcm := (*calls)[1]
+	message := fcm.body["message"].(map[string]interface{})
+	android := message["android"].(map[string]interface{})["notification"].(map[string]interface{})
+	data := message["data"].(map[string]interface{})
+	if fcm.format != "fcmv1" || android["channel_id"] != "payments" || data["amount"] != "12.5" || data["link"] != "julia://tari" {
+		t.Errorf("unexpected FCM v1 request: %+v", fcm)
+	}
+
+	if err := s.SendNotification(context.Background(), msg, "m1"); err == nil {
+		t.Error("a second send of the same key should be reported as duplicate")
+	}
+}
+
+func TestSendRejectedIsPermanentAndReleased(t *testing.T) {
+	s, calls := newTestHub(t, http.StatusBadRequest)
+	msg := worker.NotificationMessage{Title: "A", Body: "B", IdempotencyKey: "m2"}
+
+	err := s.SendNotification(context.Background(), msg, "m2")
+	if permanent, ok := worker.AsPermanent(err); !ok || permanent.Reason != worker.ReasonRejectedByHub {
+		t.Fatalf("err = %v, want permanent RejectedByHub", err)
+	}
+	if (*calls)[0].format != "template" || (*calls)[0].apiVersion != "2015-01" {
+		t.Errorf("messages without a format should use the default template format, got %+v", (*calls)[0])
+	}
+	if status, _ := s.deduplicator.Claim(context.Background(), "m2"); status != ClaimAcquired {
+		t.Errorf("a failed send must release its claim, got %v", status)
+	}
+}
+
+func TestSendToInstallationsAndUser(t *testing.T) {
+	s, calls := newTestHub(t, http.StatusCreated)
+	ids := make([]string, 25)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("inst-%02d", i)
+	}
+	if err := s.SendNotification(context.Background(), worker.NotificationMessage{Title: "A", Body: "B", InstallationIDs: ids}, "m3"); err != nil {
+		t.Fatalf("SendNotification: %v", err)
+	}
+	if len(*calls) != 2 || strings.Count((*calls)[0].tags, "$InstallationId:") != 20 || (*calls)[1].tags != "$InstallationId:{inst-20} || $InstallationId:{inst-21} || $InstallationId:{inst-22} || $InstallationId:{inst-23} || $InstallationId:{inst-24}" {
+		t.Errorf("installations should be sent in groups of 20, got %+v", *calls)
+	}
+
+	*calls = nil
+	msg := worker.NotificationMessage{Title: "A", Body: "B", UserID: "RSSMRA80A01H501U", TagExpression: "lang_it || lang_en"}
+	if err := s.SendNotification(context.Background(), msg, "m4"); err != nil {
+		t.Fatalf("SendNotification: %v", err)
+	}
+	if (*calls)[0].tags != "$UserId:{RSSMRA80A01H501U} && (lang_it || lang_en)" {
+		t.Errorf("unexpected user target %q", (*calls)[0].tags)
+	}
+}
+
+func TestDeliveryTracking(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			if !strings.HasSuffix(r.URL.Path, "/hub/messages/n-1") {
+				t.Errorf("unexpected telemetry path %s", r.URL.Path)
+			}
+			fmt.Fprint(w, `<NotificationDetails xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect">
+				<NotificationId>n-1</NotificationId><State>Completed</State>
+				<ApnsOutcomeCounts><Outcome><Name>Successful</Name><Count>3</Count></Outcome><Outcome><Name>InvalidToken</Name><Count>1</Count></Outcome></ApnsOutcomeCounts>
+				<FcmV1OutcomeCounts><Outcome><Name>Successful</Name><Count>2</Count></Outcome></FcmV1OutcomeCounts>
+			</NotificationDetails>`)
+			return
+		}
+		w.Header().Set("TrackingId", "t-1")
+		w.Header().Set("Location", "https://"+r.Host+"/hub/messages/n-1?api-version=2015-04")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+
+	deliveries := NewMemoryDeliveryStore(10, time.Hour)
+	s := NewNotificationHubService(config.NotificationHubConfig{
+		ConnectionString:   "Endpoint=" + strings.Replace(server.URL, "https://", "sb://", 1) + "/;SharedAccessKeyName=test;SharedAccessKey=secret",
+		HubName:            "hub",
+		Enabled:            true,
+		SendTimeoutSeconds: 5,
+		DefaultFormat:      worker.FormatTemplate,
+	}, newTestDeduplicator(10), deliveries, config.TrackingConfig{Enabled: true, PollOutcome: true, PollIntervalSeconds: 1, MaxPollAttempts: 1})
+	s.httpClient = server.Client()
+	defer s.Close()
+
+	msg := worker.NotificationMessage{Title: "A", Body: "B", TagExpression: "topic_tari && lang_it"}
+	if err := s.SendNotification(context.Background(), msg, "m1"); err != nil {
+		t.Fatalf("SendNotification: %v", err)
+	}
+
+	var record DeliveryRecord
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		record, _, _ = deliveries.Get(context.Background(), "m1")
+		if len(record.Requests) == 1 && record.Requests[0].Outcome != "" {
+			break
+		}
+	}
+	if record.Status != DeliverySent || len(record.Topics) != 1 || record.Topics[0] != "tari" {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	r := record.Requests[0]
+	if r.TrackingID != "t-1" || r.NotificationID != "n-1" || r.Outcome != "Completed" || r.OutcomeCounts["Successful"] != 5 || r.OutcomeCounts["InvalidToken"] != 1 {
+		t.Errorf("unexpected request record %+v", r)
+	}
+}
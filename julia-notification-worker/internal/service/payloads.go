@@ -0,0 +1,190 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"julia-notification-worker/internal/worker"
+	"julia-notification-worker/pkg/tagexpr"
+)
+
+// hubRequest is one notification to post to the hub in a given format
+type hubRequest struct {
+	format     string
+	apiVersion string
+	headers    map[string]string
+	body       []byte
+}
+
+// buildRequests returns the requests sending msg: one for the template and single native formats,
+// one per platform for the native fan-out
+func buildRequests(msg worker.NotificationMessage, format, messageId string) ([]hubRequest, error) {
+	switch format {
+	case worker.FormatTemplate:
+		req, err := templateRequest(msg, messageId)
+		return []hubRequest{req}, err
+	case worker.FormatApple:
+		req, err := appleRequest(msg, messageId)
+		return []hubRequest{req}, err
+	case worker.FormatFCMV1:
+		req, err := fcmV1Request(msg, messageId)
+		return []hubRequest{req}, err
+	case worker.FormatNative:
+		apple, err := appleRequest(msg, messageId)
+		if err != nil {
+			return nil, err
+		}
+		fcm, err := fcmV1Request(msg, messageId)
+		if err != nil {
+			return nil, err
+		}
+		return []hubRequest{apple, fcm}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification format: %s", format)
+	}
+}
+
+// templateRequest sends the flat properties the registered templates refer to, e.g. $(title)
+func templateRequest(msg worker.NotificationMessage, messageId string) (hubRequest, error) {
+	properties := make(map[string]string)
+	properties["title"] = msg.Title
+	properties["message"] = msg.Body
+	properties["messageId"] = messageId
+
+	for k, v := range msg.Data {
+		properties[k] = fmt.Sprintf("%v", v)
+	}
+
+	body, err := json.Marshal(properties)
+	if err != nil {
+		return hubRequest{}, fmt.Errorf("failed to marshal notification properties: %w", err)
+	}
+	return hubRequest{format: worker.FormatTemplate, apiVersion: "2015-01", body: body}, nil
+}
+
+type apsAlert struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type aps struct {
+	Alert    apsAlert `json:"alert"`
+	Badge    *int     `json:"badge,omitempty"`
+	Sound    string   `json:"sound,omitempty"`
+	ThreadID string   `json:"thread-id,omitempty"`
+}
+
+// appleRequest builds an APNS payload: the alert in aps, the data as custom top-level keys keeping their JSON types
+func appleRequest(msg worker.NotificationMessage, messageId string) (hubRequest, error) {
+	payload := make(map[string]interface{}, len(msg.Data)+2)
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	payload["messageId"] = messageId
+	payload["aps"] = aps{
+		Alert:    apsAlert{Title: msg.Title, Body: msg.Body},
+		Badge:    msg.Badge,
+		Sound:    msg.Sound,
+		ThreadID: msg.ThreadID,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return hubRequest{}, fmt.Errorf("failed to marshal APNS payload: %w", err)
+	}
+	return hubRequest{
+		format:     worker.FormatApple,
+		apiVersion: "2015-01",
+		headers: map[string]string{
+			"apns-push-type": "alert",
+			"apns-priority":  "10",
+		},
+		body: body,
+	}, nil
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmAndroidNotification struct {
+	ChannelID string `json:"channel_id,omitempty"`
+	Sound     string `json:"sound,omitempty"`
+}
+
+type fcmAndroid struct {
+	Notification fcmAndroidNotification `json:"notification"`
+}
+
+type fcmMessage struct {
+	Notification fcmNotification   `json:"notification"`
+	Android      *fcmAndroid       `json:"android,omitempty"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+// fcmV1Request builds an FCM HTTP v1 message. FCM data values must be strings, so non-string values
+// are sent as their JSON encoding rather than Go's formatting.
+func fcmV1Request(msg worker.NotificationMessage, messageId string) (hubRequest, error) {
+	data := make(map[string]string, len(msg.Data)+1)
+	for k, v := range msg.Data {
+		if s, ok := v.(string); ok {
+			data[k] = s
+			continue
+		}
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return hubRequest{}, fmt.Errorf("failed to encode data %s: %w", k, err)
+		}
+		data[k] = string(encoded)
+	}
+	data["messageId"] = messageId
+
+	message := fcmMessage{
+		Notification: fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:         data,
+	}
+	if msg.ChannelID != "" || msg.Sound != "" {
+		message.Android = &fcmAndroid{Notification: fcmAndroidNotification{
+			ChannelID: msg.ChannelID,
+			Sound:     msg.Sound,
+		}}
+	}
+
+	body, err := json.Marshal(map[string]fcmMessage{"message": message})
+	if err != nil {
+		return hubRequest{}, fmt.Errorf("failed to marshal FCM v1 payload: %w", err)
+	}
+	return hubRequest{format: worker.FormatFCMV1, apiVersion: "2023-10-01", body: body}, nil
+}
+
+// targetExpressions returns the ServiceBusNotification-Tags values addressing msg, one per request.
+// Installations are addressed through their $InstallationId tag, in groups of at most 20, and a user through
+// the $UserId tag of the installations registered for it; an empty expression broadcasts to the whole hub.
+func targetExpressions(msg worker.NotificationMessage) ([]string, error) {
+	switch {
+	case len(msg.InstallationIDs) > 0:
+		var expressions []string
+		for start := 0; start < len(msg.InstallationIDs); start += tagexpr.MaxTags {
+			ids := msg.InstallationIDs[start:min(start+tagexpr.MaxTags, len(msg.InstallationIDs))]
+			tags := make([]tagexpr.Expr, len(ids))
+			for i, id := range ids {
+				tags[i] = tagexpr.Installation(id)
+			}
+			expression, err := tagexpr.Any(tags...).Build()
+			if err != nil {
+				return nil, err
+			}
+			expressions = append(expressions, expression)
+		}
+		return expressions, nil
+	case msg.UserID != "" && msg.TagExpression != "":
+		expression, err := tagexpr.All(tagexpr.User(msg.UserID), tagexpr.Parse(msg.TagExpression)).Build()
+		return []string{expression}, err
+	case msg.UserID != "":
+		expression, err := tagexpr.User(msg.UserID).Build()
+		return []string{expression}, err
+	default:
+		return []string{msg.TagExpression}, tagexpr.Validate(msg.TagExpression)
+	}
+}
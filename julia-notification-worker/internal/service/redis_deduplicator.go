@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"julia-notification-worker/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// processedValue marks a key whose notification was sent; claims hold the owner token instead
+const processedValue = "processed"
+
+// releaseScript deletes a key only while it still holds the caller's claim, so that a late release
+// cannot drop a claim taken over by another replica after expiry, nor a confirmed key
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisDeduplicator keeps keys in Redis so that all replicas share them and they survive restarts.
+// Claims are taken with SET NX and a claim TTL, then overwritten with the processed marker and the full TTL.
+// Every key is also indexed in a sorted set scored by its expiry, which Size counts without scanning the keyspace.
+type RedisDeduplicator struct {
+	client   *redis.Client
+	prefix   string
+	index    string // sorted set of the keys, outside the prefix so that it cannot clash with one
+	ttl      time.Duration
+	claimTTL time.Duration
+	owner    string // claim token of this replica
+}
+
+func NewRedisDeduplicator(cfg config.DeduplicationConfig) *RedisDeduplicator {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+
+	token := make([]byte, 16)
+	rand.Read(token)
+
+	return &RedisDeduplicator{
+		client:   client,
+		prefix:   cfg.Redis.KeyPrefix,
+		index:    strings.TrimSuffix(cfg.Redis.KeyPrefix, ":") + "-index",
+		ttl:      time.Duration(cfg.TTLSeconds) * time.Second,
+		claimTTL: time.Duration(cfg.ClaimTTLSeconds) * time.Second,
+		owner:    "claim:" + hex.EncodeToString(token),
+	}
+}
+
+// Claim implements Deduplicator
+func (s *RedisDeduplicator) Claim(ctx context.Context, key string) (ClaimStatus, error) {
+	acquired, err := s.client.SetNX(ctx, s.prefix+key, s.owner, s.claimTTL).Result()
+	if err != nil {
+		return 0, err
+	}
+	if acquired {
+		s.indexKey(ctx, key, s.claimTTL)
+		return ClaimAcquired, nil
+	}
+
+	value, err := s.client.Get(ctx, s.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		// Released or expired in the meantime: let the next delivery claim it
+		return ClaimInProgress, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if value == processedValue {
+		return ClaimProcessed, nil
+	}
+	return ClaimInProgress, nil
+}
+
+// Confirm implements Deduplicator
+func (s *RedisDeduplicator) Confirm(ctx context.Context, key string) error {
+	if err := s.client.Set(ctx, s.prefix+key, processedValue, s.ttl).Err(); err != nil {
+		return err
+	}
+	s.indexKey(ctx, key, s.ttl)
+	return nil
+}
+
+// Release implements Deduplicator
+func (s *RedisDeduplicator) Release(ctx context.Context, key string) error {
+	deleted, err := releaseScript.Run(ctx, s.client, []string{s.prefix + key}, s.owner).Int()
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		s.client.ZRem(ctx, s.index, key)
+	}
+	return nil
+}
+
+// Size implements Deduplicator by counting the index entries that have not expired yet
+func (s *RedisDeduplicator) Size(ctx context.Context) (int, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	var count *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, s.index, "-inf", now)
+		count = pipe.ZCard(ctx, s.index)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
+
+// indexKey records key in the index until it expires in ttl, dropping the entries already expired. The index only
+// feeds the size metric, so a failure is not reported.
+func (s *RedisDeduplicator) indexKey(ctx context.Context, key string, ttl time.Duration) {
+	now := time.Now()
+	s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, s.index, redis.Z{Score: float64(now.Add(ttl).UnixMilli()), Member: key})
+		pipe.ZRemRangeByScore(ctx, s.index, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		pipe.Expire(ctx, s.index, s.ttl)
+		return nil
+	})
+}
+
+// Ping checks the connection to Redis
+func (s *RedisDeduplicator) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisDeduplicator) Close() error {
+	return s.client.Close()
+}
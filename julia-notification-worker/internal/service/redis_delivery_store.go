@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"julia-notification-worker/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisDeliveryStore keeps the records in Redis as JSON, shared by the replicas and kept across restarts
+type RedisDeliveryStore struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedisDeliveryStore(cfg config.RedisConfig, prefix string, ttl time.Duration) *RedisDeliveryStore {
+	return &RedisDeliveryStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+		}),
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// Save implements DeliveryStore
+func (s *RedisDeliveryStore) Save(ctx context.Context, record DeliveryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+record.MessageID, data, s.ttl).Err()
+}
+
+// Get implements DeliveryStore
+func (s *RedisDeliveryStore) Get(ctx context.Context, messageID string) (DeliveryRecord, bool, error) {
+	data, err := s.client.Get(ctx, s.prefix+messageID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return DeliveryRecord{}, false, nil
+	}
+	if err != nil {
+		return DeliveryRecord{}, false, err
+	}
+	var record DeliveryRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return DeliveryRecord{}, false, err
+	}
+	return record, true, nil
+}
+
+func (s *RedisDeliveryStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"julia-notification-worker/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cancelledValue marks a cancelled message; schedules hold the sequence number instead
+const cancelledValue = "cancelled"
+
+// saveScript records a schedule unless the message was cancelled in the meantime
+var saveScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// RedisScheduleStore keeps records in Redis, so that a cancellation received by any replica, or by a restarted
+// one, finds the schedule
+type RedisScheduleStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisScheduleStore(cfg config.RedisConfig, prefix string) *RedisScheduleStore {
+	return &RedisScheduleStore{
+		client: redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+		}),
+		prefix: prefix,
+	}
+}
+
+// Save implements ScheduleStore
+func (s *RedisScheduleStore) Save(ctx context.Context, messageID string, sequenceNumber int64, ttl time.Duration) (bool, error) {
+	saved, err := saveScript.Run(ctx, s.client, []string{s.prefix + messageID},
+		cancelledValue, strconv.FormatInt(sequenceNumber, 10), ttl.Milliseconds()).Int()
+	return saved == 1, err
+}
+
+// Cancel implements ScheduleStore, replacing the record with the cancellation in a single SET ... GET
+func (s *RedisScheduleStore) Cancel(ctx context.Context, messageID string, ttl time.Duration) (int64, bool, error) {
+	previous, err := s.client.SetArgs(ctx, s.prefix+messageID, cancelledValue, redis.SetArgs{TTL: ttl, Get: true}).Result()
+	if errors.Is(err, redis.Nil) || previous == cancelledValue {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	sequenceNumber, err := strconv.ParseInt(previous, 10, 64)
+	if err != nil {
+		return 0, false, nil // not a record of ours
+	}
+	return sequenceNumber, true, nil
+}
+
+// Cancelled implements ScheduleStore
+func (s *RedisScheduleStore) Cancelled(ctx context.Context, messageID string) (bool, error) {
+	value, err := s.client.Get(ctx, s.prefix+messageID).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return value == cancelledValue, err
+}
+
+func (s *RedisScheduleStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"julia-notification-worker/internal/config"
+)
+
+// ScheduleStore records the scheduled copies of deferred notifications and their cancellations, by message ID.
+// A cancellation is recorded even when no schedule is known yet, so that a message cancelled before it was
+// received, or whose copy could not be cancelled, is dropped when it shows up.
+type ScheduleStore interface {
+	// Save records the sequence number of the scheduled copy of messageID for ttl.
+	// It returns false, saving nothing, when messageID was cancelled.
+	Save(ctx context.Context, messageID string, sequenceNumber int64, ttl time.Duration) (bool, error)
+	// Cancel marks messageID cancelled for ttl and returns the sequence number recorded for it, if any
+	Cancel(ctx context.Context, messageID string, ttl time.Duration) (int64, bool, error)
+	// Cancelled reports whether messageID was cancelled
+	Cancelled(ctx context.Context, messageID string) (bool, error)
+}
+
+// NewScheduleStore creates the store selected by the deduplication store setting, Redis records using
+// scheduling.keyPrefix on the deduplication Redis
+func NewScheduleStore(dedup config.DeduplicationConfig, scheduling config.SchedulingConfig) (ScheduleStore, error) {
+	switch dedup.Store {
+	case config.DeduplicationStoreMemory:
+		return NewMemoryScheduleStore(), nil
+	case config.DeduplicationStoreRedis:
+		return NewRedisScheduleStore(dedup.Redis, scheduling.KeyPrefix), nil
+	default:
+		return nil, fmt.Errorf("unknown schedule store: %s", dedup.Store)
+	}
+}
+
+type scheduleRecord struct {
+	sequenceNumber int64
+	cancelled      bool
+	expiresAt      time.Time
+}
+
+// MemoryScheduleStore keeps records in process memory: cancellations only reach the replica that scheduled the
+// message, and are lost on restart together with the sequence numbers
+type MemoryScheduleStore struct {
+	mu      sync.Mutex
+	records map[string]scheduleRecord
+}
+
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{records: make(map[string]scheduleRecord)}
+}
+
+// Save implements ScheduleStore
+func (s *MemoryScheduleStore) Save(_ context.Context, messageID string, sequenceNumber int64, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+	if record, found := s.records[messageID]; found && record.cancelled {
+		return false, nil
+	}
+	s.records[messageID] = scheduleRecord{sequenceNumber: sequenceNumber, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// Cancel implements ScheduleStore
+func (s *MemoryScheduleStore) Cancel(_ context.Context, messageID string, ttl time.Duration) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+	record, found := s.records[messageID]
+	s.records[messageID] = scheduleRecord{cancelled: true, expiresAt: now.Add(ttl)}
+	if !found || record.cancelled {
+		return 0, false, nil
+	}
+	return record.sequenceNumber, true, nil
+}
+
+// Cancelled implements ScheduleStore
+func (s *MemoryScheduleStore) Cancelled(_ context.Context, messageID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, found := s.records[messageID]
+	return found && record.cancelled && time.Now().Before(record.expiresAt), nil
+}
+
+// expire drops the expired records; schedules are few, so a scan on every write is cheap
+func (s *MemoryScheduleStore) expire(now time.Time) {
+	for id, record := range s.records {
+		if !now.Before(record.expiresAt) {
+			delete(s.records, id)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryScheduleStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryScheduleStore()
+
+	if saved, _ := s.Save(ctx, "m1", 42, time.Hour); !saved {
+		t.Fatal("schedule should be saved")
+	}
+	if seq, scheduled, _ := s.Cancel(ctx, "m1", time.Hour); !scheduled || seq != 42 {
+		t.Errorf("Cancel = %d, %v, want the saved sequence number", seq, scheduled)
+	}
+	if cancelled, _ := s.Cancelled(ctx, "m1"); !cancelled {
+		t.Error("m1 should be cancelled")
+	}
+
+	// Cancelled before being scheduled
+	if _, scheduled, _ := s.Cancel(ctx, "m2", time.Hour); scheduled {
+		t.Error("m2 was never scheduled")
+	}
+	if saved, _ := s.Save(ctx, "m2", 43, time.Hour); saved {
+		t.Error("a cancelled message must not be scheduled")
+	}
+
+	s.Cancel(ctx, "m3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if cancelled, _ := s.Cancelled(ctx, "m3"); cancelled {
+		t.Error("the cancellation of m3 should have expired")
+	}
+}
@@ -4,72 +4,230 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
+	"time"
+
+	"julia-notification-worker/internal/config"
+	"julia-notification-worker/internal/telemetry"
+	"julia-notification-worker/internal/worker"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// minLockRenewalWait keeps lock renewals from spinning when a lock is about to expire
+const minLockRenewalWait = time.Second
+
 type MessageProcessor interface {
-	ProcessMessage(messageID string, contentType string, body []byte) error
+	ProcessMessage(ctx context.Context, messageID string, contentType string, properties map[string]any, body []byte) error
+}
+
+// Retrier sends a message that failed transiently back to the subscription, to be delivered again at retryAt.
+// attempts is the number of failed deliveries so far.
+type Retrier interface {
+	Retry(ctx context.Context, message *azservicebus.ReceivedMessage, attempts uint32, retryAt time.Time) error
+}
+
+// heldMessage is a received message waiting for or under processing, with its lock being renewed
+type heldMessage struct {
+	message     *azservicebus.ReceivedMessage
+	stopRenewal func()
 }
 
 type Client struct {
-	client   *azservicebus.Client
-	receiver *azservicebus.Receiver
-	handler  MessageProcessor
+	client           *azservicebus.Client
+	receiver         *azservicebus.Receiver
+	handler          MessageProcessor
+	retrier          Retrier
+	topicName        string
+	subscriptionName string
+	maxConcurrent    int
+	prefetch         int
+	maxLockRenewal   time.Duration
+	drainTimeout     time.Duration
+	maxDeliveryCount uint32
+	retryBackoff     time.Duration
+	maxRetryBackoff  time.Duration
 }
 
-func NewClient(connectionString, topicName, subscriptionName string, maxConcurrentCalls int, handler MessageProcessor) (*Client, error) {
-	client, err := azservicebus.NewClientFromConnectionString(connectionString, nil)
+func NewClient(cfg config.ServiceBusConfig, handler MessageProcessor, retrier Retrier) (*Client, error) {
+	client, err := azservicebus.NewClientFromConnectionString(cfg.ConnectionString, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create service bus client: %w", err)
 	}
 
-	receiver, err := client.NewReceiverForSubscription(topicName, subscriptionName, nil)
+	receiver, err := client.NewReceiverForSubscription(cfg.TopicName, cfg.SubscriptionName, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create receiver: %w", err)
 	}
 
 	return &Client{
-		client:   client,
-		receiver: receiver,
-		handler:  handler,
+		client:           client,
+		receiver:         receiver,
+		handler:          handler,
+		retrier:          retrier,
+		topicName:        cfg.TopicName,
+		subscriptionName: cfg.SubscriptionName,
+		maxConcurrent:    cfg.MaxConcurrentCalls,
+		prefetch:         cfg.PrefetchCount,
+		maxLockRenewal:   time.Duration(cfg.MaxLockRenewalSeconds) * time.Second,
+		drainTimeout:     time.Duration(cfg.DrainTimeoutSeconds) * time.Second,
+		maxDeliveryCount: uint32(cfg.MaxDeliveryCount),
+		retryBackoff:     time.Duration(cfg.RetryBackoffSeconds) * time.Second,
+		maxRetryBackoff:  time.Duration(cfg.MaxRetryBackoffSeconds) * time.Second,
 	}, nil
 }
 
+// Start receives messages until ctx is done and processes them on MaxConcurrentCalls goroutines.
+// Up to PrefetchCount more messages are received ahead and wait for a free goroutine under lock renewal.
+// When ctx is done it stops receiving, abandons the messages not yet started and waits up to DrainTimeout
+// for the in-flight ones, whose processing is only cancelled after that.
 func (c *Client) Start(ctx context.Context) error {
+	// Processing outlives ctx so that in-flight messages can finish during the drain
+	processCtx, cancelProcessing := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelProcessing()
+
+	// Each slot is a message held by the worker, either queued or being processed
+	slots := make(chan struct{}, c.maxConcurrent+c.prefetch)
+	queue := make(chan heldMessage, c.maxConcurrent+c.prefetch)
+
+	var workers sync.WaitGroup
+	for i := 0; i < c.maxConcurrent; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for held := range queue {
+				if ctx.Err() != nil {
+					held.stopRenewal()
+					c.abandon(processCtx, held.message)
+				} else {
+					c.processSingleMessage(processCtx, held)
+				}
+				<-slots
+			}
+		}()
+	}
+
+	c.receive(ctx, processCtx, slots, queue)
+	close(queue)
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(c.drainTimeout):
+		log.Printf("In-flight messages not finished after %v, cancelling them", c.drainTimeout)
+		cancelProcessing()
+		<-done
+	}
+	return nil
+}
+
+// receive fetches as many messages as there are free slots and queues them, until ctx is done.
+// Lock renewal starts on receipt and runs under processCtx, so that queued messages keep their lock too.
+func (c *Client) receive(ctx, processCtx context.Context, slots chan struct{}, queue chan<- heldMessage) {
 	for {
-		messages, err := c.receiver.ReceiveMessages(ctx, 10, nil)
+		// Wait for one free slot, then take the others that are free
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		free := 1
+	acquire:
+		for free < cap(slots) {
+			select {
+			case slots <- struct{}{}:
+				free++
+			default:
+				break acquire
+			}
+		}
+
+		messages, err := c.receiver.ReceiveMessages(ctx, free, nil)
+		for i := len(messages); i < free; i++ {
+			<-slots
+		}
 		if err != nil {
 			if ctx.Err() != nil {
-				return nil // Context cancelled
+				return // Context cancelled
 			}
 			log.Printf("Error receiving messages: %v", err)
 			continue
 		}
 
 		for _, message := range messages {
-			c.processSingleMessage(ctx, message)
+			queue <- heldMessage{message: message, stopRenewal: c.renewLock(processCtx, message)}
 		}
 	}
 }
 
-func (c *Client) processSingleMessage(ctx context.Context, message *azservicebus.ReceivedMessage) {
+// processSingleMessage processes a message and settles it: completed on success, dead-lettered on a permanent
+// failure or after MaxDeliveryCount deliveries, otherwise sent back through the Retrier for another attempt after
+// a backoff, or abandoned if that fails.
+func (c *Client) processSingleMessage(ctx context.Context, held heldMessage) {
+	message := held.message
 	messageID := message.MessageID
+
+	// A copy scheduled by a worker reading another subscription of the topic is not ours to send
+	if target, ok := message.ApplicationProperties[worker.ScheduledForProperty].(string); ok && target != c.subscriptionName {
+		held.stopRenewal()
+		log.Printf("Skipping message %s scheduled for subscription %s", messageID, target)
+		if err := c.receiver.CompleteMessage(ctx, message, nil); err != nil {
+			log.Printf("Error completing message %s: %v", messageID, err)
+		}
+		return
+	}
 	var contentType string
 	if message.ContentType != nil {
 		contentType = *message.ContentType
 	}
 
-	err := c.handler.ProcessMessage(messageID, contentType, message.Body)
+	// Continue the trace started by the publisher, if any
+	ctx = telemetry.ExtractMessage(ctx, message)
+	ctx, span := telemetry.Tracer().Start(ctx, fmt.Sprintf("%s process", c.topicName),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("servicebus"),
+			semconv.MessagingDestinationName(c.topicName),
+			semconv.MessagingServicebusDestinationSubscriptionName(c.subscriptionName),
+			semconv.MessagingMessageID(messageID),
+			semconv.MessagingServicebusMessageDeliveryCount(int(message.DeliveryCount)),
+		),
+	)
+
+	err := c.handler.ProcessMessage(ctx, messageID, contentType, message.ApplicationProperties, message.Body)
+	tracing.EndSpan(span, err)
 	if err != nil {
-		log.Printf("Error processing message %s: %v. Abandoning message for retry.", messageID, err)
-		// Explicitly abandon the message so it becomes available for another worker immediately
-		abandonErr := c.receiver.AbandonMessage(ctx, message, nil)
-		if abandonErr != nil {
-			log.Printf("Error abandoning message %s: %v", messageID, abandonErr)
+		if permanent, ok := worker.AsPermanent(err); ok {
+			held.stopRenewal()
+			c.deadLetter(ctx, message, permanent.Reason, err)
+			return
 		}
-		return
+		attempts := deliveries(message)
+		if attempts >= c.maxDeliveryCount {
+			held.stopRenewal()
+			c.deadLetter(ctx, message, worker.ReasonMaxDeliveryExceeded, err)
+			return
+		}
+
+		// Retry later through a scheduled copy so that a failing dependency is not retried in a tight loop,
+		// without holding the lock and the slot in the meantime
+		backoff := c.backoff(attempts)
+		if retryErr := c.retrier.Retry(ctx, message, attempts, time.Now().Add(backoff)); retryErr != nil {
+			log.Printf("Error processing message %s (delivery %d): %v. Scheduling the retry failed (%v), abandoning message.", messageID, attempts, err, retryErr)
+			held.stopRenewal()
+			c.abandon(ctx, message)
+			return
+		}
+		log.Printf("Error processing message %s (delivery %d): %v. Retry scheduled in %v.", messageID, attempts, err, backoff)
 	}
+	held.stopRenewal()
 
 	err = c.receiver.CompleteMessage(ctx, message, nil)
 	if err != nil {
@@ -77,6 +235,72 @@ func (c *Client) processSingleMessage(ctx context.Context, message *azservicebus
 	}
 }
 
+// deliveries returns the deliveries of message so far, including those of the messages it is a retry copy of
+func deliveries(message *azservicebus.ReceivedMessage) uint32 {
+	count := message.DeliveryCount
+	if prior, ok := message.ApplicationProperties[worker.RetryAttemptsProperty].(int64); ok && prior > 0 {
+		count += uint32(prior)
+	}
+	return count
+}
+
+// backoff returns the delay before a message that failed on the given delivery is delivered again
+func (c *Client) backoff(deliveryCount uint32) time.Duration {
+	backoff := c.retryBackoff
+	for i := uint32(1); i < deliveryCount && backoff < c.maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, c.maxRetryBackoff)
+}
+
+func (c *Client) deadLetter(ctx context.Context, message *azservicebus.ReceivedMessage, reason string, cause error) {
+	log.Printf("Dead-lettering message %s (delivery %d): reason=%s, error=%v", message.MessageID, message.DeliveryCount, reason, cause)
+	description := cause.Error()
+	err := c.receiver.DeadLetterMessage(ctx, message, &azservicebus.DeadLetterOptions{
+		Reason:           &reason,
+		ErrorDescription: &description,
+	})
+	if err != nil {
+		log.Printf("Error dead-lettering message %s: %v", message.MessageID, err)
+	}
+}
+
+func (c *Client) abandon(ctx context.Context, message *azservicebus.ReceivedMessage) {
+	if err := c.receiver.AbandonMessage(ctx, message, nil); err != nil {
+		log.Printf("Error abandoning message %s: %v", message.MessageID, err)
+	}
+}
+
+// renewLock keeps the peek-lock of message alive, renewing it halfway to its expiry, for at most MaxLockRenewal.
+// It returns the function that stops the renewal once the message is settled.
+func (c *Client) renewLock(ctx context.Context, message *azservicebus.ReceivedMessage) func() {
+	if c.maxLockRenewal <= 0 || message.LockedUntil == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.maxLockRenewal)
+	go func() {
+		for {
+			wait := max(time.Until(*message.LockedUntil)/2, minLockRenewalWait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			if err := c.receiver.RenewMessageLock(ctx, message, nil); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Error renewing lock of message %s: %v", message.MessageID, err)
+				}
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+// Close closes the receiver and the connection; call it after Start has returned
 func (c *Client) Close() error {
+	if err := c.receiver.Close(context.Background()); err != nil {
+		log.Printf("Error closing receiver: %v", err)
+	}
 	return c.client.Close(context.Background())
 }
@@ -0,0 +1,159 @@
+package servicebus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+
+	"julia-notification-worker/internal/config"
+	"julia-notification-worker/internal/service"
+	"julia-notification-worker/internal/telemetry"
+	"julia-notification-worker/internal/worker"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+)
+
+// Scheduler defers notifications by sending a copy back to the topic as a Service Bus scheduled message, which
+// stays invisible until sendAt. It implements worker.Scheduler.
+//
+// The copy, like the retry copies sent by Retry, is addressed to this worker's subscription through the scheduledFor
+// property: any other subscription of the topic must filter it out, e.g. with the SQL filter "scheduledFor IS NULL",
+// or it gets the notification twice.
+type Scheduler struct {
+	client       *azservicebus.Client
+	sender       *azservicebus.Sender
+	store        service.ScheduleStore
+	subscription string
+	cancelTTL    time.Duration
+}
+
+func NewScheduler(cfg config.ServiceBusConfig, scheduling config.SchedulingConfig, store service.ScheduleStore) (*Scheduler, error) {
+	client, err := azservicebus.NewClientFromConnectionString(cfg.ConnectionString, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service bus client: %w", err)
+	}
+
+	sender, err := client.NewSender(cfg.TopicName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sender: %w", err)
+	}
+
+	return &Scheduler{
+		client:       client,
+		sender:       sender,
+		store:        store,
+		subscription: cfg.SubscriptionName,
+		cancelTTL:    time.Duration(scheduling.CancelTTLSeconds) * time.Second,
+	}, nil
+}
+
+// Schedule implements worker.Scheduler. The copy gets the MessageID "<id>:scheduled" and carries the original in
+// originalMessageId, under which it is deduplicated and cancelled; a copy scheduled twice, e.g. when completing
+// the original failed, is sent once.
+func (s *Scheduler) Schedule(ctx context.Context, messageID string, sendAt time.Time, contentType string, properties map[string]any, body []byte) error {
+	cancelled, err := s.store.Cancelled(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		log.Printf("Cancelled notification not scheduled: MessageId=%s", messageID)
+		return nil
+	}
+
+	copyID := messageID + ":scheduled"
+	copyProperties := maps.Clone(properties) // the trace context is written into them too
+	if copyProperties == nil {
+		copyProperties = make(map[string]any)
+	}
+	copyProperties[worker.OriginalMessageIDProperty] = messageID
+	copyProperties[worker.ScheduledForProperty] = s.subscription
+	message := &azservicebus.Message{
+		MessageID:             &copyID,
+		ApplicationProperties: copyProperties,
+		Body:                  body,
+	}
+	if contentType != "" {
+		message.ContentType = &contentType
+	}
+	// The scheduled delivery continues the trace of the original
+	telemetry.InjectMessage(ctx, message)
+
+	sequenceNumbers, err := s.sender.ScheduleMessages(ctx, []*azservicebus.Message{message}, sendAt, nil)
+	if err != nil {
+		return err
+	}
+
+	saved, err := s.store.Save(ctx, messageID, sequenceNumbers[0], time.Until(sendAt)+s.cancelTTL)
+	if err != nil {
+		// The copy is out but cannot be cancelled by sequence number: the cancellation record still drops it
+		log.Printf("Error recording schedule of message %s: %v", messageID, err)
+		return nil
+	}
+	if !saved {
+		// Cancelled while scheduling
+		s.cancelScheduled(ctx, messageID, sequenceNumbers[0])
+	}
+	return nil
+}
+
+// Retry implements Retrier: it sends a copy of the failed message back to this worker's subscription, delivered
+// at retryAt, so that the worker holds neither the lock nor a slot while waiting. The copy gets the MessageID
+// "<retryOf>:retry:<attempts>" and counts the failed deliveries so far, its own DeliveryCount starting again from 1.
+func (s *Scheduler) Retry(ctx context.Context, message *azservicebus.ReceivedMessage, attempts uint32, retryAt time.Time) error {
+	properties := maps.Clone(message.ApplicationProperties)
+	if properties == nil {
+		properties = make(map[string]any)
+	}
+	retryOf, ok := properties[worker.RetryOfProperty].(string)
+	if !ok || retryOf == "" {
+		retryOf = message.MessageID
+		properties[worker.RetryOfProperty] = retryOf
+	}
+	properties[worker.RetryAttemptsProperty] = int64(attempts)
+	properties[worker.ScheduledForProperty] = s.subscription
+
+	copyID := fmt.Sprintf("%s:retry:%d", retryOf, attempts)
+	retry := &azservicebus.Message{
+		MessageID:             &copyID,
+		ContentType:           message.ContentType,
+		ApplicationProperties: properties,
+		Body:                  message.Body,
+	}
+	_, err := s.sender.ScheduleMessages(ctx, []*azservicebus.Message{retry}, retryAt, nil)
+	return err
+}
+
+// Cancel implements worker.Scheduler. The cancellation is recorded first and is what guarantees that the message
+// is not sent; cancelling the scheduled copy only avoids its delivery.
+func (s *Scheduler) Cancel(ctx context.Context, messageID string) error {
+	sequenceNumber, scheduled, err := s.store.Cancel(ctx, messageID, s.cancelTTL)
+	if err != nil {
+		return err
+	}
+	if scheduled {
+		s.cancelScheduled(ctx, messageID, sequenceNumber)
+	}
+	return nil
+}
+
+// Cancelled implements worker.Scheduler
+func (s *Scheduler) Cancelled(ctx context.Context, messageID string) (bool, error) {
+	return s.store.Cancelled(ctx, messageID)
+}
+
+func (s *Scheduler) cancelScheduled(ctx context.Context, messageID string, sequenceNumber int64) {
+	if err := s.sender.CancelScheduledMessages(ctx, []int64{sequenceNumber}, nil); err != nil {
+		// Already delivered or gone: the cancellation record drops it on delivery
+		log.Printf("Error cancelling scheduled message %s (sequence %d): %v", messageID, sequenceNumber, err)
+	}
+}
+
+// Close closes the sender and the connection
+func (s *Scheduler) Close() error {
+	if err := s.sender.Close(context.Background()); err != nil {
+		log.Printf("Error closing sender: %v", err)
+	}
+	return s.client.Close(context.Background())
+}
@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+	"go.opentelemetry.io/otel"
+)
+
+// ApplicationPropertiesCarrier adapts Service Bus application properties to the OpenTelemetry propagators
+type ApplicationPropertiesCarrier map[string]interface{}
+
+func (c ApplicationPropertiesCarrier) Get(key string) string {
+	if v, ok := c[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (c ApplicationPropertiesCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c ApplicationPropertiesCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectMessage writes the trace context of ctx into the message application properties
+func InjectMessage(ctx context.Context, message *azservicebus.Message) {
+	if message.ApplicationProperties == nil {
+		message.ApplicationProperties = make(map[string]interface{})
+	}
+	otel.GetTextMapPropagator().Inject(ctx, ApplicationPropertiesCarrier(message.ApplicationProperties))
+}
+
+// ExtractMessage returns ctx enriched with the trace context carried by the received message
+func ExtractMessage(ctx context.Context, message *azservicebus.ReceivedMessage) context.Context {
+	if message.ApplicationProperties == nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, ApplicationPropertiesCarrier(message.ApplicationProperties))
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"julia-notification-worker/internal/config"
+)
+
+// idempotencyKey returns the deduplication key of a message and the source it was taken from, following the
+// rule configured for its type. It falls back to the content hash when the rule yields no key.
+func (p *ServiceBusNotificationProcessor) idempotencyKey(messageType, messageID string, properties map[string]any, msg NotificationMessage) (key, source string) {
+	rule, ok := p.idempotency.Types[strings.ToLower(messageType)]
+	if !ok {
+		rule = p.idempotency.Default
+	}
+
+	switch rule.Source {
+	case config.IdempotencySourceMessageID:
+		if messageID != "" {
+			return messageID, config.IdempotencySourceMessageID
+		}
+	case config.IdempotencySourceProperty:
+		if value, ok := properties[rule.Property]; ok && value != nil && fmt.Sprint(value) != "" {
+			return "key:" + fmt.Sprint(value), config.IdempotencySourceProperty
+		}
+	}
+	return contentKey(messageType, msg), config.IdempotencySourceContent
+}
+
+// contentKey hashes the fields that make a notification what it is; json.Marshal sorts map keys,
+// so equal data always gives the same key
+func contentKey(messageType string, msg NotificationMessage) string {
+	var sendAt string
+	if !msg.SendAt.IsZero() {
+		sendAt = msg.SendAt.UTC().Format(time.RFC3339) // the same text at another time is another notification
+	}
+	// The same text sent to other recipients, or in another format, is another notification
+	installationIDs := slices.Clone(msg.InstallationIDs)
+	slices.Sort(installationIDs)
+	data, _ := json.Marshal(struct {
+		Type            string                 `json:"type"`
+		Title           string                 `json:"title"`
+		Body            string                 `json:"body"`
+		TagExpression   string                 `json:"tagExpression"`
+		Data            map[string]interface{} `json:"data"`
+		SendAt          string                 `json:"sendAt,omitempty"`
+		InstallationIDs []string               `json:"installationIds,omitempty"`
+		UserID          string                 `json:"userId,omitempty"`
+		Format          string                 `json:"format,omitempty"`
+	}{strings.ToLower(messageType), msg.Title, msg.Body, msg.TagExpression, msg.Data, sendAt, installationIDs, msg.UserID, msg.Format})
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -1,7 +1,9 @@
 package worker
 
 import (
+	"errors"
 	"fmt"
+	"time"
 )
 
 // NotificationMessage represents a notification message to be sent to Azure Notification Hub.
@@ -10,8 +12,30 @@ type NotificationMessage struct {
 	Body          string                 `json:"body" validate:"required"`
 	TagExpression string                 `json:"tagExpression,omitempty"`
 	Data          map[string]interface{} `json:"data,omitempty"`
+	// Direct targets, used instead of a tag expression crafted by the producer
+	InstallationIDs []string `json:"installationIds,omitempty"` // sent to these installations only
+	UserID          string   `json:"userId,omitempty"`          // sent to the installations registered with this user ID
+	// Format is one of the Format* values; empty uses the hub default
+	Format string `json:"format,omitempty"`
+	// Native payload options, ignored by templates
+	Badge     *int   `json:"badge,omitempty"`     // iOS badge count
+	Sound     string `json:"sound,omitempty"`     // iOS and Android sound
+	ThreadID  string `json:"threadId,omitempty"`  // iOS notification grouping
+	ChannelID string `json:"channelId,omitempty"` // Android notification channel
+	// SendAt defers the notification; zero sends it at once
+	SendAt time.Time `json:"sendAt,omitempty"`
+	// IdempotencyKey identifies the notification for deduplication; empty disables it
+	IdempotencyKey string `json:"-"`
 }
 
+// Notification formats, sent as ServiceBusNotification-Format
+const (
+	FormatTemplate = "template" // properties for the templates registered by the apps
+	FormatApple    = "apple"    // native APNS payload
+	FormatFCMV1    = "fcmv1"    // native FCM HTTP v1 payload
+	FormatNative   = "native"   // both native payloads, one request per platform
+)
+
 type DuplicateMessageError struct {
 	MessageID string
 }
@@ -19,3 +43,38 @@ type DuplicateMessageError struct {
 func (e *DuplicateMessageError) Error() string {
 	return fmt.Sprintf("duplicate message detected: %s", e.MessageID)
 }
+
+// Dead-letter reasons, set as DeadLetterReason on the messages moved to the dead-letter queue
+const (
+	ReasonInvalidPayload      = "InvalidPayload"      // the body is not a notification JSON document
+	ReasonValidationFailed    = "ValidationFailed"    // a required field is missing
+	ReasonRejectedByHub       = "RejectedByHub"       // Notification Hub refused the notification itself
+	ReasonMaxDeliveryExceeded = "MaxDeliveryExceeded" // transient failures persisted over all the attempts
+)
+
+// PermanentError reports a message that will never be processed successfully, whatever the number of attempts.
+// Such messages are dead-lettered at once; any other error is considered transient and retried.
+type PermanentError struct {
+	Reason string // one of the Reason* values
+	Err    error
+}
+
+func (e *PermanentError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err in a PermanentError with the given reason
+func Permanent(reason string, err error) error {
+	return &PermanentError{Reason: reason, Err: err}
+}
+
+// AsPermanent returns the PermanentError in the chain of err, if any
+func AsPermanent(err error) (*PermanentError, bool) {
+	var permanent *PermanentError
+	ok := errors.As(err, &permanent)
+	return permanent, ok
+}
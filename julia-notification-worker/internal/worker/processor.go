@@ -1,64 +1,146 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
+	"time"
+
+	"julia-notification-worker/internal/config"
+	"julia-notification-worker/pkg/tagexpr"
 )
 
 // ServiceBusNotificationDto represents the raw JSON from Service Bus
 type ServiceBusNotificationDto struct {
-	Title         string                 `json:"title"`
-	Body          string                 `json:"body"`
-	Message       string                 `json:"message"` // Fallback field
-	TagExpression string                 `json:"tagExpression"`
-	Data          map[string]interface{} `json:"data"`
+	Title           string                 `json:"title"`
+	Body            string                 `json:"body"`
+	Message         string                 `json:"message"` // Fallback field
+	Type            string                 `json:"type"`    // selects the idempotency rule
+	InstallationIDs []string               `json:"installationIds"`
+	UserID          string                 `json:"userId"`
+	Format          string                 `json:"format"`
+	Badge           *int                   `json:"badge"`
+	Sound           string                 `json:"sound"`
+	ThreadID        string                 `json:"threadId"`
+	ChannelID       string                 `json:"channelId"`
+	TagExpression   string                 `json:"tagExpression"`
+	Data            map[string]interface{} `json:"data"`
+	SendAt          string                 `json:"sendAt"`          // RFC 3339, or local time in TimeZone
+	TimeZone        string                 `json:"timeZone"`        // IANA zone, e.g. Europe/Rome
+	CancelMessageID string                 `json:"cancelMessageId"` // makes the message a cancellation of another one
 }
 
 type NotificationHubService interface {
-	SendNotification(msg NotificationMessage, messageId string) error
+	SendNotification(ctx context.Context, msg NotificationMessage, messageId string) error
 }
 
 type ServiceBusNotificationProcessor struct {
 	notificationHubService NotificationHubService
+	scheduler              Scheduler
+	idempotency            config.IdempotencyConfig
+	location               *time.Location // of sendAt values without offset nor time zone
+	minDelay               time.Duration
 }
 
-func NewServiceBusNotificationProcessor(hubService NotificationHubService) *ServiceBusNotificationProcessor {
+func NewServiceBusNotificationProcessor(hubService NotificationHubService, scheduler Scheduler, idempotency config.IdempotencyConfig, scheduling config.SchedulingConfig) *ServiceBusNotificationProcessor {
+	location, err := time.LoadLocation(scheduling.DefaultTimeZone)
+	if err != nil {
+		log.Printf("Unknown time zone %q, using UTC: %v", scheduling.DefaultTimeZone, err)
+		location = time.UTC
+	}
 	return &ServiceBusNotificationProcessor{
 		notificationHubService: hubService,
+		scheduler:              scheduler,
+		idempotency:            idempotency,
+		location:               location,
+		minDelay:               time.Duration(scheduling.MinDelaySeconds) * time.Second,
 	}
 }
 
-func (p *ServiceBusNotificationProcessor) ProcessMessage(messageID string, contentType string, body []byte) error {
-	log.Printf("Received message from Service Bus: MessageId=%s", messageID)
+func (p *ServiceBusNotificationProcessor) ProcessMessage(ctx context.Context, messageID string, contentType string, properties map[string]any, body []byte) error {
+	// A scheduled copy stands for its original, for deduplication and cancellation alike
+	scheduledCopy := false
+	if original, ok := properties[OriginalMessageIDProperty].(string); ok && original != "" {
+		log.Printf("Received scheduled copy from Service Bus: MessageId=%s, OriginalMessageId=%s", messageID, original)
+		messageID, scheduledCopy = original, true
+	} else if retryOf, ok := properties[RetryOfProperty].(string); ok && retryOf != "" {
+		log.Printf("Received retry copy from Service Bus: MessageId=%s, RetryOf=%s", messageID, retryOf)
+		messageID = retryOf
+	} else {
+		log.Printf("Received message from Service Bus: MessageId=%s", messageID)
+	}
 
 	var dto ServiceBusNotificationDto
 	if err := json.Unmarshal(body, &dto); err != nil {
 		// If it's not JSON, we might want to handle it as raw string in the future,
 		// but for now, we follow the Java logic which expects JSON for complex notifications.
-		return fmt.Errorf("failed to deserialize message: %w", err)
+		return Permanent(ReasonInvalidPayload, fmt.Errorf("failed to deserialize message: %w", err))
+	}
+
+	if dto.CancelMessageID != "" {
+		if err := p.scheduler.Cancel(ctx, dto.CancelMessageID); err != nil {
+			return fmt.Errorf("failed to cancel message %s: %w", dto.CancelMessageID, err)
+		}
+		log.Printf("Notification cancelled: MessageId=%s, by %s", dto.CancelMessageID, messageID)
+		return nil
 	}
 
 	// Preprocess DTO (fallback logic)
 	p.preprocessDto(&dto)
 
+	sendAt, err := parseSendAt(dto.SendAt, dto.TimeZone, p.location)
+	if err != nil {
+		return Permanent(ReasonValidationFailed, fmt.Errorf("validation failed: %w", err))
+	}
+
 	// Map DTO to internal model
 	notification := NotificationMessage{
-		Title:         dto.Title,
-		Body:          dto.Body,
-		TagExpression: dto.TagExpression,
-		Data:          dto.Data,
+		Title:           dto.Title,
+		Body:            dto.Body,
+		TagExpression:   dto.TagExpression,
+		Data:            dto.Data,
+		InstallationIDs: dto.InstallationIDs,
+		UserID:          dto.UserID,
+		Format:          strings.ToLower(dto.Format),
+		Badge:           dto.Badge,
+		Sound:           dto.Sound,
+		ThreadID:        dto.ThreadID,
+		ChannelID:       dto.ChannelID,
+		SendAt:          sendAt,
 	}
 
 	// Validate
 	if err := p.validateNotificationMessage(&notification); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+		return Permanent(ReasonValidationFailed, fmt.Errorf("validation failed: %w", err))
 	}
 
-	log.Printf("Sending notification to Hub: MessageId=%s, Title=%s, TagExpression=%s", messageID, notification.Title, notification.TagExpression)
+	key, source := p.idempotencyKey(dto.Type, messageID, properties, notification)
+	notification.IdempotencyKey = key
+
+	if !notification.SendAt.IsZero() {
+		// Deferred: the scheduled copy comes back here at sendAt and is sent, even if the clocks disagree
+		if !scheduledCopy && time.Until(notification.SendAt) > p.minDelay {
+			if err := p.scheduler.Schedule(ctx, messageID, notification.SendAt, contentType, properties, body); err != nil {
+				return fmt.Errorf("failed to schedule notification: %w", err)
+			}
+			log.Printf("Notification scheduled: MessageId=%s, SendAt=%s", messageID, notification.SendAt.Format(time.RFC3339))
+			return nil
+		}
+		cancelled, err := p.scheduler.Cancelled(ctx, messageID)
+		if err != nil {
+			return fmt.Errorf("failed to check cancellation: %w", err)
+		}
+		if cancelled {
+			log.Printf("Cancelled notification skipped, will complete: MessageId=%s", messageID)
+			return nil
+		}
+	}
+
+	log.Printf("Sending notification to Hub: MessageId=%s, IdempotencyKey=%s (%s), Title=%s, TagExpression=%s", messageID, key, source, notification.Title, notification.TagExpression)
 
-	if err := p.notificationHubService.SendNotification(notification, messageID); err != nil {
+	if err := p.notificationHubService.SendNotification(ctx, notification, messageID); err != nil {
 		// Detect duplicate message error (defined in worker/model.go now)
 		if _, ok := err.(*DuplicateMessageError); ok {
 			log.Printf("Duplicate message skipped, will complete: MessageId=%s, reason=%v", messageID, err)
@@ -87,5 +169,31 @@ func (p *ServiceBusNotificationProcessor) validateNotificationMessage(msg *Notif
 	if strings.TrimSpace(msg.Body) == "" {
 		return fmt.Errorf("notification body is required")
 	}
+	if len(msg.InstallationIDs) > 0 && (msg.UserID != "" || msg.TagExpression != "") {
+		return fmt.Errorf("installationIds cannot be combined with userId or tagExpression")
+	}
+	for _, id := range msg.InstallationIDs {
+		if _, err := tagexpr.Installation(id).Build(); err != nil {
+			return fmt.Errorf("invalid installation ID %q: only letters, digits and _ @ # . : - are allowed", id)
+		}
+	}
+	// The user tag counts towards the Hub limits of the expression it is combined with
+	var target tagexpr.Expr
+	switch {
+	case msg.UserID != "" && msg.TagExpression != "":
+		target = tagexpr.All(tagexpr.User(msg.UserID), tagexpr.Parse(msg.TagExpression))
+	case msg.UserID != "":
+		target = tagexpr.User(msg.UserID)
+	case msg.TagExpression != "":
+		target = tagexpr.Parse(msg.TagExpression)
+	}
+	if _, err := target.Build(); err != nil {
+		return err
+	}
+	switch msg.Format {
+	case "", FormatTemplate, FormatApple, FormatFCMV1, FormatNative:
+	default:
+		return fmt.Errorf("notification format must be one of template, apple, fcmv1, native")
+	}
 	return nil
 }
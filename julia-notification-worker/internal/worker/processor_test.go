@@ -0,0 +1,218 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"julia-notification-worker/internal/config"
+)
+
+type stubHub struct {
+	err  error
+	sent *NotificationMessage
+}
+
+func (h stubHub) SendNotification(_ context.Context, msg NotificationMessage, _ string) error {
+	if h.sent != nil {
+		*h.sent = msg
+	}
+	return h.err
+}
+
+// stubScheduler records schedules and cancellations in memory
+type stubScheduler struct {
+	scheduled map[string]time.Time
+	cancelled map[string]bool
+}
+
+func newStubScheduler() *stubScheduler {
+	return &stubScheduler{scheduled: make(map[string]time.Time), cancelled: make(map[string]bool)}
+}
+
+func (s *stubScheduler) Schedule(_ context.Context, messageID string, sendAt time.Time, _ string, _ map[string]any, _ []byte) error {
+	s.scheduled[messageID] = sendAt
+	return nil
+}
+
+func (s *stubScheduler) Cancel(_ context.Context, messageID string) error {
+	s.cancelled[messageID] = true
+	return nil
+}
+
+func (s *stubScheduler) Cancelled(_ context.Context, messageID string) (bool, error) {
+	return s.cancelled[messageID], nil
+}
+
+var (
+	defaultIdempotency = config.IdempotencyConfig{Default: config.IdempotencyRule{Source: config.IdempotencySourceMessageID}}
+	defaultScheduling  = config.SchedulingConfig{DefaultTimeZone: "Europe/Rome", MinDelaySeconds: 60}
+)
+
+func TestProcessMessageClassifiesErrors(t *testing.T) {
+	valid := []byte(`{"title":"Avviso","message":"Testo"}`)
+	tests := []struct {
+		name   string
+		body   []byte
+		hubErr error
+		reason string // expected permanent reason, empty for transient or success
+		fails  bool
+	}{
+		{name: "malformed json", body: []byte(`{"title":`), reason: ReasonInvalidPayload, fails: true},
+		{name: "missing title", body: []byte(`{"body":"Testo"}`), reason: ReasonValidationFailed, fails: true},
+		{name: "installations with tags", body: []byte(`{"title":"A","body":"B","installationIds":["i1"],"tagExpression":"x"}`), reason: ReasonValidationFailed, fails: true},
+		{name: "invalid user id", body: []byte(`{"title":"A","body":"B","userId":"a b"}`), reason: ReasonValidationFailed, fails: true},
+		{name: "invalid tag expression", body: []byte(`{"title":"A","body":"B","tagExpression":"lang_it && (topic_tari"}`), reason: ReasonValidationFailed, fails: true},
+		{name: "user over tag limit", body: []byte(`{"title":"A","body":"B","userId":"u1","tagExpression":"a || b || c || d || e || f"}`), reason: ReasonValidationFailed, fails: true},
+		{name: "invalid sendAt", body: []byte(`{"title":"A","body":"B","sendAt":"tomorrow at 7"}`), reason: ReasonValidationFailed, fails: true},
+		{name: "unknown time zone", body: []byte(`{"title":"A","body":"B","sendAt":"2030-01-02T07:00","timeZone":"Mars/Olympus"}`), reason: ReasonValidationFailed, fails: true},
+		{name: "hub unavailable", body: valid, hubErr: errors.New("503 Service Unavailable"), fails: true},
+		{name: "hub rejects", body: valid, hubErr: Permanent(ReasonRejectedByHub, errors.New("400 Bad Request")), reason: ReasonRejectedByHub, fails: true},
+		{name: "duplicate", body: valid, hubErr: &DuplicateMessageError{MessageID: "m1"}},
+		{name: "sent", body: valid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewServiceBusNotificationProcessor(stubHub{err: tt.hubErr}, newStubScheduler(), defaultIdempotency, defaultScheduling).ProcessMessage(context.Background(), "m1", "application/json", nil, tt.body)
+			if (err != nil) != tt.fails {
+				t.Fatalf("err = %v, want failure %v", err, tt.fails)
+			}
+			permanent, ok := AsPermanent(err)
+			if tt.reason == "" && ok {
+				t.Errorf("error should be transient, got reason %s", permanent.Reason)
+			}
+			if tt.reason != "" && (!ok || permanent.Reason != tt.reason) {
+				t.Errorf("err = %v, want permanent with reason %s", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	idempotency := config.IdempotencyConfig{
+		Default: config.IdempotencyRule{Source: config.IdempotencySourceMessageID},
+		Types: map[string]config.IdempotencyRule{
+			"reminder":  {Source: config.IdempotencySourceProperty, Property: "idempotencyKey"},
+			"marketing": {Source: config.IdempotencySourceContent},
+		},
+	}
+	key := func(messageID string, properties map[string]any, body string) string {
+		var sent NotificationMessage
+		p := NewServiceBusNotificationProcessor(stubHub{sent: &sent}, newStubScheduler(), idempotency, defaultScheduling)
+		if err := p.ProcessMessage(context.Background(), messageID, "application/json", properties, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		return sent.IdempotencyKey
+	}
+
+	if got := key("m1", nil, `{"title":"A","body":"B"}`); got != "m1" {
+		t.Errorf("default rule should use the message ID, got %q", got)
+	}
+	if got := key("", nil, `{"title":"A","body":"B"}`); !strings.HasPrefix(got, "sha256:") {
+		t.Errorf("an empty message ID should fall back to the content hash, got %q", got)
+	}
+	if got := key("m1", map[string]any{"idempotencyKey": "r-42"}, `{"type":"Reminder","title":"A","body":"B"}`); got != "key:r-42" {
+		t.Errorf("reminder should use the property, got %q", got)
+	}
+
+	first := key("m1", nil, `{"type":"marketing","title":"A","body":"B","data":{"x":1,"y":2}}`)
+	republished := key("m2", nil, `{"type":"marketing","data":{"y":2,"x":1},"message":"B","title":"A"}`)
+	changed := key("m3", nil, `{"type":"marketing","title":"A","body":"C","data":{"x":1,"y":2}}`)
+	if first != republished {
+		t.Errorf("the same content republished with a new ID should share the key: %q != %q", first, republished)
+	}
+	if first == changed {
+		t.Error("different content must not share the key")
+	}
+
+	toUser1 := key("", nil, `{"title":"A","body":"B","userId":"u1"}`)
+	toUser2 := key("", nil, `{"title":"A","body":"B","userId":"u2"}`)
+	if toUser1 == toUser2 {
+		t.Error("the same text for two users must not share the key")
+	}
+	sorted := key("", nil, `{"title":"A","body":"B","installationIds":["i1","i2"]}`)
+	reordered := key("", nil, `{"title":"A","body":"B","installationIds":["i2","i1"]}`)
+	if sorted != reordered || sorted == key("", nil, `{"title":"A","body":"B","installationIds":["i3"]}`) {
+		t.Error("installation IDs should be part of the key, in any order")
+	}
+	if key("", nil, `{"title":"A","body":"B","format":"apple"}`) == key("", nil, `{"title":"A","body":"B","format":"fcmv1"}`) {
+		t.Error("the same text in two formats must not share the key")
+	}
+}
+
+func TestScheduling(t *testing.T) {
+	var sent NotificationMessage
+	scheduler := newStubScheduler()
+	p := NewServiceBusNotificationProcessor(stubHub{sent: &sent}, scheduler, defaultIdempotency, defaultScheduling)
+	process := func(messageID, body string) {
+		t.Helper()
+		if err := p.ProcessMessage(context.Background(), messageID, "application/json", nil, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sendAt := time.Now().Add(12 * time.Hour).In(time.UTC).Truncate(time.Second)
+	process("m1", `{"title":"Pulizia strade","body":"Domani alle 7","sendAt":"`+sendAt.Format(time.RFC3339)+`"}`)
+	if sent.Title != "" || !scheduler.scheduled["m1"].Equal(sendAt) {
+		t.Fatalf("a future sendAt should be scheduled, got sent %+v, scheduled %v", sent, scheduler.scheduled)
+	}
+
+	// The scheduled copy comes back when due
+	process("m1", `{"title":"Pulizia strade","body":"Domani alle 7","sendAt":"`+time.Now().Format(time.RFC3339)+`"}`)
+	if sent.Title != "Pulizia strade" {
+		t.Fatal("a due sendAt should be sent")
+	}
+
+	// A copy is sent under its original ID, even if it arrives early
+	sent = NotificationMessage{}
+	early := `{"title":"A","body":"B","sendAt":"` + sendAt.Format(time.RFC3339) + `"}`
+	properties := map[string]any{OriginalMessageIDProperty: "m3", ScheduledForProperty: "notification-subscription"}
+	if err := p.ProcessMessage(context.Background(), "m3:scheduled", "application/json", properties, []byte(early)); err != nil {
+		t.Fatal(err)
+	}
+	if sent.IdempotencyKey != "m3" {
+		t.Errorf("a scheduled copy should be sent under the original ID, got %+v", sent)
+	}
+	if _, rescheduled := scheduler.scheduled["m3"]; rescheduled {
+		t.Error("a scheduled copy must not be scheduled again")
+	}
+
+	// A retry copy of a message still to be scheduled is scheduled under the first message ID
+	sent = NotificationMessage{}
+	properties = map[string]any{RetryOfProperty: "m4", RetryAttemptsProperty: int64(1), ScheduledForProperty: "notification-subscription"}
+	if err := p.ProcessMessage(context.Background(), "m4:retry:1", "application/json", properties, []byte(early)); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Title != "" || !scheduler.scheduled["m4"].Equal(sendAt) {
+		t.Errorf("a retry copy should be scheduled under the first message ID, got sent %+v, scheduled %v", sent, scheduler.scheduled)
+	}
+
+	sent = NotificationMessage{}
+	process("c1", `{"cancelMessageId":"m2"}`)
+	process("m2", `{"title":"A","body":"B","sendAt":"`+time.Now().Format(time.RFC3339)+`"}`)
+	if sent.Title != "" {
+		t.Error("a cancelled message should not be sent")
+	}
+}
+
+func TestParseSendAt(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	tests := []struct {
+		sendAt, timeZone string
+		want             time.Time
+	}{
+		{"2030-03-01T07:00:00Z", "Europe/Rome", time.Date(2030, 3, 1, 7, 0, 0, 0, time.UTC)},
+		{"2030-03-01T07:00:00+01:00", "", time.Date(2030, 3, 1, 6, 0, 0, 0, time.UTC)},
+		{"2030-03-01T07:00", "", time.Date(2030, 3, 1, 7, 0, 0, 0, rome)},
+		{"2030-07-01 07:00", "Europe/Rome", time.Date(2030, 7, 1, 5, 0, 0, 0, time.UTC)},
+		{"2030-07-01T07:00:00", "America/New_York", time.Date(2030, 7, 1, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseSendAt(tt.sendAt, tt.timeZone, rome)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseSendAt(%q, %q) = %v, %v, want %v", tt.sendAt, tt.timeZone, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Application properties of the scheduled copies. A copy has its own MessageID, so that Service Bus duplicate
+// detection does not drop it as a repetition of the original, and is processed under the original one.
+const (
+	OriginalMessageIDProperty = "originalMessageId" // MessageID of the message the copy was made from
+	ScheduledForProperty      = "scheduledFor"      // subscription the copy is meant for
+)
+
+// Application properties of the retry copies, sent back to the subscription with a delay after a transient failure.
+// A retry copy is processed under retryOf, unless it is the retry of a scheduled copy, processed under the original.
+const (
+	RetryOfProperty       = "retryOf"       // MessageID of the first message of the retries
+	RetryAttemptsProperty = "retryAttempts" // deliveries that failed before the copy, whose own DeliveryCount starts from 1
+)
+
+// Scheduler defers notifications to their sendAt and cancels them
+type Scheduler interface {
+	// Schedule enqueues a copy of the message, with the same body, to be delivered at sendAt
+	Schedule(ctx context.Context, messageID string, sendAt time.Time, contentType string, properties map[string]any, body []byte) error
+	// Cancel prevents messageID from being sent, whether it is already scheduled or not received yet
+	Cancel(ctx context.Context, messageID string) error
+	// Cancelled reports whether messageID was cancelled
+	Cancelled(ctx context.Context, messageID string) (bool, error)
+}
+
+// localLayouts are the sendAt layouts without offset, read in the time zone of the message
+var localLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// parseSendAt reads sendAt as RFC 3339, whose offset wins over timeZone, or as a local time in timeZone,
+// defaultLocation when empty. An empty sendAt gives the zero time.
+func parseSendAt(sendAt, timeZone string, defaultLocation *time.Location) (time.Time, error) {
+	if sendAt == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, sendAt); err == nil {
+		return t, nil
+	}
+
+	location := defaultLocation
+	if timeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return time.Time{}, fmt.Errorf("unknown timeZone %q", timeZone)
+		}
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, sendAt, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("sendAt %q must be RFC 3339 or a local time like 2006-01-02T07:00", sendAt)
+}
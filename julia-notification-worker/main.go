@@ -6,10 +6,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	_ "time/tzdata" // time zones of sendAt, also where the system has no zoneinfo
 
 	"julia-notification-worker/internal/config"
+	"julia-notification-worker/internal/metrics"
 	"julia-notification-worker/internal/service"
 	"julia-notification-worker/internal/servicebus"
+	"julia-notification-worker/internal/telemetry"
 	"julia-notification-worker/internal/worker"
 )
 
@@ -17,23 +21,92 @@ func main() {
 	log.Println("Initializing Julia Notification Worker (Go version)...")
 
 	// Load configuration
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig(context.Background())
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	log.Printf("Configuration loaded from %q:", cfg.File())
+	for _, line := range cfg.Dump() {
+		log.Printf("  %s", line)
+	}
+
+	// Initialize tracing
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry)
+	if err != nil {
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}()
 
 	// Initialize services
-	dedupeService := service.NewDeduplicationService()
-	hubService := service.NewNotificationHubService(cfg.NotificationHub, dedupeService)
+	deduplicator, err := service.NewDeduplicator(cfg.Deduplication)
+	if err != nil {
+		log.Fatalf("Error initializing deduplication: %v", err)
+	}
+	if redisDeduplicator, ok := deduplicator.(*service.RedisDeduplicator); ok {
+		defer redisDeduplicator.Close()
+		if err := redisDeduplicator.Ping(context.Background()); err != nil {
+			log.Fatalf("Error connecting to the deduplication Redis: %v", err)
+		}
+	}
+	metrics.ObserveDeduplicationEntries(deduplicator.Size)
+	var deliveries service.DeliveryStore
+	if cfg.Tracking.Enabled {
+		deliveries, err = service.NewDeliveryStore(cfg.Deduplication, cfg.Tracking)
+		if err != nil {
+			log.Fatalf("Error initializing delivery tracking: %v", err)
+		}
+		if redisDeliveries, ok := deliveries.(*service.RedisDeliveryStore); ok {
+			defer redisDeliveries.Close()
+		}
+	}
+	hubService := service.NewNotificationHubService(cfg.NotificationHub, deduplicator, deliveries, cfg.Tracking)
+	defer hubService.Close()
+
+	// Apply configuration file edits and rotated secrets to the settings that are safe to change at runtime
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	err = cfg.Watch(watchCtx, func(next *config.Config, changed []string, err error) {
+		if err != nil {
+			log.Printf("Configuration reload rejected: %v", err)
+			return
+		}
+		if len(changed) == 0 {
+			return
+		}
+		hubService.SetEnabled(next.NotificationHub.Enabled)
+		hubService.SetConnectionString(next.NotificationHub.ConnectionString)
+		log.Printf("Configuration reloaded: %v", changed)
+	})
+	if err != nil {
+		log.Printf("Warning: configuration file changes will not be applied: %v", err)
+	}
 
 	// Initialize message processor
-	processor := worker.NewServiceBusNotificationProcessor(hubService)
+	scheduleStore, err := service.NewScheduleStore(cfg.Deduplication, cfg.Scheduling)
+	if err != nil {
+		log.Fatalf("Error initializing schedule store: %v", err)
+	}
+	if redisScheduleStore, ok := scheduleStore.(*service.RedisScheduleStore); ok {
+		defer redisScheduleStore.Close()
+	}
+	scheduler, err := servicebus.NewScheduler(cfg.ServiceBus, cfg.Scheduling, scheduleStore)
+	if err != nil {
+		log.Fatalf("Error initializing scheduler: %v", err)
+	}
+	defer scheduler.Close()
+	processor := worker.NewServiceBusNotificationProcessor(hubService, scheduler, cfg.Idempotency, cfg.Scheduling)
 
 	// Initialize Service Bus client
-	sbClient, err := servicebus.NewClient(
-		cfg.ServiceBus.ConnectionString,
-		cfg.ServiceBus.TopicName,
-		cfg.ServiceBus.SubscriptionName,
-		cfg.ServiceBus.MaxConcurrentCalls,
-		processor,
-	)
+	sbClient, err := servicebus.NewClient(cfg.ServiceBus, processor, scheduler)
 	if err != nil {
 		log.Fatalf("Error initializing Service Bus client: %v", err)
 	}
@@ -52,7 +125,15 @@ func main() {
 		cancel()
 	}()
 
-	log.Println("Worker is running. Listening for messages...")
+	if cfg.Metrics.Address != "" {
+		if err := metrics.Serve(ctx, cfg.Metrics.Address); err != nil {
+			log.Fatalf("Error starting metrics server: %v", err)
+		}
+		log.Printf("Metrics exposed on %s/metrics", cfg.Metrics.Address)
+	}
+
+	log.Printf("Worker is running with %d concurrent calls. Listening for messages...", cfg.ServiceBus.MaxConcurrentCalls)
+	// Start returns once the in-flight messages are drained; the deferred Close then closes the receiver
 	if err := sbClient.Start(ctx); err != nil {
 		log.Printf("Worker stopped with error: %v", err)
 	}
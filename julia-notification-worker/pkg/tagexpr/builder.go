@@ -0,0 +1,141 @@
+package tagexpr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/comune-roma/bff-julia-shared/pushtags"
+)
+
+// Expr is a tag expression under construction. Invalid tags are reported by Build, so that expressions can be
+// composed without checking every step:
+//
+//	expr, err := tagexpr.All(tagexpr.Any(tagexpr.Topic("tari"), tagexpr.Topic("rifiuti")), tagexpr.Language("it")).Build()
+//	// (topic_tari || topic_rifiuti) && lang_it
+type Expr struct {
+	text string
+	op   string // top-level operator, "" for a tag, a negation or a parenthesized expression
+	err  error
+}
+
+// Tag matches installations with the given tag
+func Tag(tag string) Expr {
+	if !ValidTag(tag) {
+		return Expr{err: fmt.Errorf("invalid tag %q", tag)}
+	}
+	return Expr{text: tag}
+}
+
+// Topic matches installations subscribed to a topic, with the tag the profile API registers for it
+func Topic(name string) Expr {
+	return Tag(pushtags.Topic(name))
+}
+
+// Language matches installations using a language, e.g. "it", with the tag the profile API registers for it
+func Language(code string) Expr {
+	return Tag(pushtags.Language(code))
+}
+
+// Installation matches one installation
+func Installation(id string) Expr {
+	return Tag("$InstallationId:{" + id + "}")
+}
+
+// User matches the installations registered for a user ID
+func User(id string) Expr {
+	return Tag("$UserId:{" + id + "}")
+}
+
+// Parse wraps an expression received as text, e.g. from a message, so that it can be composed with others.
+// The expression is validated, and parenthesized when composed unless it is a single tag.
+func Parse(expr string) Expr {
+	expr = strings.TrimSpace(expr)
+	if err := Validate(expr); err != nil {
+		return Expr{err: err}
+	}
+	if expr == "" {
+		return Expr{err: fmt.Errorf("empty tag expression")}
+	}
+	if ValidTag(expr) {
+		return Expr{text: expr}
+	}
+	return Expr{text: expr, op: "parsed"} // never equal to an operator, so always parenthesized
+}
+
+// Any matches installations matching at least one of exprs
+func Any(exprs ...Expr) Expr {
+	return join("||", exprs)
+}
+
+// All matches installations matching every one of exprs
+func All(exprs ...Expr) Expr {
+	return join("&&", exprs)
+}
+
+// Not matches installations not matching e
+func Not(e Expr) Expr {
+	if e.err != nil {
+		return e
+	}
+	return Expr{text: "!" + e.group()}
+}
+
+func join(op string, exprs []Expr) Expr {
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	parts := make([]string, 0, len(exprs))
+	for _, e := range exprs {
+		if e.err != nil {
+			return e
+		}
+		if e.op == op {
+			parts = append(parts, e.text) // same operator, no parentheses needed
+		} else {
+			parts = append(parts, e.group())
+		}
+	}
+	if len(parts) == 0 {
+		return Expr{err: fmt.Errorf("%s of no expression", op)}
+	}
+	return Expr{text: strings.Join(parts, " "+op+" "), op: op}
+}
+
+// group returns the expression as an operand, parenthesized when it has a top-level operator
+func (e Expr) group() string {
+	if e.op == "" {
+		return e.text
+	}
+	return "(" + e.text + ")"
+}
+
+// Build returns the expression, or the first error met while building it or validating it against the Hub limits
+func (e Expr) Build() (string, error) {
+	if e.err != nil {
+		return "", e.err
+	}
+	if err := Validate(e.text); err != nil {
+		return "", err
+	}
+	return e.text, nil
+}
+
+// Topics returns the topics addressed by expr, the tags with pushtags.TopicPrefix without it, sorted and without repetitions.
+// Negated topics are included too: the result names the topics an expression is about, not the ones it matches.
+func Topics(expr string) []string {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var topics []string
+	for _, t := range tokens {
+		if t.kind == tokenTag && strings.HasPrefix(t.text, pushtags.TopicPrefix) && !seen[t.text] {
+			seen[t.text] = true
+			topics = append(topics, strings.TrimPrefix(t.text, pushtags.TopicPrefix))
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
@@ -0,0 +1,202 @@
+// Package tagexpr validates and builds Notification Hub tag expressions.
+//
+// Grammar, loosest binding first:
+//
+//	expr  = and { "||" and }
+//	and   = unary { "&&" unary }
+//	unary = "!" unary | "(" expr ")" | tag
+//
+// A tag is made of letters, digits and _ @ # . : - up to 120 characters, or is one of the reserved
+// $InstallationId:{id} and $UserId:{id} tags. The Hub accepts up to 20 tags in an expression made only of ||,
+// and up to 6 when it also uses && or !.
+package tagexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Limits enforced by Notification Hub
+const (
+	MaxTags        = 20  // tags in an expression made only of ||
+	MaxTagsComplex = 6   // tags in an expression using && or !
+	MaxTagLength   = 120 // characters of a tag
+)
+
+var (
+	tagPattern      = regexp.MustCompile(`^[A-Za-z0-9_@#.:\-]+$`)
+	reservedPattern = regexp.MustCompile(`^\$(InstallationId|UserId):\{[A-Za-z0-9_@#.:\-]+\}$`)
+)
+
+// SyntaxError reports an invalid expression and the offset of the problem
+type SyntaxError struct {
+	Expr   string
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid tag expression %q at offset %d: %s", e.Expr, e.Offset, e.Msg)
+}
+
+// ValidTag reports whether tag may be used in an expression
+func ValidTag(tag string) bool {
+	return len(tag) <= MaxTagLength && (tagPattern.MatchString(tag) || reservedPattern.MatchString(tag))
+}
+
+// Validate checks expr against the grammar and the Hub limits; the empty expression, a broadcast, is valid
+func Validate(expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return err
+	}
+	p := &parser{expr: expr, tokens: tokens}
+	if err := p.parseOr(); err != nil {
+		return err
+	}
+	if p.pos < len(p.tokens) {
+		return p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+
+	limit := MaxTags
+	if p.complex {
+		limit = MaxTagsComplex
+	}
+	if p.tags > limit {
+		return &SyntaxError{Expr: expr, Offset: len(expr), Msg: fmt.Sprintf("%d tags, at most %d allowed", p.tags, limit)}
+	}
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokenTag tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(expr[i:], "&&"):
+			tokens = append(tokens, token{tokenAnd, "&&", i})
+			i += 2
+		case strings.HasPrefix(expr[i:], "||"):
+			tokens = append(tokens, token{tokenOr, "||", i})
+			i += 2
+		case c == '!':
+			tokens = append(tokens, token{tokenNot, "!", i})
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")", i})
+			i++
+		default:
+			end := i
+			for end < len(expr) && !strings.ContainsRune(" \t&|!()", rune(expr[end])) {
+				end++
+			}
+			tag := expr[i:end]
+			if tag == "" || !ValidTag(tag) {
+				if tag == "" {
+					tag = expr[i : i+1]
+				}
+				return nil, &SyntaxError{Expr: expr, Offset: i, Msg: fmt.Sprintf("invalid tag %q", tag)}
+			}
+			tokens = append(tokens, token{tokenTag, tag, i})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	expr    string
+	tokens  []token
+	pos     int
+	tags    int
+	complex bool // uses && or !
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	offset := len(p.expr)
+	if p.pos < len(p.tokens) {
+		offset = p.tokens[p.pos].offset
+	}
+	return &SyntaxError{Expr: p.expr, Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) accept(kind tokenKind) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() error {
+	if err := p.parseAnd(); err != nil {
+		return err
+	}
+	for p.accept(tokenOr) {
+		if err := p.parseAnd(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseAnd() error {
+	if err := p.parseUnary(); err != nil {
+		return err
+	}
+	for p.accept(tokenAnd) {
+		p.complex = true
+		if err := p.parseUnary(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseUnary() error {
+	switch {
+	case p.accept(tokenNot):
+		p.complex = true
+		return p.parseUnary()
+	case p.accept(tokenOpen):
+		if err := p.parseOr(); err != nil {
+			return err
+		}
+		if !p.accept(tokenClose) {
+			return p.errorf("missing )")
+		}
+		return nil
+	case p.accept(tokenTag):
+		p.tags++
+		return nil
+	case p.pos < len(p.tokens):
+		return p.errorf("unexpected %q", p.tokens[p.pos].text)
+	default:
+		return p.errorf("unexpected end of expression")
+	}
+}
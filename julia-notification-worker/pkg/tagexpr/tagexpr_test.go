@@ -0,0 +1,81 @@
+package tagexpr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	twentyOne := make([]string, 21)
+	for i := range twentyOne {
+		twentyOne[i] = "t" + strings.Repeat("x", i)
+	}
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"", true},
+		{"lang_it", true},
+		{"topic_tari || topic_rifiuti", true},
+		{"(topic_tari || topic_rifiuti) && !lang_en", true},
+		{"$InstallationId:{a1-b2} || $UserId:{RSSMRA80A01H501U}", true},
+		{strings.Join(twentyOne[:20], " || "), true},
+		{strings.Join(twentyOne, " || "), false},
+		{"a && b && c && d && e && f", true},
+		{"a && b && c && d && e && f && g", false},
+		{"!a || b || c || d || e || f || g", false},
+		{strings.Repeat("x", 121), false},
+		{"lang it", false},
+		{"lang_it &&", false},
+		{"&& lang_it", false},
+		{"(lang_it", false},
+		{"lang_it)", false},
+		{"a & b", false},
+		{"$Other:{x}", false},
+		{"$UserId:{a b}", false},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.expr); (err == nil) != tt.valid {
+			t.Errorf("Validate(%q) = %v, want valid %v", tt.expr, err, tt.valid)
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		expr Expr
+		want string
+	}{
+		{All(Any(Topic("tari"), Topic("rifiuti")), Language("IT")), "(topic_tari || topic_rifiuti) && lang_it"},
+		{Any(Any(Tag("a"), Tag("b")), Tag("c")), "a || b || c"},
+		{All(Tag("a"), Not(Any(Tag("b"), Tag("c")))), "a && !(b || c)"},
+		{All(User("u1"), Parse("a || b")), "$UserId:{u1} && (a || b)"},
+		{All(User("u1"), Parse("a")), "$UserId:{u1} && a"},
+		{Any(Installation("i1"), Installation("i2")), "$InstallationId:{i1} || $InstallationId:{i2}"},
+	}
+	for _, tt := range tests {
+		got, err := tt.expr.Build()
+		if err != nil || got != tt.want {
+			t.Errorf("got %q, %v, want %q", got, err, tt.want)
+		}
+		if err := Validate(got); err != nil {
+			t.Errorf("built expression %q does not validate: %v", got, err)
+		}
+	}
+
+	for _, invalid := range []Expr{Tag("a b"), All(Tag("a"), Topic("x y")), Any(), Parse("a &&"), All(Tag("a"), Tag("b"), Tag("c"), Tag("d"), Tag("e"), Tag("f"), Tag("g"))} {
+		if got, err := invalid.Build(); err == nil {
+			t.Errorf("expected an error, got %q", got)
+		}
+	}
+}
+
+func TestTopics(t *testing.T) {
+	got := Topics("(topic_tari || topic_rifiuti || topic_tari) && lang_it && !topic_eventi")
+	if strings.Join(got, ",") != "eventi,rifiuti,tari" {
+		t.Errorf("Topics = %v", got)
+	}
+	if got := Topics("lang_it"); got != nil {
+		t.Errorf("Topics without topic tags = %v", got)
+	}
+}
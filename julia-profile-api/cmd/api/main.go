@@ -16,10 +16,18 @@ import (
 	"github.com/comune-roma/bff-julia-profile-api/internal/middleware"
 	"github.com/comune-roma/bff-julia-profile-api/internal/repository"
 	"github.com/comune-roma/bff-julia-profile-api/internal/service"
+	"github.com/comune-roma/bff-julia-profile-api/internal/validation"
 	"github.com/comune-roma/bff-julia-profile-api/pkg/azure"
 	"github.com/comune-roma/bff-julia-profile-api/pkg/cache"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/health"
 	"github.com/comune-roma/bff-julia-profile-api/pkg/logger"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/problem"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/ratelimit"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/telemetry"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -38,7 +46,7 @@ func main() {
 	defer log.Sync()
 
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfig(context.Background())
 	if err != nil {
 		log.Fatal("Failed to load configuration", zap.Error(err))
 	}
@@ -47,9 +55,38 @@ func main() {
 	if err := cfg.Validate(); err != nil {
 		log.Fatal("Invalid configuration", zap.Error(err))
 	}
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatal("Invalid log level", zap.Error(err))
+	}
+	log.Info("Configuration loaded", zap.String("file", cfg.File()), zap.Strings("values", cfg.Dump()))
+
+	// Initialize tracing
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Warn("Failed to flush traces", zap.Error(err))
+		}
+	}()
+
+	// Register request validation rules
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		if err := validation.Register(v); err != nil {
+			log.Fatal("Failed to register validation rules", zap.Error(err))
+		}
+	}
+
+	// Initialize resilience policies
+	cosmosPolicy := resilience.NewPolicy("cosmosdb", cfg.Resilience.Cosmos, log)
+	redisPolicy := resilience.NewPolicy("redis", cfg.Resilience.Redis, log)
+	notificationPolicy := resilience.NewPolicy("notification-service", cfg.Resilience.Notification, log)
 
 	// Initialize Azure clients
-	cosmosClient, err := azure.NewCosmosClient(cfg)
+	cosmosClient, err := azure.NewCosmosClient(cfg, cosmosPolicy)
 	if err != nil {
 		log.Fatal("Failed to initialize Cosmos DB client", zap.Error(err))
 	}
@@ -62,86 +99,153 @@ func main() {
 	// Initialize repositories
 	userProfileRepo := repository.NewUserProfileRepository(cosmosClient, cfg.CosmosDB.Database)
 	userPreferencesRepo := repository.NewUserPreferencesRepository(cosmosClient, cfg.CosmosDB.Database)
+	verificationRepo := repository.NewContactVerificationRepository(cosmosClient, cfg.CosmosDB.Database)
+	auditRepo := repository.NewAuditRepository(cosmosClient, cfg.CosmosDB.Database)
 
 	// Initialize notification client
-	notificationClient := client.NewNotificationClient("http://notification-service", log)
+	notificationClient := client.NewNotificationClient("http://notification-service", notificationPolicy, log)
 
-	// Initialize Redis cache
-	redisCache := cache.NewRedisCache(cfg.Redis)
+	// Initialize verification code sender
+	verificationSender, err := client.NewVerificationSender(cfg.Verification, notificationClient, log)
+	if err != nil {
+		log.Fatal("Failed to initialize verification sender", zap.Error(err))
+	}
+
+	// Initialize profile cache
+	profileCache, err := cache.New(cfg.Cache, cfg.Redis, redisPolicy, log)
+	if err != nil {
+		log.Fatal("Failed to initialize cache", zap.Error(err))
+	}
+	defer profileCache.Close()
+	log.Info("Profile cache initialized", zap.String("backend", cfg.Cache.Backend))
+
+	// Initialize rate limiting
+	rateLimitStore, err := ratelimit.New(cfg.RateLimit, cfg.Redis)
+	if err != nil {
+		log.Fatal("Failed to initialize rate limit store", zap.Error(err))
+	}
+	defer rateLimitStore.Close()
+	rateLimiter, err := middleware.NewRateLimiter(rateLimitStore, cfg.RateLimit, log)
+	if err != nil {
+		log.Fatal("Failed to initialize rate limiter", zap.Error(err))
+	}
 
 	// Initialize services
-	userProfileService := service.NewUserProfileService(userProfileRepo, redisCache, cfg.Redis, log)
-	userPreferencesService := service.NewUserPreferencesService(appConfigClient, userPreferencesRepo, notificationClient, cfg, log)
+	auditService := service.NewAuditService(auditRepo, log)
+	verificationService := service.NewContactVerificationService(verificationRepo, userProfileRepo, verificationSender, auditService, cfg, log)
+	userProfileService := service.NewUserProfileService(userProfileRepo, verificationService, auditService, profileCache, cfg.Cache, log)
+	userPreferencesService := service.NewUserPreferencesService(appConfigClient, userPreferencesRepo, notificationClient, auditService, cfg, log)
+
+	// Initialize health checks
+	healthRegistry := health.NewRegistry("bff-julia-profile-api",
+		time.Duration(cfg.Health.Interval)*time.Second,
+		time.Duration(cfg.Health.Timeout)*time.Second,
+		log,
+	)
+	healthRegistry.Register("cosmosdb", true, userProfileRepo.Ping)
+	if cfg.Cache.Backend != config.CacheBackendNone {
+		// The profile service falls back to Cosmos DB when the cache is unavailable
+		healthRegistry.Register("cache", false, profileCache.Ping)
+	}
+	if cfg.AppConfig.Endpoint != "" {
+		healthRegistry.Register("appconfig", false, health.HTTPCheck(&http.Client{}, cfg.AppConfig.Endpoint))
+	}
+	healthRegistry.Register("notification-service", false, notificationClient.Ping)
+	if store, ok := rateLimitStore.(*ratelimit.RedisStore); ok && cfg.RateLimit.Enabled {
+		// Requests are let through when the rate limit store is unavailable
+		healthRegistry.Register("ratelimit-store", false, store.Ping)
+	}
+	for _, policy := range []*resilience.Policy{cosmosPolicy, redisPolicy, notificationPolicy} {
+		healthRegistry.Register("circuit-breaker:"+policy.Name(), false, policy.Check)
+	}
+	healthRegistry.Start()
+	defer healthRegistry.Stop()
 
 	// Initialize handlers
+	healthHandler := handler.NewHealthHandler(healthRegistry)
 	profileHandler := handler.NewUserProfileHandler(userProfileService, log)
 	preferencesHandler := handler.NewUserPreferencesHandler(userPreferencesService, log)
 	installationHandler := handler.NewInstallationHandler(userPreferencesService, log)
+	auditHandler := handler.NewAuditHandler(auditService, log)
+
+	signingKey := middleware.NewSigningKey(cfg.Auth.JWTSecret)
+
+	// Apply configuration file edits and rotated secrets to the settings that are safe to change at runtime
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	err = cfg.Watch(watchCtx, func(next *config.Config, changed []string, err error) {
+		if err != nil {
+			log.Warn("Configuration reload rejected", zap.Error(err))
+			return
+		}
+		if len(changed) == 0 {
+			return
+		}
+		if err := logger.SetLevel(next.LogLevel); err != nil {
+			log.Warn("Failed to apply log level", zap.Error(err))
+		}
+		rateLimiter.SetGroups(next.RateLimit.Groups)
+		signingKey.Set(next.Auth.JWTSecret)
+		log.Info("Configuration reloaded", zap.Strings("keys", changed))
+	})
+	if err != nil {
+		log.Warn("Configuration file changes will not be applied", zap.Error(err))
+	}
 
 	// Setup Gin router
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.New()
+	// The client IP keys rate limits and exemptions: only trust X-Forwarded-For from known proxies
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 
 	// Middlewares
+	router.Use(telemetry.Middleware())
+	router.Use(middleware.RequestID())
 	router.Use(middleware.Logger(log))
+	router.Use(middleware.Metrics())
 	router.Use(middleware.Recovery(log))
-	router.Use(middleware.CORS())
-	router.Use(middleware.RequestID())
-
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		status := http.StatusOK
-		response := gin.H{
-			"status":  "UP",
-			"service": "bff-julia-profile-api",
-			"time":    time.Now().Format(time.RFC3339),
-		}
+	router.Use(middleware.CORS(cfg.CORS))
 
-		// Check database connection
-		if err := userProfileRepo.Ping(c.Request.Context()); err != nil {
-			status = http.StatusServiceUnavailable
-			response["status"] = "DOWN"
-			response["error"] = "Database connection error"
-			log.Error("Health check failed: database", zap.Error(err))
-		}
-
-		// Check Redis connection if enabled
-		if cfg.Redis.Enabled {
-			if err := redisCache.Ping(c.Request.Context()); err != nil {
-				response["cache_status"] = "DOWN"
-				log.Warn("Health check warning: Redis", zap.Error(err))
-			} else {
-				response["cache_status"] = "UP"
-			}
-		}
-
-		c.JSON(status, response)
-	})
+	// Health check endpoints
+	router.GET("/health/live", healthHandler.Live)
+	router.GET("/health/ready", healthHandler.Ready)
+	router.GET("/health", healthHandler.Ready)
 
 	// Metrics endpoint
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// API v1 routes
 	v1 := router.Group("/api/v1")
-	v1.Use(middleware.Auth(cfg.Auth))
+	v1.Use(middleware.Auth(cfg.Auth, signingKey))
 	{
+		read := rateLimiter.Limit(config.RateLimitGroupRead)
+		write := rateLimiter.Limit(config.RateLimitGroupWrite)
+
 		// Profile
-		v1.GET("/users/me", profileHandler.GetUserProfile)
-		v1.PUT("/users/me", profileHandler.UpdateUserProfile)
+		v1.GET("/users/me", read, profileHandler.GetUserProfile)
+		v1.PUT("/users/me", write, profileHandler.UpdateUserProfile)
+		v1.POST("/users/me/contact/verify", rateLimiter.Limit(config.RateLimitGroupVerification), profileHandler.VerifyContact)
+		v1.GET("/users/me/history", read, auditHandler.GetMyHistory)
 
 		// Preferences
-		v1.GET("/users/me/preferences/chat", preferencesHandler.GetUserPreferences)
-		v1.PUT("/users/me/preferences/chat", preferencesHandler.UpdateUserPreferences)
-		v1.GET("/users/me/preferences/language", preferencesHandler.GetPreferredLanguage)
-		v1.PUT("/users/me/preferences/language", preferencesHandler.SetPreferredLanguage)
+		v1.GET("/users/me/preferences/chat", read, preferencesHandler.GetUserPreferences)
+		v1.PUT("/users/me/preferences/chat", write, preferencesHandler.UpdateUserPreferences)
+		v1.GET("/users/me/preferences/language", read, preferencesHandler.GetPreferredLanguage)
+		v1.PUT("/users/me/preferences/language", write, preferencesHandler.SetPreferredLanguage)
 
 		// Notifications
-		v1.GET("/users/me/notifications/preferences", preferencesHandler.GetNotificationPreferences)
-		v1.PUT("/users/me/notifications/preferences", preferencesHandler.UpdateNotificationPreferences)
-		v1.PUT("/users/me/notifications/installations/:installationId", installationHandler.UpsertInstallation)
-		v1.DELETE("/users/me/notifications/installations/:installationId", installationHandler.DeleteInstallation)
+		v1.GET("/users/me/notifications/preferences", read, preferencesHandler.GetNotificationPreferences)
+		v1.PUT("/users/me/notifications/preferences", write, preferencesHandler.UpdateNotificationPreferences)
+		v1.PUT("/users/me/notifications/installations/:installationId", write, installationHandler.UpsertInstallation)
+		v1.DELETE("/users/me/notifications/installations/:installationId", write, installationHandler.DeleteInstallation)
+
+		// Operator
+		operator := v1.Group("/operator", middleware.RequireRole(cfg.Auth, "operator"), rateLimiter.Limit(config.RateLimitGroupOperator))
+		operator.GET("/users/:userId/history", auditHandler.GetUserHistory)
 	}
 
 	// Swagger documentation (enabled only if not in production or explicitly allowed)
@@ -149,6 +253,9 @@ func main() {
 		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	}
 
+	// Unknown routes get a problem response as well
+	router.NoRoute(problem.NoRoute)
+
 	// Start server with graceful shutdown
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", cfg.Server.Port),
@@ -1,48 +1,193 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/health"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
 	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
-	"github.com/sony/gobreaker"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/telemetry"
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 )
 
 // NotificationClient handles communication with the Notification Service
 type NotificationClient struct {
-	baseURL string
-	cb      *gobreaker.CircuitBreaker
-	log     *zap.Logger
+	baseURL    string
+	httpClient *http.Client
+	policy     *resilience.Policy
+	log        *zap.Logger
 }
 
 // NewNotificationClient creates a new NotificationClient
-func NewNotificationClient(baseURL string, log *zap.Logger) *NotificationClient {
+func NewNotificationClient(baseURL string, policy *resilience.Policy, log *zap.Logger) *NotificationClient {
 	return &NotificationClient{
-		baseURL: baseURL,
-		cb:      resilience.NewCircuitBreaker("notification-service"),
-		log:     log,
+		baseURL:    baseURL,
+		httpClient: &http.Client{},
+		policy:     policy,
+		log:        log,
 	}
 }
 
+// Ping checks that the Notification Service is reachable
+func (c *NotificationClient) Ping(ctx context.Context) error {
+	return health.HTTPCheck(c.httpClient, c.baseURL+"/health")(ctx)
+}
+
 // SyncUserPreferences synchronizes user preferences with the Notification Service
 func (c *NotificationClient) SyncUserPreferences(ctx context.Context, language string, topics []string) error {
-	_, err := c.cb.Execute(func() (interface{}, error) {
-		c.log.Info("Syncing user preferences to Notification Service",
+	ctx, span := tracing.StartClientSpan(ctx, telemetry.Tracer(), "notification-service sync preferences",
+		attribute.String("peer.service", "notification-service"),
+		attribute.Int("notification.topics", len(topics)),
+	)
+	err := c.policy.Execute(ctx, func(ctx context.Context) error {
+		reqctx.Logger(ctx, c.log).Info("Syncing user preferences to Notification Service",
 			zap.String("language", language),
 			zap.Int("topicsCount", len(topics)),
 		)
 
 		// TODO: Implement actual HTTP call to Notification Service
-		// In a real implementation:
-		// resp, err := http.Post(c.baseURL + "/sync", ...)
+		// In a real implementation the trace context is injected into the request headers:
+		// otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+		// req.Header.Set(reqctx.CorrelationIDHeader, reqctx.CorrelationID(ctx))
+		// resp, err := c.httpClient.Do(req)
 		// if err != nil || resp.StatusCode >= 500 { return nil, err }
 
-		return nil, nil
+		return nil
+	})
+
+	tracing.EndSpan(span, err)
+	if err != nil {
+		reqctx.Logger(ctx, c.log).Warn("Notification service sync failed or circuit open", zap.Error(err))
+	}
+
+	return err
+}
+
+// verificationCodeRequest is the body of the Notification Service verification code endpoint
+type verificationCodeRequest struct {
+	Channel     model.ContactChannel `json:"channel"`
+	Destination string               `json:"destination"`
+	Code        string               `json:"code"`
+}
+
+// SendVerificationCode asks the Notification Service to deliver a verification code by email or SMS.
+// It implements VerificationSender and is the sender used in production.
+func (c *NotificationClient) SendVerificationCode(ctx context.Context, channel model.ContactChannel, destination, code string) error {
+	body, err := json.Marshal(verificationCodeRequest{Channel: channel, Destination: destination, Code: code})
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification code request: %w", err)
+	}
+
+	ctx, span := tracing.StartClientSpan(ctx, telemetry.Tracer(), "notification-service send verification code",
+		attribute.String("peer.service", "notification-service"),
+		attribute.String("verification.channel", string(channel)),
+	)
+	err = c.send(ctx, http.MethodPost, "/api/v1/verification-codes", body)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		reqctx.Logger(ctx, c.log).Warn("Failed to send verification code", zap.String("channel", string(channel)), zap.Error(err))
+	}
+	return err
+}
+
+// installationRequest is the body of the Notification Service installation endpoint
+type installationRequest struct {
+	InstallationID string                     `json:"installationId"`
+	UserID         string                     `json:"userId"`
+	Platform       model.InstallationPlatform `json:"platform"`
+	PushChannel    string                     `json:"pushChannel"`
+	Tags           []string                   `json:"tags"`
+}
+
+// RegisterInstallation creates or replaces a device installation in the Notification Hub through the
+// Notification Service. tags are the pushtags the notification worker targets.
+func (c *NotificationClient) RegisterInstallation(ctx context.Context, userID, installationID string, req *model.DeviceInstallationRequest, tags []string) error {
+	body, err := json.Marshal(installationRequest{
+		InstallationID: installationID,
+		UserID:         userID,
+		Platform:       req.Platform,
+		PushChannel:    req.PushChannel,
+		Tags:           tags,
 	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal installation request: %w", err)
+	}
 
+	ctx, span := tracing.StartClientSpan(ctx, telemetry.Tracer(), "notification-service register installation",
+		attribute.String("peer.service", "notification-service"),
+		attribute.Int("notification.tags", len(tags)),
+	)
+	err = c.send(ctx, http.MethodPut, "/api/v1/installations/"+url.PathEscape(installationID), body)
+	tracing.EndSpan(span, err)
 	if err != nil {
-		c.log.Warn("Notification service sync failed or circuit open", zap.Error(err))
+		reqctx.Logger(ctx, c.log).Warn("Failed to register installation", zap.String("installationID", installationID), zap.Error(err))
 	}
+	return err
+}
 
+// DeleteInstallation removes a device installation; an installation already gone is not an error
+func (c *NotificationClient) DeleteInstallation(ctx context.Context, installationID string) error {
+	ctx, span := tracing.StartClientSpan(ctx, telemetry.Tracer(), "notification-service delete installation",
+		attribute.String("peer.service", "notification-service"),
+	)
+	err := c.send(ctx, http.MethodDelete, "/api/v1/installations/"+url.PathEscape(installationID), nil)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		err = nil
+	}
+	tracing.EndSpan(span, err)
+	if err != nil {
+		reqctx.Logger(ctx, c.log).Warn("Failed to delete installation", zap.String("installationID", installationID), zap.Error(err))
+	}
 	return err
 }
+
+// statusError is returned for a Notification Service response with an error status
+type statusError struct {
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("notification service returned status %d", e.StatusCode)
+}
+
+// send makes a request to the Notification Service under the policy, with a JSON body if body is not nil.
+// 5xx and 429 responses are retried, other error statuses are returned at once.
+func (c *NotificationClient) send(ctx context.Context, method, path string, body []byte) error {
+	return c.policy.Execute(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return resilience.Permanent(err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set(reqctx.CorrelationIDHeader, reqctx.CorrelationID(ctx))
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+			return &statusError{StatusCode: resp.StatusCode}
+		case resp.StatusCode >= http.StatusBadRequest:
+			return resilience.Permanent(&statusError{StatusCode: resp.StatusCode})
+		}
+		return nil
+	})
+}
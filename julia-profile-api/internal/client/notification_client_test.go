@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
+	"go.uber.org/zap"
+)
+
+func TestNotificationClientRegistersInstallation(t *testing.T) {
+	var got installationRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/api/v1/installations/inst-1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	policy := resilience.NewPolicy("notification-service", testPolicyConfig, zap.NewNop())
+	c := NewNotificationClient(server.URL, policy, zap.NewNop())
+
+	req := &model.DeviceInstallationRequest{Platform: model.PlatformFCM, PushChannel: "token"}
+	tags := []string{"lang_it", "topic_tari"}
+	if err := c.RegisterInstallation(context.Background(), "user-001", "inst-1", req, tags); err != nil {
+		t.Fatal(err)
+	}
+	want := installationRequest{InstallationID: "inst-1", UserID: "user-001", Platform: model.PlatformFCM, PushChannel: "token", Tags: tags}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request body = %+v, want %+v", got, want)
+	}
+}
+
+func TestNotificationClientDeletesMissingInstallation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/api/v1/installations/inst-1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	policy := resilience.NewPolicy("notification-service", testPolicyConfig, zap.NewNop())
+	c := NewNotificationClient(server.URL, policy, zap.NewNop())
+
+	if err := c.DeleteInstallation(context.Background(), "inst-1"); err != nil {
+		t.Errorf("an installation already gone should not be an error, got %v", err)
+	}
+}
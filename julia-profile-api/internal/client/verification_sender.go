@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"go.uber.org/zap"
+)
+
+// VerificationSender delivers one-time verification codes to the user
+type VerificationSender interface {
+	SendVerificationCode(ctx context.Context, channel model.ContactChannel, destination, code string) error
+}
+
+// NewVerificationSender creates the sender selected by configuration
+func NewVerificationSender(cfg config.VerificationConfig, notifications *NotificationClient, log *zap.Logger) (VerificationSender, error) {
+	switch cfg.Sender {
+	case config.VerificationSenderNotification:
+		return notifications, nil
+	case config.VerificationSenderLog:
+		return NewLogVerificationSender(log), nil
+	case config.VerificationSenderFile:
+		return NewFileVerificationSender(cfg.OutboxPath, log), nil
+	default:
+		return nil, fmt.Errorf("unknown verification sender: %s", cfg.Sender)
+	}
+}
+
+// LogVerificationSender only records in the application log that a code was issued, with the destination
+// masked and without the code; use the file sender to read codes locally. Not allowed in production.
+type LogVerificationSender struct {
+	log *zap.Logger
+}
+
+// NewLogVerificationSender creates a new LogVerificationSender
+func NewLogVerificationSender(log *zap.Logger) *LogVerificationSender {
+	return &LogVerificationSender{log: log}
+}
+
+// SendVerificationCode logs that a verification code was issued
+func (s *LogVerificationSender) SendVerificationCode(ctx context.Context, channel model.ContactChannel, destination, code string) error {
+	reqctx.Logger(ctx, s.log).Info("Verification code issued",
+		zap.String("channel", string(channel)),
+		zap.String("destination", maskDestination(destination)),
+	)
+	return nil
+}
+
+// maskDestination keeps the first character and the email domain or the last two digits, e.g. m***@example.com
+// or +39***67, enough to tell destinations apart in the logs
+func maskDestination(destination string) string {
+	if at := strings.LastIndex(destination, "@"); at > 0 {
+		return destination[:1] + "***" + destination[at:]
+	}
+	if len(destination) <= 4 {
+		return "***"
+	}
+	return destination[:3] + "***" + destination[len(destination)-2:]
+}
+
+// outboxEntry is a single line of the file outbox
+type outboxEntry struct {
+	Channel     model.ContactChannel `json:"channel"`
+	Destination string               `json:"destination"`
+	Code        string               `json:"code"`
+	SentAt      time.Time            `json:"sentAt"`
+}
+
+// FileVerificationSender appends verification codes as JSON lines to an outbox file,
+// so tests and local tooling can read the code that would have been delivered. Not allowed in production.
+type FileVerificationSender struct {
+	path string
+	mu   sync.Mutex
+	log  *zap.Logger
+}
+
+// NewFileVerificationSender creates a new FileVerificationSender
+func NewFileVerificationSender(path string, log *zap.Logger) *FileVerificationSender {
+	return &FileVerificationSender{
+		path: path,
+		log:  log,
+	}
+}
+
+// SendVerificationCode appends the verification code to the outbox file
+func (s *FileVerificationSender) SendVerificationCode(ctx context.Context, channel model.ContactChannel, destination, code string) error {
+	line, err := json.Marshal(outboxEntry{
+		Channel:     channel,
+		Destination: destination,
+		Code:        code,
+		SentAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open verification outbox: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write verification outbox: %w", err)
+	}
+
+	reqctx.Logger(ctx, s.log).Debug("Verification code written to outbox",
+		zap.String("channel", string(channel)),
+		zap.String("path", s.path),
+	)
+	return nil
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogVerificationSenderHidesCodeAndDestination(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	sender := NewLogVerificationSender(zap.New(core))
+
+	sender.SendVerificationCode(context.Background(), model.ChannelEmail, "mario.rossi@example.com", "482913")
+	sender.SendVerificationCode(context.Background(), model.ChannelPhone, "+393331234567", "482913")
+
+	var lines []string
+	for _, entry := range logs.All() {
+		for key, value := range entry.ContextMap() {
+			lines = append(lines, key+"="+strings.TrimSpace(value.(string)))
+		}
+	}
+	all := strings.Join(lines, " ")
+	for _, secret := range []string{"482913", "mario.rossi", "3331234567"} {
+		if strings.Contains(all, secret) {
+			t.Errorf("log contains %q: %s", secret, all)
+		}
+	}
+	if !strings.Contains(all, "destination=m***@example.com") || !strings.Contains(all, "destination=+39***67") {
+		t.Errorf("destinations should be masked, got %s", all)
+	}
+}
+
+var testPolicyConfig = config.ResiliencePolicyConfig{
+	MaxAttempts:         3,
+	InitialBackoff:      1,
+	MaxBackoff:          2,
+	BreakerMaxRequests:  1,
+	BreakerInterval:     60,
+	BreakerTimeout:      60,
+	BreakerMinRequests:  3,
+	BreakerFailureRatio: 0.5,
+}
+
+func TestNotificationClientSendsVerificationCode(t *testing.T) {
+	var calls atomic.Int32
+	var got verificationCodeRequest
+	var correlationID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/verification-codes" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		// The first attempt fails with a transient error and is retried
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		correlationID = r.Header.Get(reqctx.CorrelationIDHeader)
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	policy := resilience.NewPolicy("notification-service", testPolicyConfig, zap.NewNop())
+	c := NewNotificationClient(server.URL, policy, zap.NewNop())
+
+	ctx := reqctx.WithCorrelationID(context.Background(), "corr-1")
+	if err := c.SendVerificationCode(ctx, model.ChannelPhone, "+393331234567", "482913"); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 calls, got %d", calls.Load())
+	}
+	if got.Channel != model.ChannelPhone || got.Destination != "+393331234567" || got.Code != "482913" {
+		t.Errorf("unexpected request body %+v", got)
+	}
+	if correlationID != "corr-1" {
+		t.Errorf("expected the correlation ID to be forwarded, got %q", correlationID)
+	}
+}
+
+func TestNotificationClientDoesNotRetryRejectedCodes(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	policy := resilience.NewPolicy("notification-service", testPolicyConfig, zap.NewNop())
+	c := NewNotificationClient(server.URL, policy, zap.NewNop())
+
+	if err := c.SendVerificationCode(context.Background(), model.ChannelEmail, "mario.rossi@example.com", "482913"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a single call, got %d", calls.Load())
+	}
+}
@@ -1,21 +1,37 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"sort"
+	"strings"
+
+	"github.com/comune-roma/bff-julia-shared/configloader"
 )
 
-// Config holds all application configuration
+// Config holds all application configuration.
+// Values come from the defaults below, then application.yaml (or CONFIG_FILE), then the environment variables named
+// by the env tags; see pkg/configloader for the tag semantics.
 type Config struct {
-	Server      ServerConfig
-	CosmosDB    CosmosDBConfig
-	AppConfig   AppConfigConfig
-	Environment string
-	LogLevel    string
-	Auth        AuthConfig
-	Redis       RedisConfig
-	Defaults    DefaultPreferences
+	Server       ServerConfig
+	CosmosDB     CosmosDBConfig
+	AppConfig    AppConfigConfig
+	Environment  string `env:"ENVIRONMENT"`
+	LogLevel     string `env:"LOG_LEVEL" reload:"true"`
+	Auth         AuthConfig
+	Redis        RedisConfig
+	Cache        CacheConfig
+	RateLimit    RateLimitConfig
+	CORS         CORSConfig
+	Resilience   ResilienceConfig
+	Health       HealthConfig
+	Telemetry    TelemetryConfig
+	Verification VerificationConfig
+	Defaults     DefaultPreferences `mapstructure:"-"`
+
+	loader *configloader.Loader
 }
 
 // DefaultPreferences holds default values for user preferences
@@ -31,75 +47,290 @@ type PreferenceDefinition struct {
 }
 
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
-	Enabled  bool
-	TTL      int // in seconds
+	Addr     string `env:"REDIS_ADDR"`
+	Password string `env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `env:"REDIS_DB"`
+	Enabled  bool   `env:"REDIS_ENABLED"`
+}
+
+// Cache backends
+const (
+	CacheBackendNone   = "none"
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+	CacheBackendTiered = "tiered"
+)
+
+// CacheConfig holds the profile cache configuration
+type CacheConfig struct {
+	Backend             string `env:"CACHE_BACKEND"`        // one of the CacheBackend* values
+	TTL                 int    `env:"CACHE_TTL,REDIS_TTL"`  // in seconds, entries older than this are reloaded before being served
+	SoftTTL             int    `env:"CACHE_SOFT_TTL"`       // in seconds, entries older than this are served stale and refreshed in background
+	StaleIfError        int    `env:"CACHE_STALE_IF_ERROR"` // in seconds, how long past TTL an entry may still be served if the database fails
+	NegativeTTL         int    `env:"CACHE_NEGATIVE_TTL"`   // in seconds, lifetime of cached "profile not found" results (0 disables)
+	LocalMaxEntries     int    `env:"CACHE_LOCAL_MAX_ENTRIES"`
+	LocalTTL            int    `env:"CACHE_LOCAL_TTL"` // in seconds, lifetime of L1 entries in the tiered backend
+	InvalidationChannel string `env:"CACHE_INVALIDATION_CHANNEL"`
+}
+
+// CORSConfig holds the cross-origin policy applied to browser clients
+type CORSConfig struct {
+	AllowedOrigins   []string `env:"CORS_ALLOWED_ORIGINS"` // exact origins, "https://*.example.org" wildcards or "*"
+	AllowedMethods   []string `env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string `env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string `env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool     `env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           int      `env:"CORS_MAX_AGE"` // in seconds, how long browsers may cache a preflight response
+}
+
+// Rate limit stores
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// Rate limited route groups
+const (
+	RateLimitGroupRead         = "read"
+	RateLimitGroupWrite        = "write"
+	RateLimitGroupVerification = "verification"
+	RateLimitGroupOperator     = "operator"
+)
+
+// RateLimitConfig holds the per-client rate limiting settings
+type RateLimitConfig struct {
+	Enabled     bool     `env:"RATE_LIMIT_ENABLED"`
+	Store       string   `env:"RATE_LIMIT_STORE"`      // one of the RateLimitStore* values
+	KeyPrefix   string   `env:"RATE_LIMIT_KEY_PREFIX"` // Redis key prefix
+	MaxKeys     int      `env:"RATE_LIMIT_MAX_KEYS"`   // buckets kept by the memory store
+	ExemptCIDRs []string `env:"RATE_LIMIT_EXEMPT_CIDRS"`
+	ExemptRoles []string `env:"RATE_LIMIT_EXEMPT_ROLES"`
+	// Read from RATE_LIMIT_<GROUP>_RATE and RATE_LIMIT_<GROUP>_BURST
+	Groups map[string]RateLimitGroup `env:"RATE_LIMIT" reload:"true"`
+}
+
+// RateLimitGroup is the token bucket applied to each client of a route group
+type RateLimitGroup struct {
+	Rate  float64 `env:"RATE"` // tokens per second
+	Burst int     `env:"BURST"`
+}
+
+// ResilienceConfig holds the resilience policy of each external dependency
+type ResilienceConfig struct {
+	Cosmos       ResiliencePolicyConfig `env:"RESILIENCE_COSMOS"`
+	Redis        ResiliencePolicyConfig `env:"RESILIENCE_REDIS"`
+	Notification ResiliencePolicyConfig `env:"RESILIENCE_NOTIFICATION"`
+}
+
+// ResiliencePolicyConfig configures retries, timeouts, bulkhead and circuit breaker for a dependency.
+// Variables are prefixed by the dependency, e.g. RESILIENCE_COSMOS_MAX_ATTEMPTS.
+type ResiliencePolicyConfig struct {
+	MaxAttempts         int     `env:"MAX_ATTEMPTS"`          // total attempts including the first one
+	InitialBackoff      int     `env:"INITIAL_BACKOFF_MS"`    // in milliseconds, doubled after each failed attempt
+	MaxBackoff          int     `env:"MAX_BACKOFF_MS"`        // in milliseconds
+	AttemptTimeout      int     `env:"ATTEMPT_TIMEOUT_MS"`    // in milliseconds, 0 disables the per-attempt timeout
+	MaxConcurrent       int     `env:"MAX_CONCURRENT"`        // bulkhead size, 0 disables the limit
+	BreakerMaxRequests  int     `env:"BREAKER_MAX_REQUESTS"`  // requests allowed through while half-open
+	BreakerInterval     int     `env:"BREAKER_INTERVAL"`      // in seconds, window after which closed-state counts are reset
+	BreakerTimeout      int     `env:"BREAKER_TIMEOUT"`       // in seconds, time spent open before probing again
+	BreakerMinRequests  int     `env:"BREAKER_MIN_REQUESTS"`  // requests in the window before the breaker may trip
+	BreakerFailureRatio float64 `env:"BREAKER_FAILURE_RATIO"` // failure ratio that trips the breaker
+}
+
+// HealthConfig holds the background health check settings
+type HealthConfig struct {
+	Interval int `env:"HEALTH_CHECK_INTERVAL"` // in seconds
+	Timeout  int `env:"HEALTH_CHECK_TIMEOUT"`  // in seconds, per check
+}
+
+// Trace exporters
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterFile   = "file"
+	TraceExporterOTLP   = "otlp"
+)
+
+// TelemetryConfig holds the OpenTelemetry tracing settings
+type TelemetryConfig struct {
+	Exporter    string  `env:"OTEL_TRACES_EXPORTER"`               // one of the TraceExporter* values
+	Endpoint    string  `env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"` // OTLP/HTTP traces URL, e.g. http://otel-collector:4318/v1/traces
+	FilePath    string  `env:"OTEL_TRACES_FILE"`                   // destination of the "file" exporter
+	ServiceName string  `env:"OTEL_SERVICE_NAME"`
+	Environment string  `env:"ENVIRONMENT"` // defaults to the service environment
+	SampleRatio float64 `env:"OTEL_TRACES_SAMPLER_ARG"`
+}
+
+// Verification code senders
+const (
+	VerificationSenderNotification = "notification" // delivered by the Notification Service, the production sender
+	VerificationSenderLog          = "log"          // development only
+	VerificationSenderFile         = "file"         // development only
+)
+
+// VerificationConfig holds settings for the contact verification flow
+type VerificationConfig struct {
+	CodeLength  int    `env:"VERIFICATION_CODE_LENGTH"`
+	CodeTTL     int    `env:"VERIFICATION_CODE_TTL"` // in seconds
+	MaxAttempts int    `env:"VERIFICATION_MAX_ATTEMPTS"`
+	Sender      string `env:"VERIFICATION_SENDER"` // one of the VerificationSender* values
+	OutboxPath  string `env:"VERIFICATION_OUTBOX_PATH"`
 }
 
 type AuthConfig struct {
-	JWTSecret         string
-	JWTIssuer         string
-	JWTAudience       string
-	ValidationEnabled bool
+	JWTSecret         string `env:"AUTH_JWT_SECRET" secret:"true" reload:"true"`
+	JWTIssuer         string `env:"AUTH_JWT_ISSUER"`
+	JWTAudience       string `env:"AUTH_JWT_AUDIENCE"`
+	ValidationEnabled bool   `env:"AUTH_VALIDATION_ENABLED"`
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	Port string
+	Port           string   `env:"SERVER_PORT"`
+	TrustedProxies []string `env:"TRUSTED_PROXIES"` // proxies whose X-Forwarded-For is trusted to report the client IP
 }
 
 // CosmosDBConfig holds Cosmos DB configuration
 type CosmosDBConfig struct {
-	Endpoint                 string
-	Key                      string
-	Database                 string
-	UserProfileContainer     string
-	UserPreferencesContainer string
-	Emulator                 bool
+	Endpoint                 string `env:"COSMOS_DB_ENDPOINT"`
+	Key                      string `env:"COSMOS_DB_KEY" secret:"true"`
+	Database                 string `env:"COSMOS_DB_DATABASE"`
+	UserProfileContainer     string `env:"COSMOS_DB_PROFILE_CONTAINER"`
+	UserPreferencesContainer string `env:"COSMOS_DB_PREFERENCES_CONTAINER"`
+	Emulator                 bool   `env:"COSMOS_EMULATOR_ENABLED"`
 }
 
 // AppConfigConfig holds Azure App Configuration settings
 type AppConfigConfig struct {
-	Endpoint      string
-	ConnectionStr string
-	LabelFilter   string
+	Endpoint      string `env:"AZURE_APPCONFIG_ENDPOINT"`
+	ConnectionStr string `env:"AZURE_APPCONFIG_CONNECTION_STRING" secret:"true"`
+	LabelFilter   string `env:"AZURE_APPCONFIG_LABEL"`
 }
 
-// LoadConfig loads configuration from environment variables
-func LoadConfig() (*Config, error) {
-	cfg := &Config{
+// LoadConfig loads configuration from the defaults, the configuration file, secret providers and environment variables
+func LoadConfig(ctx context.Context) (*Config, error) {
+	opts, err := loaderOptions()
+	if err != nil {
+		return nil, err
+	}
+	loader := configloader.New(opts)
+	cfg := defaultConfig()
+	if err := loader.Load(ctx, cfg); err != nil {
+		return nil, err
+	}
+	cfg.resolve(loader)
+	cfg.loader = loader
+	return cfg, nil
+}
+
+// loaderOptions reads CONFIG_FILE when set, otherwise an optional application.yaml in the working directory.
+// Secrets may also come from the providers selected by the SECRETS_* variables.
+func loaderOptions() (configloader.Options, error) {
+	providers, refresh, err := configloader.SecretProvidersFromEnv()
+	if err != nil {
+		return configloader.Options{}, err
+	}
+	return configloader.Options{
+		File:            os.Getenv("CONFIG_FILE"),
+		Name:            "application",
+		Paths:           []string{"."},
+		Providers:       providers,
+		RefreshInterval: refresh,
+	}, nil
+}
+
+// defaultConfig returns the configuration used when no source overrides a value
+func defaultConfig() *Config {
+	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8090"),
+			Port: "8090",
 		},
 		CosmosDB: CosmosDBConfig{
-			Endpoint:                 getEnv("COSMOS_DB_ENDPOINT", ""),
-			Key:                      getEnv("COSMOS_DB_KEY", ""),
-			Database:                 getEnv("COSMOS_DB_DATABASE", ""),
-			UserProfileContainer:     getEnv("COSMOS_DB_PROFILE_CONTAINER", "user_profiles"),
-			UserPreferencesContainer: getEnv("COSMOS_DB_PREFERENCES_CONTAINER", "user_preferences"),
-			Emulator:                 getEnvBool("COSMOS_EMULATOR_ENABLED", false),
+			UserProfileContainer:     "user_profiles",
+			UserPreferencesContainer: "user_preferences",
 		},
 		AppConfig: AppConfigConfig{
-			Endpoint:      getEnv("AZURE_APPCONFIG_ENDPOINT", ""),
-			ConnectionStr: getEnv("AZURE_APPCONFIG_CONNECTION_STRING", ""),
-			LabelFilter:   getEnv("AZURE_APPCONFIG_LABEL", "dev"),
+			LabelFilter: "dev",
 		},
-		Environment: getEnv("ENVIRONMENT", "development"),
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		Environment: "development",
+		LogLevel:    "info",
 		Auth: AuthConfig{
-			JWTSecret:         getEnv("AUTH_JWT_SECRET", ""),
-			JWTIssuer:         getEnv("AUTH_JWT_ISSUER", "bff-julia"),
-			JWTAudience:       getEnv("AUTH_JWT_AUDIENCE", "julia-app"),
-			ValidationEnabled: getEnvBool("AUTH_VALIDATION_ENABLED", true),
+			JWTIssuer:         "bff-julia",
+			JWTAudience:       "julia-app",
+			ValidationEnabled: true,
 		},
 		Redis: RedisConfig{
-			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvInt("REDIS_DB", 0),
-			Enabled:  getEnvBool("REDIS_ENABLED", false),
-			TTL:      getEnvInt("REDIS_TTL", 3600),
+			Addr: "localhost:6379",
+		},
+		Cache: CacheConfig{
+			Backend:             CacheBackendNone,
+			TTL:                 3600,
+			SoftTTL:             300,
+			StaleIfError:        3600,
+			NegativeTTL:         30,
+			LocalMaxEntries:     10000,
+			LocalTTL:            60,
+			InvalidationChannel: "bff-julia-profile:cache-invalidation",
+		},
+		CORS: CORSConfig{
+			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Content-Type", "Authorization", "Accept-Language", "X-App-Platform", "X-App-Version", "X-Request-ID", "X-Correlation-ID", "X-Installation-ID"},
+			ExposedHeaders:   []string{"X-Request-ID", "X-Correlation-ID", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining"},
+			AllowCredentials: false,
+			MaxAge:           600,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:     true,
+			Store:       RateLimitStoreMemory,
+			KeyPrefix:   "ratelimit:profile-api:",
+			MaxKeys:     100000,
+			ExemptRoles: []string{"internal"},
+			Groups: map[string]RateLimitGroup{
+				RateLimitGroupRead:         {Rate: 5, Burst: 30},
+				RateLimitGroupWrite:        {Rate: 1, Burst: 10},
+				RateLimitGroupVerification: {Rate: 0.05, Burst: 5},
+				RateLimitGroupOperator:     {Rate: 20, Burst: 50},
+			},
+		},
+		Resilience: ResilienceConfig{
+			Cosmos: defaultResiliencePolicy(ResiliencePolicyConfig{
+				MaxAttempts:    3,
+				InitialBackoff: 100,
+				MaxBackoff:     2000,
+				AttemptTimeout: 5000,
+				MaxConcurrent:  100,
+			}),
+			Redis: defaultResiliencePolicy(ResiliencePolicyConfig{
+				MaxAttempts:    2,
+				InitialBackoff: 20,
+				MaxBackoff:     200,
+				AttemptTimeout: 500,
+				MaxConcurrent:  200,
+			}),
+			Notification: defaultResiliencePolicy(ResiliencePolicyConfig{
+				MaxAttempts:    3,
+				InitialBackoff: 200,
+				MaxBackoff:     5000,
+				AttemptTimeout: 3000,
+				MaxConcurrent:  20,
+			}),
+		},
+		Health: HealthConfig{
+			Interval: 15,
+			Timeout:  3,
+		},
+		Telemetry: TelemetryConfig{
+			Exporter:    TraceExporterNone,
+			FilePath:    "traces.jsonl",
+			ServiceName: "bff-julia-profile-api",
+			Environment: "development",
+			SampleRatio: 1.0,
+		},
+		Verification: VerificationConfig{
+			CodeLength:  6,
+			CodeTTL:     600,
+			MaxAttempts: 5,
+			Sender:      VerificationSenderLog,
 		},
 		Defaults: DefaultPreferences{
 			Chat: []PreferenceDefinition{
@@ -131,61 +362,204 @@ func LoadConfig() (*Config, error) {
 			},
 		},
 	}
+}
 
-	return cfg, nil
+// defaultResiliencePolicy completes a dependency policy with the breaker settings shared by every dependency
+func defaultResiliencePolicy(p ResiliencePolicyConfig) ResiliencePolicyConfig {
+	p.BreakerMaxRequests = 3
+	p.BreakerInterval = 5
+	p.BreakerTimeout = 30
+	p.BreakerMinRequests = 5
+	p.BreakerFailureRatio = 0.6
+	return p
 }
 
-// getEnv gets an environment variable with a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// resolve fills the defaults that depend on other values once every source has been read
+func (c *Config) resolve(loader *configloader.Loader) {
+	// Keep the previous behaviour: Redis cache when Redis is enabled, no cache otherwise
+	if loader.Source("Cache.Backend") == configloader.SourceDefault && c.Redis.Enabled {
+		c.Cache.Backend = CacheBackendRedis
+	}
+	// Any origin is allowed outside production; production origins must be listed explicitly
+	if loader.Source("CORS.AllowedOrigins") == configloader.SourceDefault && c.Environment != "production" {
+		c.CORS.AllowedOrigins = []string{"*"}
+	}
+	// Codes go through the Notification Service in production, the log sender is the development default
+	if loader.Source("Verification.Sender") == configloader.SourceDefault && c.Environment == "production" {
+		c.Verification.Sender = VerificationSenderNotification
+	}
+	if loader.Source("Telemetry.Environment") == configloader.SourceDefault {
+		c.Telemetry.Environment = c.Environment
 	}
-	return defaultValue
 }
 
-// getEnvBool gets a boolean environment variable with a default value
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		boolVal, err := strconv.ParseBool(value)
-		if err != nil {
-			return defaultValue
-		}
-		return boolVal
+// File returns the configuration file in use, empty when the configuration comes from the environment only
+func (c *Config) File() string {
+	if c.loader == nil {
+		return ""
 	}
-	return defaultValue
+	return c.loader.File()
 }
 
-// getEnvInt gets an integer environment variable with a default value
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		intVal, err := strconv.Atoi(value)
+// Dump returns the effective configuration, one "key=value (source)" line per value, with secrets redacted
+func (c *Config) Dump() []string {
+	if c.loader == nil {
+		return configloader.New(configloader.Options{}).Dump(c)
+	}
+	return c.loader.Dump(c)
+}
+
+// Watch reloads the configuration when the file changes and at every SECRETS_REFRESH_INTERVAL, until ctx is done.
+// onReload receives the new configuration and the keys that changed, or the reason the change was rejected:
+// only keys tagged reload:"true" may change while the service runs, the others need a restart.
+func (c *Config) Watch(ctx context.Context, onReload func(next *Config, changed []string, err error)) error {
+	current := c
+	return c.loader.Watch(ctx, func() {
+		next := defaultConfig()
+		changed, err := c.loader.Reload(ctx, current, next, func(loader *configloader.Loader) error {
+			next.resolve(loader)
+			return next.Validate()
+		})
 		if err != nil {
-			return defaultValue
+			onReload(nil, nil, err)
+			return
 		}
-		return intVal
+		next.loader = c.loader
+		current = next
+		onReload(next, changed, nil)
+	})
+}
+
+// Validate checks that the policy values are usable
+func (p ResiliencePolicyConfig) Validate() error {
+	var errs []error
+	if p.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("max attempts must be at least 1"))
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < p.InitialBackoff {
+		errs = append(errs, fmt.Errorf("backoff must be non-negative and max backoff must not be lower than the initial one"))
 	}
-	return defaultValue
+	if p.AttemptTimeout < 0 || p.MaxConcurrent < 0 {
+		errs = append(errs, fmt.Errorf("attempt timeout and max concurrent must not be negative"))
+	}
+	if p.BreakerMaxRequests < 1 || p.BreakerTimeout < 1 {
+		errs = append(errs, fmt.Errorf("breaker max requests and timeout must be positive"))
+	}
+	if p.BreakerFailureRatio <= 0 || p.BreakerFailureRatio > 1 {
+		errs = append(errs, fmt.Errorf("breaker failure ratio must be in (0, 1]"))
+	}
+	return errors.Join(errs...)
 }
 
-// Validate validates the configuration
+// Validate validates the configuration and reports every problem found, not only the first one
 func (c *Config) Validate() error {
+	var errs []error
 	if c.Server.Port == "" {
-		return fmt.Errorf("server port is required")
+		errs = append(errs, fmt.Errorf("SERVER_PORT is required"))
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be one of debug, info, warn, error"))
 	}
 	if c.CosmosDB.Endpoint == "" {
-		return fmt.Errorf("COSMOS_DB_ENDPOINT is required")
+		errs = append(errs, fmt.Errorf("COSMOS_DB_ENDPOINT is required"))
 	}
 	if c.CosmosDB.Key == "" && !c.CosmosDB.Emulator {
-		return fmt.Errorf("COSMOS_DB_KEY is required when emulator is disabled")
+		errs = append(errs, fmt.Errorf("COSMOS_DB_KEY is required when emulator is disabled"))
 	}
 	if c.CosmosDB.Database == "" {
-		return fmt.Errorf("COSMOS_DB_DATABASE is required")
+		errs = append(errs, fmt.Errorf("COSMOS_DB_DATABASE is required"))
 	}
 	if c.AppConfig.ConnectionStr == "" && c.Environment == "production" {
-		return fmt.Errorf("AZURE_APPCONFIG_CONNECTION_STRING is required in production")
+		errs = append(errs, fmt.Errorf("AZURE_APPCONFIG_CONNECTION_STRING is required in production"))
 	}
 	if c.Auth.ValidationEnabled && c.Auth.JWTSecret == "" && c.Environment == "production" {
-		return fmt.Errorf("AUTH_JWT_SECRET is required in production when validation is enabled")
+		errs = append(errs, fmt.Errorf("AUTH_JWT_SECRET is required in production when validation is enabled"))
+	}
+	switch c.Cache.Backend {
+	case CacheBackendNone, CacheBackendMemory:
+	case CacheBackendRedis, CacheBackendTiered:
+		if !c.Redis.Enabled {
+			errs = append(errs, fmt.Errorf("REDIS_ENABLED must be true when CACHE_BACKEND is %s", c.Cache.Backend))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("CACHE_BACKEND must be one of none, memory, redis, tiered"))
+	}
+	if c.Cache.SoftTTL <= 0 || c.Cache.SoftTTL > c.Cache.TTL {
+		errs = append(errs, fmt.Errorf("CACHE_SOFT_TTL must be between 1 and CACHE_TTL"))
+	}
+	if c.Cache.NegativeTTL < 0 || c.Cache.StaleIfError < 0 {
+		errs = append(errs, fmt.Errorf("CACHE_NEGATIVE_TTL and CACHE_STALE_IF_ERROR must not be negative"))
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" && c.CORS.AllowCredentials {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS must list explicit origins when CORS_ALLOW_CREDENTIALS is true"))
+		}
+		if origin == "*" && c.Environment == "production" {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS must not be * in production"))
+		}
+	}
+	switch c.RateLimit.Store {
+	case RateLimitStoreMemory:
+	case RateLimitStoreRedis:
+		if c.RateLimit.Enabled && !c.Redis.Enabled {
+			errs = append(errs, fmt.Errorf("REDIS_ENABLED must be true when RATE_LIMIT_STORE is redis"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE must be one of memory, redis"))
+	}
+	groups := make([]string, 0, len(c.RateLimit.Groups))
+	for name := range c.RateLimit.Groups {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	for _, name := range groups {
+		if group := c.RateLimit.Groups[name]; group.Rate <= 0 || group.Burst < 1 {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_%s_RATE and RATE_LIMIT_%s_BURST must be positive", strings.ToUpper(name), strings.ToUpper(name)))
+		}
+	}
+	for _, policy := range []struct {
+		prefix string
+		cfg    ResiliencePolicyConfig
+	}{
+		{"RESILIENCE_COSMOS", c.Resilience.Cosmos},
+		{"RESILIENCE_REDIS", c.Resilience.Redis},
+		{"RESILIENCE_NOTIFICATION", c.Resilience.Notification},
+	} {
+		if err := policy.cfg.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", policy.prefix, err))
+		}
+	}
+	if c.Health.Interval <= 0 || c.Health.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("HEALTH_CHECK_INTERVAL and HEALTH_CHECK_TIMEOUT must be positive"))
+	}
+	switch c.Telemetry.Exporter {
+	case TraceExporterNone, TraceExporterStdout, TraceExporterFile, TraceExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_EXPORTER must be one of none, stdout, file, otlp"))
+	}
+	if c.Telemetry.SampleRatio < 0 || c.Telemetry.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be between 0 and 1"))
+	}
+	if c.Verification.CodeLength < 4 || c.Verification.CodeLength > 10 {
+		errs = append(errs, fmt.Errorf("VERIFICATION_CODE_LENGTH must be between 4 and 10"))
+	}
+	if c.Verification.CodeTTL <= 0 || c.Verification.MaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("VERIFICATION_CODE_TTL and VERIFICATION_MAX_ATTEMPTS must be positive"))
+	}
+	switch c.Verification.Sender {
+	case VerificationSenderNotification:
+	case VerificationSenderLog, VerificationSenderFile:
+		// Both keep codes where operators can read them, they are meant for local runs and tests
+		if c.Environment == "production" {
+			errs = append(errs, fmt.Errorf("VERIFICATION_SENDER %s is for development only and not allowed in production", c.Verification.Sender))
+		}
+		if c.Verification.Sender == VerificationSenderFile && c.Verification.OutboxPath == "" {
+			errs = append(errs, fmt.Errorf("VERIFICATION_OUTBOX_PATH is required when VERIFICATION_SENDER is file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("VERIFICATION_SENDER must be one of notification, log, file"))
 	}
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/service"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/problem"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultHistoryWindow = 90 * 24 * time.Hour
+	defaultHistoryLimit  = 50
+	maxHistoryLimit      = 500
+)
+
+// AuditHandler handles profile change history requests
+type AuditHandler struct {
+	service *service.AuditService
+	log     *zap.Logger
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(service *service.AuditService, log *zap.Logger) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+		log:     log,
+	}
+}
+
+// GetMyHistory godoc
+// @Summary Get profile change history
+// @Description Get the audit log of profile and preference changes of the authenticated user
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param X-App-Platform header string true "App Platform (iOS/Android)"
+// @Param X-App-Version header string true "App Version (semver)"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
+// @Param from query string false "Start of the range (RFC 3339), defaults to 90 days ago"
+// @Param to query string false "End of the range (RFC 3339), defaults to now"
+// @Param limit query int false "Maximum number of entries (default 50, max 500)"
+// @Success 200 {object} model.AuditHistoryResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/history [get]
+func (h *AuditHandler) GetMyHistory(c *gin.Context) {
+	h.history(c, currentUserID(c))
+}
+
+// GetUserHistory godoc
+// @Summary Get a user's change history (operator)
+// @Description Get the audit log of a user filtered by date range. Requires the operator role.
+// @Tags operator
+// @Accept json
+// @Produce json
+// @Param userId path string true "User ID"
+// @Param from query string false "Start of the range (RFC 3339), defaults to 90 days ago"
+// @Param to query string false "End of the range (RFC 3339), defaults to now"
+// @Param limit query int false "Maximum number of entries (default 50, max 500)"
+// @Success 200 {object} model.AuditHistoryResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /operator/users/{userId}/history [get]
+func (h *AuditHandler) GetUserHistory(c *gin.Context) {
+	reqctx.Logger(c.Request.Context(), h.log).Info("Operator history query",
+		zap.String("operator", currentUserID(c)),
+		zap.String("userID", c.Param("userId")),
+	)
+	h.history(c, c.Param("userId"))
+}
+
+func (h *AuditHandler) history(c *gin.Context, userID string) {
+	to := time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondInvalidQuery(c, "to", "rfc3339", "must be an RFC 3339 timestamp")
+			return
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultHistoryWindow)
+	if v := c.Query("from"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondInvalidQuery(c, "from", "rfc3339", "must be an RFC 3339 timestamp")
+			return
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		respondInvalidQuery(c, "from", "ltefield", "must not be after 'to'")
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxHistoryLimit {
+			respondInvalidQuery(c, "limit", "range", "must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+
+	history, err := h.service.History(c.Request.Context(), userID, from, to, limit)
+	if err != nil {
+		respondError(c, h.log.With(zap.String("userID", userID)), "Failed to get change history", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// respondInvalidQuery writes a validation problem for a single query parameter
+func respondInvalidQuery(c *gin.Context, field, rule, message string) {
+	problem.Respond(c, problem.Validation("Invalid query parameters", []problem.Violation{{Field: field, Rule: rule, Message: message}}))
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/comune-roma/bff-julia-profile-api/pkg/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// normalizer is implemented by request models that canonicalize their fields before validation
+type normalizer interface {
+	Normalize()
+}
+
+// bindJSON decodes the request body into req, normalizes it and validates it.
+// On failure it writes a 400 problem response with the list of violations and returns false.
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		problem.Respond(c, problem.New(problem.KindValidation, problem.CodeMalformedBody, "Request body is not valid JSON"))
+		return false
+	}
+
+	if n, ok := req.(normalizer); ok {
+		n.Normalize()
+	}
+
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		problem.Respond(c, problem.Validation("Request validation failed", toViolations(err)))
+		return false
+	}
+
+	return true
+}
+
+// toViolations converts validator errors into field-level violations
+func toViolations(err error) []problem.Violation {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return []problem.Violation{{Rule: "invalid", Message: "request is invalid"}}
+	}
+
+	violations := make([]problem.Violation, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		violations = append(violations, problem.Violation{
+			Field:   fieldPath(fe.Namespace()),
+			Rule:    fe.Tag(),
+			Message: violationMessage(fe),
+		})
+	}
+	return violations
+}
+
+// fieldPath strips the root struct name from the validator namespace (e.g. "UpdateUserProfileRequest.address.postalCode")
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func violationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		if fe.Kind().String() == "slice" {
+			return fmt.Sprintf("must contain at most %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters long", fe.Param())
+	case "rfc5322":
+		return "must be a valid email address"
+	case "phone_e164":
+		return "must be a valid phone number in international format (e.g. +393331234567)"
+	case "cap_it":
+		return "must be a valid Italian postal code (5 digits)"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	case "bcp47_language_tag":
+		return "must be a valid language tag (e.g. it-IT)"
+	default:
+		return fmt.Sprintf("failed the '%s' rule", fe.Tag())
+	}
+}
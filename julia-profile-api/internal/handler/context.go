@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/internal/service"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUserID is used when token validation is disabled (local runs)
+const defaultUserID = "user-001"
+
+// currentUserID returns the authenticated user, as set by the Auth middleware
+func currentUserID(c *gin.Context) string {
+	if userID := c.GetString("UserID"); userID != "" {
+		return userID
+	}
+	return defaultUserID
+}
+
+// requestContext returns the request context enriched with the caller metadata used by the audit log
+func requestContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	return service.WithRequestMetadata(ctx, model.RequestMetadata{
+		Actor:          currentUserID(c),
+		RequestID:      reqctx.RequestID(ctx),
+		CorrelationID:  reqctx.CorrelationID(ctx),
+		ClientPlatform: c.GetHeader("X-App-Platform"),
+		ClientVersion:  c.GetHeader("X-App-Version"),
+	})
+}
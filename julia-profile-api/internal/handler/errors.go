@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/repository"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/problem"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// respondError maps err to a problem response.
+// Unclassified errors are logged with their cause and returned to the client without internal details.
+func respondError(c *gin.Context, log *zap.Logger, msg string, err error) {
+	p := classify(err)
+	if p.Status() >= 500 {
+		reqctx.Logger(c.Request.Context(), log).Error(msg, zap.String("code", p.Code), zap.Error(err))
+	}
+	problem.Respond(c, p)
+}
+
+// classify returns the problem describing err
+func classify(err error) *problem.Error {
+	var p *problem.Error
+	switch {
+	case errors.As(err, &p):
+		return p
+	case resilience.IsRejected(err), repository.IsUnavailable(err), errors.Is(err, context.DeadlineExceeded):
+		return problem.Wrap(problem.KindDependencyUnavailable, problem.CodeDependencyUnavailable, "A backing service is temporarily unavailable, retry later", err)
+	default:
+		return problem.Wrap(problem.KindInternal, problem.CodeInternal, "", err)
+	}
+}
@@ -32,26 +32,25 @@ func NewInstallationHandler(service *service.UserPreferencesService, log *zap.Lo
 // @Param installationId path string true "Installation ID"
 // @Param X-App-Platform header string true "App Platform (IOS/ANDROID)"
 // @Param X-App-Version header string true "App Version (semver)"
-// @Param X-Request-Id header string true "Request ID"
-// @Param X-Correlation-Id header string true "Correlation ID"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Param request body model.DeviceInstallationRequest true "Installation request"
 // @Success 201 "Created"
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 401 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /users/me/notifications/installations/{installationId} [put]
 func (h *InstallationHandler) UpsertInstallation(c *gin.Context) {
 	installationID := c.Param("installationId")
 	var req model.DeviceInstallationRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Bad Request", Message: err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	userID := "user-001" // TODO: Auth
-	err := h.service.UpsertInstallation(c.Request.Context(), userID, installationID, &req)
+	userID := currentUserID(c)
+	err := h.service.UpsertInstallation(requestContext(c), userID, installationID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Internal Error", Message: err.Error()})
+		respondError(c, h.log, "Failed to upsert installation", err)
 		return
 	}
 
@@ -67,19 +66,19 @@ func (h *InstallationHandler) UpsertInstallation(c *gin.Context) {
 // @Param installationId path string true "Installation ID"
 // @Param X-App-Platform header string true "App Platform (IOS/ANDROID)"
 // @Param X-App-Version header string true "App Version (semver)"
-// @Param X-Request-Id header string true "Request ID"
-// @Param X-Correlation-Id header string true "Correlation ID"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Success 204 "No Content"
-// @Failure 401 {object} model.ErrorResponse
-// @Failure 404 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /users/me/notifications/installations/{installationId} [delete]
 func (h *InstallationHandler) DeleteInstallation(c *gin.Context) {
 	installationID := c.Param("installationId")
-	userID := "user-001" // TODO: Auth
-	err := h.service.DeleteInstallation(c.Request.Context(), userID, installationID)
+	userID := currentUserID(c)
+	err := h.service.DeleteInstallation(requestContext(c), userID, installationID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Internal Error", Message: err.Error()})
+		respondError(c, h.log, "Failed to delete installation", err)
 		return
 	}
 
@@ -5,6 +5,7 @@ import (
 
 	"github.com/comune-roma/bff-julia-profile-api/internal/model"
 	"github.com/comune-roma/bff-julia-profile-api/internal/service"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -31,36 +32,27 @@ func NewUserPreferencesHandler(service *service.UserPreferencesService, log *zap
 // @Produce json
 // @Param X-App-Platform header string true "App Platform (IOS/ANDROID)"
 // @Param X-App-Version header string true "App Version (semver)"
-// @Param X-Request-Id header string true "Request ID"
-// @Param X-Correlation-Id header string true "Correlation ID"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Success 200 {object} model.ChatPreferences
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 404 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /users/me/preferences/chat [get]
 func (h *UserPreferencesHandler) GetUserPreferences(c *gin.Context) {
 	platform := c.GetHeader("X-App-Platform")
 	version := c.GetHeader("X-App-Version")
-	requestID := c.GetHeader("X-Request-Id")
-	correlationID := c.GetHeader("X-Correlation-Id")
 
-	h.log.Info("Getting user preferences",
+	reqctx.Logger(c.Request.Context(), h.log).Info("Getting user preferences",
 		zap.String("platform", platform),
 		zap.String("version", version),
-		zap.String("requestID", requestID),
-		zap.String("correlationID", correlationID),
 	)
 
-	// TODO: Extract user ID from authentication context (JWT)
-	userID := "user-001"
+	userID := currentUserID(c)
 
-	preferences, err := h.service.GetChatPreferences(c.Request.Context(), userID)
+	preferences, err := h.service.GetChatPreferences(requestContext(c), userID)
 	if err != nil {
-		h.log.Error("Failed to get user preferences", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to retrieve user preferences",
-		})
+		respondError(c, h.log, "Failed to get user preferences", err)
 		return
 	}
 
@@ -75,45 +67,32 @@ func (h *UserPreferencesHandler) GetUserPreferences(c *gin.Context) {
 // @Produce json
 // @Param X-App-Platform header string true "App Platform (IOS/ANDROID)"
 // @Param X-App-Version header string true "App Version (semver)"
-// @Param X-Request-Id header string true "Request ID"
-// @Param X-Correlation-Id header string true "Correlation ID"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Param request body model.ChatPreferences true "Update preferences request"
 // @Success 200 {object} model.ChatPreferences
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /users/me/preferences/chat [put]
 func (h *UserPreferencesHandler) UpdateUserPreferences(c *gin.Context) {
 	platform := c.GetHeader("X-App-Platform")
 	version := c.GetHeader("X-App-Version")
-	requestID := c.GetHeader("X-Request-Id")
-	correlationID := c.GetHeader("X-Correlation-Id")
 
-	h.log.Info("Updating user preferences",
+	reqctx.Logger(c.Request.Context(), h.log).Info("Updating user preferences",
 		zap.String("platform", platform),
 		zap.String("version", version),
-		zap.String("requestID", requestID),
-		zap.String("correlationID", correlationID),
 	)
 
 	var req model.ChatPreferences
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	// TODO: Extract user ID from authentication context
-	userID := "user-001"
+	userID := currentUserID(c)
 
-	preferences, err := h.service.UpdateChatPreferences(c.Request.Context(), userID, &req)
+	preferences, err := h.service.UpdateChatPreferences(requestContext(c), userID, &req)
 	if err != nil {
-		h.log.Error("Failed to update user preferences", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to update user preferences",
-		})
+		respondError(c, h.log, "Failed to update user preferences", err)
 		return
 	}
 
@@ -128,18 +107,18 @@ func (h *UserPreferencesHandler) UpdateUserPreferences(c *gin.Context) {
 // @Produce json
 // @Param X-App-Platform header string true "App Platform (IOS/ANDROID)"
 // @Param X-App-Version header string true "App Version (semver)"
-// @Param X-Request-Id header string true "Request ID"
-// @Param X-Correlation-Id header string true "Correlation ID"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Success 200 {object} model.LanguagePreference
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 404 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /users/me/preferences/language [get]
 func (h *UserPreferencesHandler) GetPreferredLanguage(c *gin.Context) {
-	userID := "user-001" // TODO: Auth
-	pref, err := h.service.GetPreferredLanguage(c.Request.Context(), userID)
+	userID := currentUserID(c)
+	pref, err := h.service.GetPreferredLanguage(requestContext(c), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Internal Error", Message: err.Error()})
+		respondError(c, h.log, "Failed to get preferred language", err)
 		return
 	}
 	c.JSON(http.StatusOK, pref)
@@ -153,23 +132,22 @@ func (h *UserPreferencesHandler) GetPreferredLanguage(c *gin.Context) {
 // @Produce json
 // @Param X-App-Platform header string true "App Platform (IOS/ANDROID)"
 // @Param X-App-Version header string true "App Version (semver)"
-// @Param X-Request-Id header string true "Request ID"
-// @Param X-Correlation-Id header string true "Correlation ID"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Param request body model.LanguagePreference true "Language preference"
 // @Success 200 {object} model.LanguagePreference
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /users/me/preferences/language [put]
 func (h *UserPreferencesHandler) SetPreferredLanguage(c *gin.Context) {
 	var req model.LanguagePreference
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Bad Request", Message: err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
-	userID := "user-001" // TODO: Auth
-	pref, err := h.service.UpdatePreferredLanguage(c.Request.Context(), userID, &req)
+	userID := currentUserID(c)
+	pref, err := h.service.UpdatePreferredLanguage(requestContext(c), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Internal Error", Message: err.Error()})
+		respondError(c, h.log, "Failed to set preferred language", err)
 		return
 	}
 	c.JSON(http.StatusOK, pref)
@@ -183,17 +161,17 @@ func (h *UserPreferencesHandler) SetPreferredLanguage(c *gin.Context) {
 // @Produce json
 // @Param X-App-Platform header string true "App Platform (IOS/ANDROID)"
 // @Param X-App-Version header string true "App Version (semver)"
-// @Param X-Request-Id header string true "Request ID"
-// @Param X-Correlation-Id header string true "Correlation ID"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Success 200 {object} model.NotificationPreferences
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /users/me/notifications/preferences [get]
 func (h *UserPreferencesHandler) GetNotificationPreferences(c *gin.Context) {
-	userID := "user-001" // TODO: Auth
-	prefs, err := h.service.GetNotificationPreferences(c.Request.Context(), userID)
+	userID := currentUserID(c)
+	prefs, err := h.service.GetNotificationPreferences(requestContext(c), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Internal Error", Message: err.Error()})
+		respondError(c, h.log, "Failed to get notification preferences", err)
 		return
 	}
 	c.JSON(http.StatusOK, prefs)
@@ -207,23 +185,22 @@ func (h *UserPreferencesHandler) GetNotificationPreferences(c *gin.Context) {
 // @Produce json
 // @Param X-App-Platform header string true "App Platform (IOS/ANDROID)"
 // @Param X-App-Version header string true "App Version (semver)"
-// @Param X-Request-Id header string true "Request ID"
-// @Param X-Correlation-Id header string true "Correlation ID"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
 // @Param request body model.NotificationPreferences true "Notification preferences"
 // @Success 200 {object} model.NotificationPreferences
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /users/me/notifications/preferences [put]
 func (h *UserPreferencesHandler) UpdateNotificationPreferences(c *gin.Context) {
 	var req model.NotificationPreferences
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "Bad Request", Message: err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
-	userID := "user-001" // TODO: Auth
-	prefs, err := h.service.UpdateNotificationPreferences(c.Request.Context(), userID, &req)
+	userID := currentUserID(c)
+	prefs, err := h.service.UpdateNotificationPreferences(requestContext(c), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "Internal Error", Message: err.Error()})
+		respondError(c, h.log, "Failed to update notification preferences", err)
 		return
 	}
 	c.JSON(http.StatusOK, prefs)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/comune-roma/bff-julia-profile-api/internal/model"
 	"github.com/comune-roma/bff-julia-profile-api/internal/service"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -34,33 +35,24 @@ func NewUserProfileHandler(service *service.UserProfileService, log *zap.Logger)
 // @Param X-Request-ID header string false "Request ID"
 // @Param X-Correlation-ID header string false "Correlation ID"
 // @Success 200 {object} model.UserProfileResponse
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 404 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /user/profile [get]
 func (h *UserProfileHandler) GetUserProfile(c *gin.Context) {
 	platform := c.GetHeader("X-App-Platform")
 	version := c.GetHeader("X-App-Version")
-	requestID := c.GetHeader("X-Request-ID")
-	correlationID := c.GetHeader("X-Correlation-ID")
 
-	h.log.Info("Getting user profile",
+	reqctx.Logger(c.Request.Context(), h.log).Info("Getting user profile",
 		zap.String("platform", platform),
 		zap.String("version", version),
-		zap.String("requestID", requestID),
-		zap.String("correlationID", correlationID),
 	)
 
-	// TODO: Extract user ID from authentication context
-	userID := "user-001"
+	userID := currentUserID(c)
 
-	profile, err := h.service.GetUserProfile(c.Request.Context(), userID)
+	profile, err := h.service.GetUserProfile(requestContext(c), userID)
 	if err != nil {
-		h.log.Error("Failed to get user profile", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to retrieve user profile",
-		})
+		respondError(c, h.log, "Failed to get user profile", err)
 		return
 	}
 
@@ -78,42 +70,65 @@ func (h *UserProfileHandler) GetUserProfile(c *gin.Context) {
 // @Param X-Request-ID header string false "Request ID"
 // @Param X-Correlation-ID header string false "Correlation ID"
 // @Param request body model.UpdateUserProfileRequest true "Update profile request"
-// @Success 200 {object} model.UserProfileResponse
-// @Failure 400 {object} model.ErrorResponse
-// @Failure 500 {object} model.ErrorResponse
+// @Success 200 {object} model.UserProfileResponse "Email and phone changes are returned in pendingVerifications until verified"
+// @Failure 400 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /user/profile [put]
 func (h *UserProfileHandler) UpdateUserProfile(c *gin.Context) {
 	platform := c.GetHeader("X-App-Platform")
 	version := c.GetHeader("X-App-Version")
-	requestID := c.GetHeader("X-Request-ID")
-	correlationID := c.GetHeader("X-Correlation-ID")
 
 	var req model.UpdateUserProfileRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "Bad Request",
-			Message: err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	h.log.Info("Updating user profile",
+	reqctx.Logger(c.Request.Context(), h.log).Info("Updating user profile",
 		zap.String("platform", platform),
 		zap.String("version", version),
-		zap.String("requestID", requestID),
-		zap.String("correlationID", correlationID),
 	)
 
-	// TODO: Extract user ID from authentication context
-	userID := "user-001"
+	userID := currentUserID(c)
+
+	profile, err := h.service.UpdateUserProfile(requestContext(c), userID, &req)
+	if err != nil {
+		respondError(c, h.log, "Failed to update user profile", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// VerifyContact godoc
+// @Summary Verify a pending contact change
+// @Description Confirm a pending email or phone change with the one-time code sent to the new contact
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param X-App-Platform header string true "App Platform (iOS/Android)"
+// @Param X-App-Version header string true "App Version (semver)"
+// @Param X-Request-ID header string false "Request ID"
+// @Param X-Correlation-ID header string false "Correlation ID"
+// @Param request body model.VerifyContactRequest true "Verification request"
+// @Success 200 {object} model.UserProfileResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 410 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /users/me/contact/verify [post]
+func (h *UserProfileHandler) VerifyContact(c *gin.Context) {
+	var req model.VerifyContactRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	userID := currentUserID(c)
 
-	profile, err := h.service.UpdateUserProfile(c.Request.Context(), userID, &req)
+	profile, err := h.service.VerifyContact(requestContext(c), userID, &req)
 	if err != nil {
-		h.log.Error("Failed to update user profile", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to update user profile",
-		})
+		respondError(c, h.log, "Failed to verify contact", err)
 		return
 	}
 
@@ -0,0 +1,103 @@
+// Package metrics defines the Prometheus collectors exposed on /metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// HTTPRequests counts requests by method, route template and status class (2xx, 4xx...)
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status class.",
+	}, []string{"method", "route", "status_class"})
+
+	// HTTPRequestDuration observes request latency by method and route template
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route template.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+
+	// HTTPRequestsInFlight reports the requests currently being served
+	HTTPRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+)
+
+// Profile cache lookup results
+const (
+	CacheResultHit         = "hit"
+	CacheResultMiss        = "miss"
+	CacheResultStale       = "stale"
+	CacheResultNegativeHit = "negative_hit"
+	CacheResultStaleError  = "stale_on_error"
+)
+
+var (
+	// ProfileCacheLookups counts profile cache lookups by result
+	ProfileCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "profile_cache_lookups_total",
+		Help: "Profile cache lookups by result (hit, miss, stale, negative_hit, stale_on_error).",
+	}, []string{"result"})
+
+	// ProfileCacheRefreshes counts background refreshes of stale profile entries by outcome
+	ProfileCacheRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "profile_cache_refreshes_total",
+		Help: "Background refreshes of stale profile cache entries by outcome (success, error).",
+	}, []string{"outcome"})
+
+	// ProfileLoadsCoalesced counts profile loads that shared an in-flight database read
+	ProfileLoadsCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "profile_loads_coalesced_total",
+		Help: "Profile loads served by an already in-flight database read.",
+	})
+)
+
+var (
+	// CircuitBreakerState reports the state of each circuit breaker (0 closed, 1 half-open, 2 open)
+	CircuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "resilience_circuit_breaker_state",
+		Help: "Circuit breaker state by dependency: 0 closed, 1 half-open, 2 open.",
+	}, []string{"name"})
+
+	// ResilienceRetries counts retried attempts by dependency
+	ResilienceRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "resilience_retries_total",
+		Help: "Attempts retried by the resilience policy of each dependency.",
+	}, []string{"name"})
+
+	// ResilienceRejections counts calls rejected without reaching the dependency
+	ResilienceRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "resilience_rejections_total",
+		Help: "Calls rejected by the resilience policy by dependency and reason (bulkhead, circuit_open).",
+	}, []string{"name", "reason"})
+)
+
+// PreferenceUpdates counts preference topics switched on or off by users
+var PreferenceUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "preference_updates_total",
+	Help: "Preference topics changed by users, by kind (chat, notification), topic and new state.",
+}, []string{"kind", "topic", "enabled"})
+
+// AuditWriteFailures counts audit entries lost because they could not be computed or written
+var AuditWriteFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "audit_write_failures_total",
+	Help: "Audit entries not written, by resource; the mutation they describe was applied anyway.",
+}, []string{"resource"})
+
+// Rate limit decisions
+const (
+	RateLimitAllowed = "allowed"
+	RateLimitLimited = "limited"
+	RateLimitExempt  = "exempt"
+	RateLimitError   = "error"
+)
+
+// RateLimitDecisions counts rate limiter decisions by route group and outcome
+var RateLimitDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rate_limit_decisions_total",
+	Help: "Rate limiter decisions by route group and outcome (allowed, limited, exempt, error).",
+}, []string{"group", "outcome"})
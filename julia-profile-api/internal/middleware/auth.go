@@ -2,16 +2,43 @@ package middleware
 
 import (
 	"fmt"
-	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Error codes returned by the authentication middleware
+const (
+	CodeMissingToken            = "MISSING_TOKEN"
+	CodeMalformedAuthorization  = "MALFORMED_AUTHORIZATION_HEADER"
+	CodeInvalidToken            = "INVALID_TOKEN"
+	CodeInsufficientPermissions = "INSUFFICIENT_PERMISSIONS"
+)
+
+// SigningKey holds the JWT signing secret so that a rotated AUTH_JWT_SECRET applies without a restart
+type SigningKey struct {
+	secret atomic.Pointer[[]byte]
+}
+
+// NewSigningKey creates a new SigningKey
+func NewSigningKey(secret string) *SigningKey {
+	k := &SigningKey{}
+	k.Set(secret)
+	return k
+}
+
+// Set replaces the secret used to verify the tokens
+func (k *SigningKey) Set(secret string) {
+	b := []byte(secret)
+	k.secret.Store(&b)
+}
+
 // Auth middleware validates the JWT token in the Authorization header
-func Auth(cfg config.AuthConfig) gin.HandlerFunc {
+func Auth(cfg config.AuthConfig, key *SigningKey) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !cfg.ValidationEnabled {
 			c.Next()
@@ -20,13 +47,13 @@ func Auth(cfg config.AuthConfig) gin.HandlerFunc {
 
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			problem.Respond(c, problem.New(problem.KindUnauthorized, CodeMissingToken, "Authorization header is required"))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must be in the format 'Bearer <token>'"})
+			problem.Respond(c, problem.New(problem.KindUnauthorized, CodeMalformedAuthorization, "Authorization header must be in the format 'Bearer <token>'"))
 			return
 		}
 
@@ -35,29 +62,68 @@ func Auth(cfg config.AuthConfig) gin.HandlerFunc {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(cfg.JWTSecret), nil
+			return *key.secret.Load(), nil
 		})
 
 		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			problem.Respond(c, problem.New(problem.KindUnauthorized, CodeInvalidToken, "Invalid or expired token"))
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			problem.Respond(c, problem.New(problem.KindUnauthorized, CodeInvalidToken, "Invalid token claims"))
 			return
 		}
 
 		// Extract subject (usually userID)
 		sub, _ := claims.GetSubject()
 		if sub == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token missing subject claim"})
+			problem.Respond(c, problem.New(problem.KindUnauthorized, CodeInvalidToken, "Token missing subject claim"))
 			return
 		}
 
-		// Set userID in context for subsequent handlers
+		// Set userID and roles in context for subsequent handlers
 		c.Set("UserID", sub)
+		c.Set("Roles", rolesFromClaims(claims))
 		c.Next()
 	}
 }
+
+// RequireRole restricts access to callers whose token carries the given role.
+// Like Auth, it is a no-op when token validation is disabled.
+func RequireRole(cfg config.AuthConfig, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.ValidationEnabled {
+			c.Next()
+			return
+		}
+
+		for _, r := range c.GetStringSlice("Roles") {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+
+		problem.Respond(c, problem.New(problem.KindForbidden, CodeInsufficientPermissions, "Insufficient permissions"))
+	}
+}
+
+// rolesFromClaims reads the "roles" claim, which may be a single string or a list
+func rolesFromClaims(claims jwt.MapClaims) []string {
+	switch v := claims["roles"].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS middleware applies the configured cross-origin policy.
+// Preflight requests are answered directly; requests from origins that are not allowed get no CORS headers,
+// so the browser blocks them.
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	origins := newOriginMatcher(cfg.AllowedOrigins)
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAge)
+	// With credentials the origin must be echoed: "*" is rejected by browsers
+	echoOrigin := !origins.any || cfg.AllowCredentials
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		h := c.Writer.Header()
+
+		if echoOrigin {
+			// The response depends on the Origin header: shared caches must not reuse it across origins
+			h.Add("Vary", "Origin")
+		}
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin == "" || !origins.allows(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if echoOrigin {
+			h.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposed != "" {
+			h.Set("Access-Control-Expose-Headers", exposed)
+		}
+		c.Next()
+	}
+}
+
+// originMatcher checks origins against the allowed list: "*", exact origins,
+// or wildcard subdomains such as "https://*.comune.roma.it"
+type originMatcher struct {
+	any       bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+}
+
+type wildcardOrigin struct {
+	prefix string // scheme, e.g. "https://"
+	suffix string // parent domain with leading dot, e.g. ".comune.roma.it"
+}
+
+func newOriginMatcher(allowed []string) originMatcher {
+	m := originMatcher{exact: make(map[string]bool)}
+	for _, origin := range allowed {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			m.any = true
+		} else if scheme, domain, ok := strings.Cut(origin, "://*."); ok {
+			m.wildcards = append(m.wildcards, wildcardOrigin{prefix: scheme + "://", suffix: "." + domain})
+		} else {
+			m.exact[origin] = true
+		}
+	}
+	return m
+}
+
+func (m originMatcher) allows(origin string) bool {
+	origin = strings.ToLower(origin)
+	if m.any || m.exact[origin] {
+		return true
+	}
+	for _, w := range m.wildcards {
+		if strings.HasPrefix(origin, w.prefix) && strings.HasSuffix(origin, w.suffix) &&
+			len(origin) > len(w.prefix)+len(w.suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func newCORSRouter(cfg config.CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORS(cfg))
+	router.GET("/api/v1/users/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func testCORSConfig() config.CORSConfig {
+	return config.CORSConfig{
+		AllowedOrigins: []string{"https://portale.comune.roma.it", "https://*.julia.example.org"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"Authorization", "X-Request-ID"},
+		ExposedHeaders: []string{"X-Request-ID", "X-Correlation-ID"},
+		MaxAge:         600,
+	}
+}
+
+func serve(router *gin.Engine, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/v1/users/me", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflightAllowedOrigin(t *testing.T) {
+	router := newCORSRouter(testCORSConfig())
+
+	rec := serve(router, http.MethodOptions, "https://portale.comune.roma.it", map[string]string{
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "authorization",
+	})
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	h := rec.Header()
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://portale.comune.roma.it" {
+		t.Errorf("expected the origin to be echoed, got %q", got)
+	}
+	if got := h.Get("Access-Control-Allow-Methods"); got != "GET, PUT" {
+		t.Errorf("unexpected allowed methods: %q", got)
+	}
+	if got := h.Get("Access-Control-Allow-Headers"); got != "Authorization, X-Request-ID" {
+		t.Errorf("unexpected allowed headers: %q", got)
+	}
+	if got := h.Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("unexpected max age: %q", got)
+	}
+	if got := h.Values("Vary"); len(got) != 3 || got[0] != "Origin" {
+		t.Errorf("expected Vary on Origin and the preflight request headers, got %v", got)
+	}
+}
+
+func TestCORSPreflightDisallowedOrigin(t *testing.T) {
+	router := newCORSRouter(testCORSConfig())
+
+	rec := serve(router, http.MethodOptions, "https://evil.example.com", map[string]string{
+		"Access-Control-Request-Method": "PUT",
+	})
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("disallowed origin must not get CORS headers, got %q", got)
+	}
+}
+
+func TestCORSWildcardSubdomain(t *testing.T) {
+	router := newCORSRouter(testCORSConfig())
+
+	if got := serve(router, http.MethodGet, "https://app.julia.example.org", nil).Header().Get("Access-Control-Allow-Origin"); got != "https://app.julia.example.org" {
+		t.Errorf("subdomain should be allowed, got %q", got)
+	}
+	for _, origin := range []string{"https://julia.example.org", "http://app.julia.example.org", "https://app.julia.example.org.evil.com"} {
+		if got := serve(router, http.MethodGet, origin, nil).Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s should not be allowed, got %q", origin, got)
+		}
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	router := newCORSRouter(testCORSConfig())
+
+	rec := serve(router, http.MethodGet, "https://portale.comune.roma.it", nil)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to reach the handler, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID, X-Correlation-ID" {
+		t.Errorf("unexpected exposed headers: %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("expected Vary: Origin, got %q", got)
+	}
+}
+
+func TestCORSWildcardOrigin(t *testing.T) {
+	cfg := testCORSConfig()
+	cfg.AllowedOrigins = []string{"*"}
+	router := newCORSRouter(cfg)
+
+	rec := serve(router, http.MethodGet, "https://anywhere.example.com", nil)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected *, got %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "" {
+		t.Errorf("a wildcard response does not vary by origin, got Vary %q", got)
+	}
+
+	cfg.AllowCredentials = true
+	rec = serve(newCORSRouter(cfg), http.MethodGet, "https://anywhere.example.com", nil)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://anywhere.example.com" {
+		t.Errorf("with credentials the origin must be echoed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("expected credentials to be allowed, got %q", got)
+	}
+}
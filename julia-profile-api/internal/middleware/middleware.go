@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/comune-roma/bff-julia-profile-api/internal/metrics"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/problem"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -18,7 +22,7 @@ func Logger(log *zap.Logger) gin.HandlerFunc {
 
 		latency := time.Since(start)
 
-		log.Info("HTTP Request",
+		reqctx.Logger(c.Request.Context(), log).Info("HTTP Request",
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.String("query", query),
@@ -30,60 +34,66 @@ func Logger(log *zap.Logger) gin.HandlerFunc {
 	}
 }
 
+// Metrics middleware records request count, latency and status class per route.
+// The route template (e.g. /users/:userId/history) is used instead of the raw path to keep label cardinality bounded.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, statusClass(c.Writer.Status())).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
 // Recovery middleware for panic recovery
 func Recovery(log *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Error("Panic recovered",
+				reqctx.Logger(c.Request.Context(), log).Error("Panic recovered",
 					zap.Any("error", err),
 					zap.String("path", c.Request.URL.Path),
 				)
-				c.AbortWithStatus(500)
+				problem.Respond(c, problem.New(problem.KindInternal, problem.CodeInternal, ""))
 			}
 		}()
 		c.Next()
 	}
 }
 
-// CORS middleware
-func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-App-Platform, X-App-Version, X-Request-ID, X-Correlation-ID")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	}
-}
-
-// RequestID middleware adds a unique request ID
+// RequestID middleware assigns the request and correlation IDs.
+// Incoming X-Request-ID and X-Correlation-ID headers are honoured; missing ones are generated
+// (the correlation ID defaults to the request ID). Both are stored on the request context and echoed in the response.
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requestID := c.GetHeader("X-Request-ID")
+		requestID := c.GetHeader(reqctx.RequestIDHeader)
 		if requestID == "" {
-			requestID = generateRequestID()
+			requestID = reqctx.NewID()
+		}
+		correlationID := c.GetHeader(reqctx.CorrelationIDHeader)
+		if correlationID == "" {
+			correlationID = requestID
 		}
-		c.Set("RequestID", requestID)
-		c.Writer.Header().Set("X-Request-ID", requestID)
-		c.Next()
-	}
-}
 
-func generateRequestID() string {
-	return time.Now().Format("20060102150405") + "-" + randomString(8)
-}
+		ctx := reqctx.WithRequestID(c.Request.Context(), requestID)
+		ctx = reqctx.WithCorrelationID(ctx, correlationID)
+		c.Request = c.Request.WithContext(ctx)
 
-func randomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+		c.Set("RequestID", requestID)
+		c.Writer.Header().Set(reqctx.RequestIDHeader, requestID)
+		c.Writer.Header().Set(reqctx.CorrelationIDHeader, correlationID)
+		c.Next()
 	}
-	return string(b)
 }
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/metrics"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/problem"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/ratelimit"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CodeRateLimited is returned when a client exceeds the rate limit of a route group
+const CodeRateLimited = "RATE_LIMITED"
+
+// InstallationIDHeader identifies the device on routes that carry no installation ID in the path
+const InstallationIDHeader = "X-Installation-ID"
+
+// RateLimiter applies a token bucket per client to each route group.
+// Clients are identified by user ID (from the JWT), then installation ID, then IP address.
+type RateLimiter struct {
+	store       ratelimit.Store
+	cfg         config.RateLimitConfig
+	limits      atomic.Pointer[map[string]ratelimit.Limit]
+	exemptNets  []*net.IPNet
+	exemptRoles map[string]bool
+	log         *zap.Logger
+}
+
+// NewRateLimiter creates a new RateLimiter
+func NewRateLimiter(store ratelimit.Store, cfg config.RateLimitConfig, log *zap.Logger) (*RateLimiter, error) {
+	l := &RateLimiter{
+		store:       store,
+		cfg:         cfg,
+		exemptRoles: make(map[string]bool, len(cfg.ExemptRoles)),
+		log:         log,
+	}
+	for _, cidr := range cfg.ExemptCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse exempt CIDR %q: %w", cidr, err)
+		}
+		l.exemptNets = append(l.exemptNets, ipNet)
+	}
+	for _, role := range cfg.ExemptRoles {
+		l.exemptRoles[role] = true
+	}
+	l.SetGroups(cfg.Groups)
+	return l, nil
+}
+
+// SetGroups replaces the rate and burst of the route groups, e.g. after a configuration reload.
+// Groups that had no limit when the routes were registered stay unlimited.
+func (l *RateLimiter) SetGroups(groups map[string]config.RateLimitGroup) {
+	limits := make(map[string]ratelimit.Limit, len(groups))
+	for name, group := range groups {
+		limits[name] = ratelimit.Limit{Rate: group.Rate, Burst: group.Burst}
+	}
+	l.limits.Store(&limits)
+}
+
+// Limit returns the middleware enforcing the limit of the given route group.
+// It must run after Auth so that the user ID is known. Store failures let the request through.
+func (l *RateLimiter) Limit(group string) gin.HandlerFunc {
+	if _, ok := l.cfg.Groups[group]; !l.cfg.Enabled || !ok {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		limit, ok := (*l.limits.Load())[group]
+		if !ok {
+			c.Next()
+			return
+		}
+		if l.isExempt(c) {
+			metrics.RateLimitDecisions.WithLabelValues(group, metrics.RateLimitExempt).Inc()
+			c.Next()
+			return
+		}
+
+		res, err := l.store.Take(c.Request.Context(), group+":"+clientKey(c), limit)
+		if err != nil {
+			metrics.RateLimitDecisions.WithLabelValues(group, metrics.RateLimitError).Inc()
+			reqctx.Logger(c.Request.Context(), l.log).Warn("Rate limit store unavailable, allowing request", zap.String("group", group), zap.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		if !res.Allowed {
+			metrics.RateLimitDecisions.WithLabelValues(group, metrics.RateLimitLimited).Inc()
+			c.Header("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(res.RetryAfter.Seconds())))))
+			problem.Respond(c, problem.New(problem.KindTooManyRequests, CodeRateLimited, "Too many requests, retry later"))
+			return
+		}
+
+		metrics.RateLimitDecisions.WithLabelValues(group, metrics.RateLimitAllowed).Inc()
+		c.Next()
+	}
+}
+
+// isExempt reports whether the caller is an internal one: an exempt network or an exempt token role
+func (l *RateLimiter) isExempt(c *gin.Context) bool {
+	for _, role := range c.GetStringSlice("Roles") {
+		if l.exemptRoles[role] {
+			return true
+		}
+	}
+	if ip := net.ParseIP(c.ClientIP()); ip != nil {
+		for _, ipNet := range l.exemptNets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clientKey identifies the caller, preferring the most specific identity available
+func clientKey(c *gin.Context) string {
+	if userID := c.GetString("UserID"); userID != "" {
+		return "user:" + userID
+	}
+	if installationID := c.Param("installationId"); installationID != "" {
+		return "device:" + installationID
+	}
+	if installationID := c.GetHeader(InstallationIDHeader); installationID != "" {
+		return "device:" + installationID
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// AuditAction identifies the kind of mutation recorded in the audit log
+type AuditAction string
+
+const (
+	AuditActionCreate AuditAction = "CREATE"
+	AuditActionUpdate AuditAction = "UPDATE"
+	AuditActionDelete AuditAction = "DELETE"
+	AuditActionVerify AuditAction = "VERIFY"
+)
+
+// FieldChange represents a single field-level difference between two versions of a resource
+type FieldChange struct {
+	Field    string      `json:"field"`
+	OldValue interface{} `json:"oldValue,omitempty"`
+	NewValue interface{} `json:"newValue,omitempty"`
+}
+
+// AuditEntry represents an append-only record of a profile or preference mutation
+type AuditEntry struct {
+	ID             string        `json:"id"`
+	UserID         string        `json:"userId"`
+	Actor          string        `json:"actor"`
+	Action         AuditAction   `json:"action"`
+	Resource       string        `json:"resource"`
+	Changes        []FieldChange `json:"changes"`
+	RequestID      string        `json:"requestId,omitempty"`
+	CorrelationID  string        `json:"correlationId,omitempty"`
+	ClientPlatform string        `json:"clientPlatform,omitempty"`
+	ClientVersion  string        `json:"clientVersion,omitempty"`
+	Timestamp      time.Time     `json:"timestamp"`
+	TimestampMs    int64         `json:"timestampMs"` // used for range queries
+}
+
+// AuditHistoryResponse represents a page of audit entries
+type AuditHistoryResponse struct {
+	UserID  string       `json:"userId"`
+	From    time.Time    `json:"from"`
+	To      time.Time    `json:"to"`
+	Entries []AuditEntry `json:"entries"`
+}
+
+// RequestMetadata carries who performed a request and from which client
+type RequestMetadata struct {
+	Actor          string
+	RequestID      string
+	CorrelationID  string
+	ClientPlatform string
+	ClientVersion  string
+}
@@ -10,7 +10,7 @@ const (
 
 // DeviceInstallationRequest represents the request to register/update a device installation
 type DeviceInstallationRequest struct {
-	Platform    InstallationPlatform `json:"platform"`
-	PushChannel string               `json:"pushChannel"`
-	Language    string               `json:"language"`
+	Platform    InstallationPlatform `json:"platform" binding:"required,oneof=FCM APNS"`
+	PushChannel string               `json:"pushChannel" binding:"required,max=4096"`
+	Language    string               `json:"language" binding:"omitempty,max=35,bcp47_language_tag"`
 }
@@ -11,37 +11,37 @@ const (
 
 // UserPreference represents a single user preference
 type UserPreference struct {
-	ID       string                 `json:"id"`
+	ID       string                 `json:"id" binding:"required,max=64"`
 	Category UserPreferenceCategory `json:"category,omitempty"`
 	Enabled  bool                   `json:"enabled"`
 }
 
 // CustomPreference represents a user's custom preference
 type CustomPreference struct {
-	Description string `json:"description,omitempty"`
+	Description string `json:"description,omitempty" binding:"max=500"`
 }
 
 // ChatPreferences represents the response with user preferences
 type ChatPreferences struct {
-	Preferences      []UserPreference  `json:"preferences"`
+	Preferences      []UserPreference  `json:"preferences" binding:"max=100,dive"`
 	CustomPreference *CustomPreference `json:"customPreference,omitempty"`
 }
 
 // LanguagePreference represents the user's preferred language
 type LanguagePreference struct {
-	Language string `json:"language,omitempty"`
+	Language string `json:"language,omitempty" binding:"omitempty,max=35,bcp47_language_tag"`
 }
 
 // NotificationPreferenceItem represents a single notification setting
 type NotificationPreferenceItem struct {
-	ID      string `json:"id"`
+	ID      string `json:"id" binding:"required,max=64"`
 	Enabled bool   `json:"enabled"`
 }
 
 // NotificationPreferences represents the user's notification preferences
 type NotificationPreferences struct {
-	Notifications []NotificationPreferenceItem `json:"notifications"`
-	Language      string                       `json:"language,omitempty"`
+	Notifications []NotificationPreferenceItem `json:"notifications" binding:"max=100,dive"`
+	Language      string                       `json:"language,omitempty" binding:"omitempty,max=35,bcp47_language_tag"`
 }
 
 // UserPreferenceUpdate represents a preference update in a request (keeping it for internal use if needed, but ChatPreferences is used in API)
@@ -56,9 +56,3 @@ type UpdateUserPreferencesRequest struct {
 	Preferences      []UserPreferenceUpdate `json:"preferences"`
 	CustomPreference *CustomPreference      `json:"customPreference,omitempty"`
 }
-
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
-}
@@ -1,13 +1,18 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/validation"
+)
 
 // Address represents a user's address
 type Address struct {
-	Street     string `json:"street"`
-	City       string `json:"city"`
-	PostalCode string `json:"postalCode"`
-	Country    string `json:"country"`
+	Street     string `json:"street" binding:"max=200"`
+	City       string `json:"city" binding:"max=100"`
+	PostalCode string `json:"postalCode" binding:"max=10,cap_it"`
+	Country    string `json:"country" binding:"max=56"`
 }
 
 // UserProfile represents a user's profile information
@@ -25,22 +30,38 @@ type UserProfile struct {
 
 // UpdateUserProfileRequest represents the request to update user profile
 type UpdateUserProfileRequest struct {
-	FirstName string   `json:"firstName,omitempty"`
-	LastName  string   `json:"lastName,omitempty"`
-	Email     string   `json:"email,omitempty"`
-	Phone     string   `json:"phone,omitempty"`
+	FirstName string   `json:"firstName,omitempty" binding:"max=100"`
+	LastName  string   `json:"lastName,omitempty" binding:"max=100"`
+	Email     string   `json:"email,omitempty" binding:"omitempty,max=254,rfc5322"`
+	Phone     string   `json:"phone,omitempty" binding:"omitempty,phone_e164"`
 	Address   *Address `json:"address,omitempty"`
 }
 
+// Normalize trims the request fields and converts contact data into canonical form
+func (r *UpdateUserProfileRequest) Normalize() {
+	r.FirstName = strings.TrimSpace(r.FirstName)
+	r.LastName = strings.TrimSpace(r.LastName)
+	r.Email = validation.NormalizeEmail(r.Email)
+	r.Phone = validation.NormalizePhone(r.Phone)
+
+	if r.Address != nil {
+		r.Address.Street = strings.TrimSpace(r.Address.Street)
+		r.Address.City = strings.TrimSpace(r.Address.City)
+		r.Address.PostalCode = strings.TrimSpace(r.Address.PostalCode)
+		r.Address.Country = strings.TrimSpace(r.Address.Country)
+	}
+}
+
 // UserProfileResponse represents the response with user profile
 type UserProfileResponse struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"userId"`
-	FirstName string    `json:"firstName"`
-	LastName  string    `json:"lastName"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone,omitempty"`
-	Address   *Address  `json:"address,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID                   string                 `json:"id"`
+	UserID               string                 `json:"userId"`
+	FirstName            string                 `json:"firstName"`
+	LastName             string                 `json:"lastName"`
+	Email                string                 `json:"email"`
+	Phone                string                 `json:"phone,omitempty"`
+	Address              *Address               `json:"address,omitempty"`
+	PendingVerifications []PendingContactChange `json:"pendingVerifications,omitempty"`
+	CreatedAt            time.Time              `json:"createdAt"`
+	UpdatedAt            time.Time              `json:"updatedAt"`
 }
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// ContactChannel identifies the contact field being verified
+type ContactChannel string
+
+const (
+	ChannelEmail ContactChannel = "email"
+	ChannelPhone ContactChannel = "phone"
+)
+
+// ContactVerification represents a pending contact change awaiting proof of ownership
+type ContactVerification struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"userId"`
+	Channel   ContactChannel `json:"channel"`
+	Value     string         `json:"value"`
+	CodeHash  string         `json:"codeHash"`
+	Attempts  int            `json:"attempts"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+	CreatedAt time.Time      `json:"createdAt"`
+	TTL       int            `json:"ttl,omitempty"` // Cosmos DB item time-to-live in seconds
+}
+
+// PendingContactChange describes a contact change that takes effect once verified
+type PendingContactChange struct {
+	Channel   ContactChannel `json:"channel"`
+	Value     string         `json:"value"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+}
+
+// VerifyContactRequest represents the request to confirm a pending contact change
+type VerifyContactRequest struct {
+	Channel ContactChannel `json:"channel" binding:"required,oneof=email phone"`
+	Code    string         `json:"code" binding:"required,numeric,max=10"`
+}
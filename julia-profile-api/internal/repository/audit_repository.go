@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// AuditRepository handles Cosmos DB operations for the append-only audit log
+type AuditRepository struct {
+	client    *azcosmos.Client
+	database  string
+	container string
+}
+
+// NewAuditRepository creates a new AuditRepository
+func NewAuditRepository(client *azcosmos.Client, database string) *AuditRepository {
+	return &AuditRepository{
+		client:    client,
+		database:  database,
+		container: "audit_log",
+	}
+}
+
+// CreateEntry appends an audit entry to Cosmos DB. Entries are never replaced or deleted.
+// The write is an upsert of an entry with a unique ID, so that it can be retried after a timeout.
+func (r *AuditRepository) CreateEntry(ctx context.Context, userID string, entry interface{}) error {
+	containerClient, err := r.client.NewContainer(r.database, r.container)
+	if err != nil {
+		return fmt.Errorf("failed to get container client: %w", err)
+	}
+
+	marshalledItem, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	pk := azcosmos.NewPartitionKeyString(userID)
+	_, err = containerClient.UpsertItem(ctx, pk, marshalledItem, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// QueryEntries retrieves the audit entries of a user within a time range, newest first
+func (r *AuditRepository) QueryEntries(ctx context.Context, userID string, from, to time.Time, limit int) ([][]byte, error) {
+	containerClient, err := r.client.NewContainer(r.database, r.container)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container client: %w", err)
+	}
+
+	query := "SELECT * FROM c WHERE c.userId = @userId AND c.timestampMs >= @from AND c.timestampMs <= @to " +
+		"ORDER BY c.timestampMs DESC OFFSET 0 LIMIT @limit"
+
+	pk := azcosmos.NewPartitionKeyString(userID)
+	pager := containerClient.NewQueryItemsPager(query, pk, &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@userId", Value: userID},
+			{Name: "@from", Value: from.UnixMilli()},
+			{Name: "@to", Value: to.UnixMilli()},
+			{Name: "@limit", Value: limit},
+		},
+	})
+
+	var items [][]byte
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query audit entries: %w", err)
+		}
+		items = append(items, page.Items...)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// ErrNotFound is returned when the requested document does not exist
+var ErrNotFound = errors.New("document not found")
+
+// ErrPreconditionFailed is returned when a conditional write finds the document changed since it was read
+var ErrPreconditionFailed = errors.New("document changed since it was read")
+
+// isNotFound reports whether a Cosmos DB error is a 404 response
+func isNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+// isPreconditionFailed reports whether a Cosmos DB error is a 412 response to an If-Match write
+func isPreconditionFailed(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusPreconditionFailed
+}
+
+// IsUnavailable reports whether a Cosmos DB error means the database could not serve the request
+// (throttling or a server-side failure that outlasted the retries)
+func IsUnavailable(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && (respErr.StatusCode == http.StatusTooManyRequests || respErr.StatusCode >= http.StatusInternalServerError)
+}
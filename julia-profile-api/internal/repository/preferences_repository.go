@@ -34,6 +34,9 @@ func (r *UserPreferencesRepository) GetPreferences(ctx context.Context, userID s
 	pk := azcosmos.NewPartitionKeyString(userID)
 	resp, err := containerClient.ReadItem(ctx, pk, userID, nil)
 	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
 		return nil, fmt.Errorf("failed to read preferences: %w", err)
 	}
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
 )
 
@@ -24,20 +25,23 @@ func NewUserProfileRepository(client *azcosmos.Client, database string) *UserPro
 	}
 }
 
-// GetProfile retrieves a user profile from Cosmos DB
-func (r *UserProfileRepository) GetProfile(ctx context.Context, userID string) ([]byte, error) {
+// GetProfile retrieves a user profile from Cosmos DB, with the ETag to pass to ReplaceProfile
+func (r *UserProfileRepository) GetProfile(ctx context.Context, userID string) ([]byte, azcore.ETag, error) {
 	containerClient, err := r.client.NewContainer(r.database, r.container)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get container client: %w", err)
+		return nil, "", fmt.Errorf("failed to get container client: %w", err)
 	}
 
 	pk := azcosmos.NewPartitionKeyString(userID)
 	resp, err := containerClient.ReadItem(ctx, pk, userID, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read profile: %w", err)
+		if isNotFound(err) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("failed to read profile: %w", err)
 	}
 
-	return resp.Value, nil
+	return resp.Value, resp.ETag, nil
 }
 
 // CreateProfile creates a new user profile in Cosmos DB
@@ -82,6 +86,34 @@ func (r *UserProfileRepository) UpdateProfile(ctx context.Context, userID string
 	return nil
 }
 
+// ReplaceProfile replaces a user profile only if it still has the given ETag,
+// returning ErrPreconditionFailed when another request changed it in the meantime
+func (r *UserProfileRepository) ReplaceProfile(ctx context.Context, userID string, profile interface{}, etag azcore.ETag) error {
+	containerClient, err := r.client.NewContainer(r.database, r.container)
+	if err != nil {
+		return fmt.Errorf("failed to get container client: %w", err)
+	}
+
+	marshalledItem, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+
+	pk := azcosmos.NewPartitionKeyString(userID)
+	_, err = containerClient.ReplaceItem(ctx, pk, userID, marshalledItem, &azcosmos.ItemOptions{IfMatchEtag: &etag})
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return ErrPreconditionFailed
+		}
+		if isNotFound(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to replace profile: %w", err)
+	}
+
+	return nil
+}
+
 // DeleteProfile deletes a user profile from Cosmos DB
 func (r *UserProfileRepository) DeleteProfile(ctx context.Context, userID string) error {
 	containerClient, err := r.client.NewContainer(r.database, r.container)
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// ContactVerificationRepository handles Cosmos DB operations for pending contact verifications
+type ContactVerificationRepository struct {
+	client    *azcosmos.Client
+	database  string
+	container string
+}
+
+// NewContactVerificationRepository creates a new ContactVerificationRepository
+func NewContactVerificationRepository(client *azcosmos.Client, database string) *ContactVerificationRepository {
+	return &ContactVerificationRepository{
+		client:    client,
+		database:  database,
+		container: "contact_verifications",
+	}
+}
+
+// GetVerification retrieves a pending verification from Cosmos DB, with the ETag to pass to ReplaceVerification
+func (r *ContactVerificationRepository) GetVerification(ctx context.Context, userID, id string) ([]byte, azcore.ETag, error) {
+	containerClient, err := r.client.NewContainer(r.database, r.container)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get container client: %w", err)
+	}
+
+	pk := azcosmos.NewPartitionKeyString(userID)
+	resp, err := containerClient.ReadItem(ctx, pk, id, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("failed to read verification: %w", err)
+	}
+
+	return resp.Value, resp.ETag, nil
+}
+
+// UpsertVerification creates or replaces a pending verification in Cosmos DB
+func (r *ContactVerificationRepository) UpsertVerification(ctx context.Context, userID string, verification interface{}) error {
+	containerClient, err := r.client.NewContainer(r.database, r.container)
+	if err != nil {
+		return fmt.Errorf("failed to get container client: %w", err)
+	}
+
+	marshalledItem, err := json.Marshal(verification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification: %w", err)
+	}
+
+	pk := azcosmos.NewPartitionKeyString(userID)
+	_, err = containerClient.UpsertItem(ctx, pk, marshalledItem, nil)
+	if err != nil {
+		return fmt.Errorf("failed to upsert verification: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceVerification replaces a pending verification only if it still has the given ETag,
+// returning ErrPreconditionFailed when another request changed it in the meantime
+func (r *ContactVerificationRepository) ReplaceVerification(ctx context.Context, userID, id string, verification interface{}, etag azcore.ETag) error {
+	containerClient, err := r.client.NewContainer(r.database, r.container)
+	if err != nil {
+		return fmt.Errorf("failed to get container client: %w", err)
+	}
+
+	marshalledItem, err := json.Marshal(verification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification: %w", err)
+	}
+
+	pk := azcosmos.NewPartitionKeyString(userID)
+	_, err = containerClient.ReplaceItem(ctx, pk, id, marshalledItem, &azcosmos.ItemOptions{IfMatchEtag: &etag})
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return ErrPreconditionFailed
+		}
+		if isNotFound(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to replace verification: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteVerification deletes a pending verification from Cosmos DB
+func (r *ContactVerificationRepository) DeleteVerification(ctx context.Context, userID, id string) error {
+	containerClient, err := r.client.NewContainer(r.database, r.container)
+	if err != nil {
+		return fmt.Errorf("failed to get container client: %w", err)
+	}
+
+	pk := azcosmos.NewPartitionKeyString(userID)
+	_, err = containerClient.DeleteItem(ctx, pk, id, nil)
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete verification: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/metrics"
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/internal/repository"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Resources tracked by the audit log
+const (
+	AuditResourceProfile                 = "profile"
+	AuditResourceChatPreferences         = "preferences.chat"
+	AuditResourceLanguagePreference      = "preferences.language"
+	AuditResourceNotificationPreferences = "preferences.notifications"
+	AuditResourceInstallation            = "installation"
+)
+
+// ignoredAuditFields are bookkeeping fields that change on every write
+var ignoredAuditFields = map[string]bool{
+	"updatedAt": true,
+}
+
+type requestMetadataKey struct{}
+
+// WithRequestMetadata attaches the caller metadata to the context so mutations can be audited
+func WithRequestMetadata(ctx context.Context, md model.RequestMetadata) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, md)
+}
+
+func requestMetadataFrom(ctx context.Context) model.RequestMetadata {
+	md, _ := ctx.Value(requestMetadataKey{}).(model.RequestMetadata)
+	return md
+}
+
+// AuditService records profile and preference mutations in the audit log
+type AuditService struct {
+	repo *repository.AuditRepository
+	log  *zap.Logger
+}
+
+// NewAuditService creates a new AuditService
+func NewAuditService(repo *repository.AuditRepository, log *zap.Logger) *AuditService {
+	return &AuditService{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// Record stores an audit entry with the field-level diff between before and after.
+// Nothing is written when the two versions are identical. Failures do not fail the mutation, which has
+// already been applied: they are logged and counted in audit_write_failures_total, which is alerted on.
+func (s *AuditService) Record(ctx context.Context, userID string, action model.AuditAction, resource string, before, after interface{}) {
+	changes, err := diffFields(before, after)
+	if err != nil {
+		reqctx.Logger(ctx, s.log).Error("Failed to compute audit diff", zap.String("resource", resource), zap.Error(err))
+		metrics.AuditWriteFailures.WithLabelValues(resource).Inc()
+		return
+	}
+	if len(changes) == 0 && action == model.AuditActionUpdate {
+		return
+	}
+
+	md := requestMetadataFrom(ctx)
+	actor := md.Actor
+	if actor == "" {
+		actor = userID
+	}
+
+	now := time.Now().UTC()
+	entry := &model.AuditEntry{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		Actor:          actor,
+		Action:         action,
+		Resource:       resource,
+		Changes:        changes,
+		RequestID:      md.RequestID,
+		CorrelationID:  md.CorrelationID,
+		ClientPlatform: md.ClientPlatform,
+		ClientVersion:  md.ClientVersion,
+		Timestamp:      now,
+		TimestampMs:    now.UnixMilli(),
+	}
+
+	if err := s.repo.CreateEntry(ctx, userID, entry); err != nil {
+		reqctx.Logger(ctx, s.log).Error("Failed to write audit entry",
+			zap.String("userID", userID),
+			zap.String("resource", resource),
+			zap.Error(err),
+		)
+		metrics.AuditWriteFailures.WithLabelValues(resource).Inc()
+	}
+}
+
+// History returns the audit entries of a user within the given time range, newest first
+func (s *AuditService) History(ctx context.Context, userID string, from, to time.Time, limit int) (*model.AuditHistoryResponse, error) {
+	items, err := s.repo.QueryEntries(ctx, userID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]model.AuditEntry, 0, len(items))
+	for _, item := range items {
+		var entry model.AuditEntry
+		if err := json.Unmarshal(item, &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return &model.AuditHistoryResponse{
+		UserID:  userID,
+		From:    from,
+		To:      to,
+		Entries: entries,
+	}, nil
+}
+
+// diffFields compares the JSON representations of two values and returns the changed leaf fields
+func diffFields(before, after interface{}) ([]model.FieldChange, error) {
+	oldFields, err := flattenJSON(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := flattenJSON(after)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(oldFields)+len(newFields))
+	for k := range oldFields {
+		keys[k] = true
+	}
+	for k := range newFields {
+		keys[k] = true
+	}
+
+	changes := []model.FieldChange{}
+	for k := range keys {
+		if ignoredAuditFields[k] {
+			continue
+		}
+		oldValue, newValue := oldFields[k], newFields[k]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, model.FieldChange{Field: k, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// flattenJSON maps a value to "path" -> leaf value. Array elements carrying an "id" are keyed
+// by it (e.g. "preferences[documents].enabled") so reordering does not show up as a change.
+func flattenJSON(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit value: %w", err)
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit value: %w", err)
+	}
+
+	flattenInto(fields, "", generic)
+	return fields, nil
+}
+
+func flattenInto(fields map[string]interface{}, prefix string, v interface{}) {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		for k, child := range typed {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			flattenInto(fields, path, child)
+		}
+	case []interface{}:
+		for i, child := range typed {
+			key := fmt.Sprintf("%d", i)
+			if obj, ok := child.(map[string]interface{}); ok {
+				if id, ok := obj["id"].(string); ok && id != "" {
+					key = id
+				}
+			}
+			flattenInto(fields, fmt.Sprintf("%s[%s]", prefix, key), child)
+		}
+	case nil:
+		// Absent and null values are treated the same
+	default:
+		fields[prefix] = typed
+	}
+}
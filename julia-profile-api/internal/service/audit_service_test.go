@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+)
+
+func TestDiffFieldsReportsLeafChanges(t *testing.T) {
+	before := &model.UserProfile{
+		FirstName: "Mario",
+		Address:   &model.Address{Street: "Via Roma 1", City: "Roma", PostalCode: "00100", Country: "Italia"},
+	}
+	after := &model.UserProfile{
+		FirstName: "Mario",
+		Address:   &model.Address{Street: "Via Appia 10", City: "Roma", PostalCode: "00179", Country: "Italia"},
+	}
+
+	changes, err := diffFields(before, after)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %v", len(changes), changes)
+	}
+	if changes[0].Field != "address.postalCode" || changes[0].OldValue != "00100" || changes[0].NewValue != "00179" {
+		t.Errorf("Unexpected change: %+v", changes[0])
+	}
+	if changes[1].Field != "address.street" {
+		t.Errorf("Unexpected change: %+v", changes[1])
+	}
+}
+
+func TestDiffFieldsKeysArrayElementsByID(t *testing.T) {
+	before := &model.ChatPreferences{Preferences: []model.UserPreference{
+		{ID: "documents", Enabled: false},
+		{ID: "school", Enabled: true},
+	}}
+	// Same preferences in a different order, one toggled
+	after := &model.ChatPreferences{Preferences: []model.UserPreference{
+		{ID: "school", Enabled: true},
+		{ID: "documents", Enabled: true},
+	}}
+
+	changes, err := diffFields(before, after)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(changes) != 1 || changes[0].Field != "preferences[documents].enabled" {
+		t.Fatalf("Expected only preferences[documents].enabled to change, got %v", changes)
+	}
+}
+
+func TestDiffFieldsFromNil(t *testing.T) {
+	changes, err := diffFields(nil, map[string]string{"installationId": "abc"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(changes) != 1 || changes[0].OldValue != nil || changes[0].NewValue != "abc" {
+		t.Fatalf("Unexpected changes: %v", changes)
+	}
+}
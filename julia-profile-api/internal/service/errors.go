@@ -0,0 +1,33 @@
+package service
+
+import "github.com/comune-roma/bff-julia-profile-api/pkg/problem"
+
+// Error codes returned to clients in problem responses
+const (
+	CodeProfileNotFound              = "PROFILE_NOT_FOUND"
+	CodeVerificationNotFound         = "VERIFICATION_NOT_FOUND"
+	CodeVerificationExpired          = "VERIFICATION_EXPIRED"
+	CodeVerificationAttemptsExceeded = "VERIFICATION_ATTEMPTS_EXCEEDED"
+	CodeInvalidVerificationCode      = "INVALID_VERIFICATION_CODE"
+	CodeVerificationConflict         = "VERIFICATION_CONFLICT"
+)
+
+var (
+	// ErrProfileNotFound is returned when the user has no stored profile
+	ErrProfileNotFound = problem.New(problem.KindNotFound, CodeProfileNotFound, "User profile not found")
+
+	// ErrVerificationNotFound is returned when there is no pending change for the requested channel
+	ErrVerificationNotFound = problem.New(problem.KindNotFound, CodeVerificationNotFound, "No pending change to verify for this channel")
+
+	// ErrVerificationExpired is returned when the verification code is past its expiry
+	ErrVerificationExpired = problem.New(problem.KindGone, CodeVerificationExpired, "Verification code expired, request a new one")
+
+	// ErrVerificationAttemptsExceeded is returned when too many wrong codes have been submitted
+	ErrVerificationAttemptsExceeded = problem.New(problem.KindTooManyRequests, CodeVerificationAttemptsExceeded, "Too many attempts, request a new code")
+
+	// ErrVerificationConflict is returned when concurrent requests kept changing the verification or profile being updated
+	ErrVerificationConflict = problem.New(problem.KindConflict, CodeVerificationConflict, "Verification is being checked by another request, retry")
+
+	// ErrInvalidVerificationCode is returned when the submitted code does not match
+	ErrInvalidVerificationCode = problem.New(problem.KindUnprocessable, CodeInvalidVerificationCode, "Invalid verification code")
+)
@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/comune-roma/bff-julia-profile-api/internal/client"
 	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/metrics"
 	"github.com/comune-roma/bff-julia-profile-api/internal/model"
 	"github.com/comune-roma/bff-julia-profile-api/internal/repository"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/comune-roma/bff-julia-shared/pushtags"
 	"go.uber.org/zap"
 )
 
@@ -15,16 +19,18 @@ type UserPreferencesService struct {
 	appConfigClient    interface{} // Azure App Config client
 	repo               *repository.UserPreferencesRepository
 	notificationClient *client.NotificationClient
+	audit              *AuditService
 	cfg                *config.Config
 	log                *zap.Logger
 }
 
 // NewUserPreferencesService creates a new UserPreferencesService
-func NewUserPreferencesService(appConfigClient interface{}, repo *repository.UserPreferencesRepository, notificationClient *client.NotificationClient, cfg *config.Config, log *zap.Logger) *UserPreferencesService {
+func NewUserPreferencesService(appConfigClient interface{}, repo *repository.UserPreferencesRepository, notificationClient *client.NotificationClient, audit *AuditService, cfg *config.Config, log *zap.Logger) *UserPreferencesService {
 	return &UserPreferencesService{
 		appConfigClient:    appConfigClient,
 		repo:               repo,
 		notificationClient: notificationClient,
+		audit:              audit,
 		cfg:                cfg,
 		log:                log,
 	}
@@ -32,7 +38,7 @@ func NewUserPreferencesService(appConfigClient interface{}, repo *repository.Use
 
 // GetChatPreferences retrieves user chat preferences
 func (s *UserPreferencesService) GetChatPreferences(ctx context.Context, userID string) (*model.ChatPreferences, error) {
-	s.log.Info("Fetching chat preferences", zap.String("userID", userID))
+	reqctx.Logger(ctx, s.log).Info("Fetching chat preferences", zap.String("userID", userID))
 
 	// Get saved preferences from repo (mocked for now)
 	userPrefs := []string{} // Placeholder for IDs of enabled preferences from DB
@@ -78,12 +84,28 @@ func (s *UserPreferencesService) mergeChatPreferences(userPrefIDs []string, defa
 
 // UpdateChatPreferences updates user chat preferences
 func (s *UserPreferencesService) UpdateChatPreferences(ctx context.Context, userID string, req *model.ChatPreferences) (*model.ChatPreferences, error) {
-	s.log.Info("Updating chat preferences", zap.String("userID", userID))
+	reqctx.Logger(ctx, s.log).Info("Updating chat preferences", zap.String("userID", userID))
+
+	before, err := s.GetChatPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 
 	// TODO: Save to Cosmos DB via repo
 	// s.repo.UpdateChatPreferences(ctx, userID, req)
 
-	s.log.Info("Chat preferences updated successfully")
+	reqctx.Logger(ctx, s.log).Info("Chat preferences updated successfully")
+	s.audit.Record(ctx, userID, model.AuditActionUpdate, AuditResourceChatPreferences, before, req)
+
+	previous := make(map[string]bool, len(before.Preferences))
+	for _, p := range before.Preferences {
+		previous[p.ID] = p.Enabled
+	}
+	for _, p := range req.Preferences {
+		if previous[p.ID] != p.Enabled {
+			s.recordTopicChange("chat", p.ID, p.Enabled)
+		}
+	}
 
 	// Sync to Notification Service (fire and forget)
 	enabledIDs := []string{}
@@ -92,28 +114,36 @@ func (s *UserPreferencesService) UpdateChatPreferences(ctx context.Context, user
 			enabledIDs = append(enabledIDs, p.ID)
 		}
 	}
-	go s.notificationClient.SyncUserPreferences(context.Background(), "it", enabledIDs)
+	// Detached from the request cancellation but keeping its trace context
+	go s.notificationClient.SyncUserPreferences(context.WithoutCancel(ctx), "it", enabledIDs)
 
 	return s.GetChatPreferences(ctx, userID)
 }
 
 // GetPreferredLanguage retrieves user's preferred language
 func (s *UserPreferencesService) GetPreferredLanguage(ctx context.Context, userID string) (*model.LanguagePreference, error) {
-	s.log.Info("Fetching preferred language", zap.String("userID", userID))
+	reqctx.Logger(ctx, s.log).Info("Fetching preferred language", zap.String("userID", userID))
 	// TODO: Fetch from DB
 	return &model.LanguagePreference{Language: "it-IT"}, nil
 }
 
 // UpdatePreferredLanguage updates user's preferred language
 func (s *UserPreferencesService) UpdatePreferredLanguage(ctx context.Context, userID string, req *model.LanguagePreference) (*model.LanguagePreference, error) {
-	s.log.Info("Updating preferred language", zap.String("userID", userID), zap.String("language", req.Language))
+	reqctx.Logger(ctx, s.log).Info("Updating preferred language", zap.String("userID", userID), zap.String("language", req.Language))
+
+	before, err := s.GetPreferredLanguage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// TODO: Save to DB
+	s.audit.Record(ctx, userID, model.AuditActionUpdate, AuditResourceLanguagePreference, before, req)
 	return req, nil
 }
 
 // GetNotificationPreferences retrieves user notification preferences
 func (s *UserPreferencesService) GetNotificationPreferences(ctx context.Context, userID string) (*model.NotificationPreferences, error) {
-	s.log.Info("Fetching notification preferences", zap.String("userID", userID))
+	reqctx.Logger(ctx, s.log).Info("Fetching notification preferences", zap.String("userID", userID))
 	// TODO: Fetch from Notification Service or DB
 	// Default notifications from configuration
 	notifications := make([]model.NotificationPreferenceItem, len(s.cfg.Defaults.Notifications))
@@ -132,21 +162,95 @@ func (s *UserPreferencesService) GetNotificationPreferences(ctx context.Context,
 
 // UpdateNotificationPreferences updates user notification preferences
 func (s *UserPreferencesService) UpdateNotificationPreferences(ctx context.Context, userID string, req *model.NotificationPreferences) (*model.NotificationPreferences, error) {
-	s.log.Info("Updating notification preferences", zap.String("userID", userID))
+	reqctx.Logger(ctx, s.log).Info("Updating notification preferences", zap.String("userID", userID))
+
+	before, err := s.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// TODO: Sync with Notification Service
+	s.audit.Record(ctx, userID, model.AuditActionUpdate, AuditResourceNotificationPreferences, before, req)
+
+	previous := make(map[string]bool, len(before.Notifications))
+	for _, n := range before.Notifications {
+		previous[n.ID] = n.Enabled
+	}
+	for _, n := range req.Notifications {
+		if previous[n.ID] != n.Enabled {
+			s.recordTopicChange("notification", n.ID, n.Enabled)
+		}
+	}
 	return req, nil
 }
 
+// recordTopicChange counts a preference switched on or off. Topics that are not part of the
+// configured defaults are grouped under "other" to keep the metric cardinality bounded.
+func (s *UserPreferencesService) recordTopicChange(kind, topic string, enabled bool) {
+	if !s.isKnownTopic(kind, topic) {
+		topic = "other"
+	}
+	metrics.PreferenceUpdates.WithLabelValues(kind, topic, strconv.FormatBool(enabled)).Inc()
+}
+
+func (s *UserPreferencesService) isKnownTopic(kind, topic string) bool {
+	if kind == "chat" {
+		for _, p := range s.cfg.Defaults.Chat {
+			if p.ID == topic {
+				return true
+			}
+		}
+		return false
+	}
+	for _, id := range s.cfg.Defaults.Notifications {
+		if id == topic {
+			return true
+		}
+	}
+	return false
+}
+
 // UpsertInstallation registers or updates a device installation
 func (s *UserPreferencesService) UpsertInstallation(ctx context.Context, userID, installationID string, req *model.DeviceInstallationRequest) error {
-	s.log.Info("Upserting installation", zap.String("userID", userID), zap.String("installationID", installationID))
-	// TODO: Call Notification Service to register installation
+	reqctx.Logger(ctx, s.log).Info("Upserting installation", zap.String("userID", userID), zap.String("installationID", installationID))
+
+	// The installation is tagged with the user's topics and language, which the notification worker targets
+	prefs, err := s.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+	topics := []string{}
+	for _, n := range prefs.Notifications {
+		if n.Enabled {
+			topics = append(topics, n.ID)
+		}
+	}
+	language := req.Language
+	if language == "" {
+		language = prefs.Language
+	}
+	if err := s.notificationClient.RegisterInstallation(ctx, userID, installationID, req, pushtags.InstallationTags(language, topics)); err != nil {
+		return err
+	}
+
+	// The push channel is a device token and is deliberately left out of the audit log
+	s.audit.Record(ctx, userID, model.AuditActionUpdate, AuditResourceInstallation, nil, map[string]string{
+		"installationId": installationID,
+		"platform":       string(req.Platform),
+		"language":       req.Language,
+	})
 	return nil
 }
 
 // DeleteInstallation removes a device installation
 func (s *UserPreferencesService) DeleteInstallation(ctx context.Context, userID, installationID string) error {
-	s.log.Info("Deleting installation", zap.String("userID", userID), zap.String("installationID", installationID))
-	// TODO: Call Notification Service to delete installation
+	reqctx.Logger(ctx, s.log).Info("Deleting installation", zap.String("userID", userID), zap.String("installationID", installationID))
+	if err := s.notificationClient.DeleteInstallation(ctx, installationID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, userID, model.AuditActionDelete, AuditResourceInstallation, map[string]string{
+		"installationId": installationID,
+	}, nil)
 	return nil
 }
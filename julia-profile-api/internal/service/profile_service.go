@@ -3,110 +3,367 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"sync"
 	"time"
 
 	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/metrics"
 	"github.com/comune-roma/bff-julia-profile-api/internal/model"
 	"github.com/comune-roma/bff-julia-profile-api/internal/repository"
 	"github.com/comune-roma/bff-julia-profile-api/pkg/cache"
-	"github.com/google/uuid"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
+// profileLoadTimeout bounds database reads shared by coalesced requests and background refreshes
+const profileLoadTimeout = 10 * time.Second
+
+// generationSlots is the number of cache invalidation counters, shared by the users hashing to the same slot
+const generationSlots = 256
+
 // UserProfileService handles business logic for user profiles
 type UserProfileService struct {
-	repo  *repository.UserProfileRepository
-	cache cache.Cache
-	cfg   config.RedisConfig
-	log   *zap.Logger
+	repo         *repository.UserProfileRepository
+	verification *ContactVerificationService
+	audit        *AuditService
+	cache        cache.Cache
+	cfg          config.CacheConfig
+	loads        singleflight.Group
+	log          *zap.Logger
+
+	// Cache invalidations by user hash, so that a load that read the database before a write does not cache what
+	// it read; users sharing a slot only skip a few write-backs
+	generationsMu sync.Mutex
+	generations   [generationSlots]uint64
 }
 
 // NewUserProfileService creates a new UserProfileService
-func NewUserProfileService(repo *repository.UserProfileRepository, cache cache.Cache, cfg config.RedisConfig, log *zap.Logger) *UserProfileService {
+func NewUserProfileService(repo *repository.UserProfileRepository, verification *ContactVerificationService, audit *AuditService, cache cache.Cache, cfg config.CacheConfig, log *zap.Logger) *UserProfileService {
 	return &UserProfileService{
-		repo:  repo,
-		cache: cache,
-		cfg:   cfg,
-		log:   log,
+		repo:         repo,
+		verification: verification,
+		audit:        audit,
+		cache:        cache,
+		cfg:          cfg,
+		log:          log,
 	}
 }
 
-// GetUserProfile retrieves a user's profile
+// GetUserProfile retrieves a user's profile.
+// Fresh cache entries are returned as they are; stale ones are returned while a background
+// refresh runs; expired ones are reloaded, falling back to the expired copy if the database fails.
 func (s *UserProfileService) GetUserProfile(ctx context.Context, userID string) (*model.UserProfileResponse, error) {
-	cacheKey := fmt.Sprintf("profile:%s", userID)
-
-	// Try to get from cache if enabled
-	if s.cfg.Enabled {
-		val, err := s.cache.Get(ctx, cacheKey)
-		if err == nil {
-			var profile model.UserProfileResponse
-			if err := json.Unmarshal([]byte(val), &profile); err == nil {
-				s.log.Debug("Profile found in cache", zap.String("userID", userID))
-				return &profile, nil
-			}
+	entry := s.readCache(ctx, userID)
+	now := time.Now()
+
+	switch entry.state(now) {
+	case cacheStateFresh:
+		if entry.NotFound {
+			metrics.ProfileCacheLookups.WithLabelValues(metrics.CacheResultNegativeHit).Inc()
+			return nil, ErrProfileNotFound
+		}
+		metrics.ProfileCacheLookups.WithLabelValues(metrics.CacheResultHit).Inc()
+		reqctx.Logger(ctx, s.log).Debug("Profile found in cache", zap.String("userID", userID))
+		return entry.Profile, nil
+
+	case cacheStateStale:
+		metrics.ProfileCacheLookups.WithLabelValues(metrics.CacheResultStale).Inc()
+		s.refreshInBackground(ctx, userID)
+		return entry.Profile, nil
+	}
+
+	metrics.ProfileCacheLookups.WithLabelValues(metrics.CacheResultMiss).Inc()
+	profile, err := s.fetchProfile(ctx, userID)
+	if err != nil && !errors.Is(err, ErrProfileNotFound) && entry.state(now) == cacheStateExpired {
+		metrics.ProfileCacheLookups.WithLabelValues(metrics.CacheResultStaleError).Inc()
+		reqctx.Logger(ctx, s.log).Warn("Serving expired profile after database error", zap.String("userID", userID), zap.Error(err))
+		return entry.Profile, nil
+	}
+	return profile, err
+}
+
+// fetchProfile loads the profile from the database and refreshes the cache.
+// Concurrent calls for the same user share a single database read.
+func (s *UserProfileService) fetchProfile(ctx context.Context, userID string) (*model.UserProfileResponse, error) {
+	v, err, shared := s.loads.Do(userID, func() (interface{}, error) {
+		// Detached from the caller so that one cancelled request does not fail the others sharing the read
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), profileLoadTimeout)
+		defer cancel()
+
+		generation := s.generation(userID)
+		reqctx.Logger(ctx, s.log).Info("Fetching user profile from database", zap.String("userID", userID))
+		stored, err := s.loadProfile(loadCtx, userID)
+		if errors.Is(err, ErrProfileNotFound) {
+			s.writeBack(loadCtx, userID, generation, &cachedProfile{NotFound: true})
+			return nil, err
+		}
+		if err != nil {
+			return nil, err
 		}
+
+		profile := toProfileResponse(stored)
+		s.writeBack(loadCtx, userID, generation, &cachedProfile{Profile: profile})
+		return profile, nil
+	})
+	if shared {
+		metrics.ProfileLoadsCoalesced.Inc()
+	}
+	if err != nil {
+		return nil, err
 	}
+	return v.(*model.UserProfileResponse), nil
+}
 
-	s.log.Info("Fetching user profile from database", zap.String("userID", userID))
-
-	// TODO: Implement actual Cosmos DB query
-	// For now, return a mock response
-	profile := &model.UserProfileResponse{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		FirstName: "Mario",
-		LastName:  "Rossi",
-		Email:     "mario.rossi@example.com",
-		Phone:     "+39 123 456 7890",
-		Address: &model.Address{
-			Street:     "Via Roma 1",
-			City:       "Roma",
-			PostalCode: "00100",
-			Country:    "Italia",
-		},
-		CreatedAt: time.Now().Add(-365 * 24 * time.Hour),
-		UpdatedAt: time.Now(),
-	}
-
-	// Store in cache if enabled
-	if s.cfg.Enabled {
-		data, err := json.Marshal(profile)
-		if err == nil {
-			if err := s.cache.Set(ctx, cacheKey, data, time.Duration(s.cfg.TTL)*time.Second); err != nil {
-				s.log.Warn("Failed to set profile in cache", zap.Error(err))
-			}
+// refreshInBackground reloads a stale profile without blocking the caller
+func (s *UserProfileService) refreshInBackground(ctx context.Context, userID string) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if _, err := s.fetchProfile(ctx, userID); err != nil && !errors.Is(err, ErrProfileNotFound) {
+			metrics.ProfileCacheRefreshes.WithLabelValues("error").Inc()
+			reqctx.Logger(ctx, s.log).Warn("Failed to refresh stale profile", zap.String("userID", userID), zap.Error(err))
+			return
 		}
+		metrics.ProfileCacheRefreshes.WithLabelValues("success").Inc()
+	}()
+}
+
+// readCache returns the cached entry for the user, or nil on a miss
+func (s *UserProfileService) readCache(ctx context.Context, userID string) *cachedProfile {
+	val, err := s.cache.Get(ctx, profileCacheKey(userID))
+	if err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			reqctx.Logger(ctx, s.log).Warn("Failed to read profile from cache", zap.Error(err))
+		}
+		return nil
 	}
 
-	return profile, nil
+	var entry cachedProfile
+	if err := json.Unmarshal([]byte(val), &entry); err != nil || (entry.Profile == nil && !entry.NotFound) {
+		// Entries written by older versions are plain profiles: treat them as a miss
+		return nil
+	}
+	return &entry
 }
 
-// UpdateUserProfile updates a user's profile
+// generation returns the number of cache invalidations of the user's slot so far
+func (s *UserProfileService) generation(userID string) uint64 {
+	s.generationsMu.Lock()
+	defer s.generationsMu.Unlock()
+	return s.generations[generationSlot(userID)]
+}
+
+func generationSlot(userID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return h.Sum32() % generationSlots
+}
+
+// writeBack caches what a load read, unless the profile was written since the load started (generation).
+// An invalidation landing between the check and the write is caught by the check after it, which drops the
+// entry again; the invalidation itself bumps the generation before deleting, so one of the two deletes wins.
+func (s *UserProfileService) writeBack(ctx context.Context, userID string, generation uint64, entry *cachedProfile) {
+	if s.generation(userID) != generation {
+		reqctx.Logger(ctx, s.log).Debug("Profile changed during load, not caching it", zap.String("userID", userID))
+		return
+	}
+	s.writeCache(ctx, userID, entry)
+	if s.generation(userID) != generation {
+		if err := s.cache.Delete(ctx, profileCacheKey(userID)); err != nil {
+			reqctx.Logger(ctx, s.log).Warn("Failed to invalidate profile cache", zap.Error(err))
+		}
+	}
+}
+
+// invalidateCache drops the cached profile after a write. Loads already running neither cache what they read
+// nor serve it to later callers, who start a new load.
+func (s *UserProfileService) invalidateCache(ctx context.Context, userID string) {
+	s.generationsMu.Lock()
+	s.generations[generationSlot(userID)]++
+	s.generationsMu.Unlock()
+	s.loads.Forget(userID)
+
+	if err := s.cache.Delete(ctx, profileCacheKey(userID)); err != nil {
+		reqctx.Logger(ctx, s.log).Warn("Failed to invalidate profile cache", zap.Error(err))
+	}
+}
+
+// writeCache stores a profile lookup result, stamping its freshness deadlines
+func (s *UserProfileService) writeCache(ctx context.Context, userID string, entry *cachedProfile) {
+	now := time.Now().UTC()
+	var expiration time.Duration
+	if entry.NotFound {
+		if s.cfg.NegativeTTL <= 0 {
+			return
+		}
+		expiration = time.Duration(s.cfg.NegativeTTL) * time.Second
+		entry.FreshUntil = now.Add(expiration)
+		entry.StaleUntil = entry.FreshUntil
+	} else {
+		ttl := time.Duration(s.cfg.TTL) * time.Second
+		entry.FreshUntil = now.Add(time.Duration(s.cfg.SoftTTL) * time.Second)
+		entry.StaleUntil = now.Add(ttl)
+		expiration = ttl + time.Duration(s.cfg.StaleIfError)*time.Second
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := s.cache.Fill(ctx, profileCacheKey(userID), data, expiration); err != nil {
+		reqctx.Logger(ctx, s.log).Warn("Failed to set profile in cache", zap.Error(err))
+	}
+}
+
+// UpdateUserProfile updates a user's profile.
+// Email and phone changes are not applied directly: they stay pending until the new contact is verified.
 func (s *UserProfileService) UpdateUserProfile(ctx context.Context, userID string, req *model.UpdateUserProfileRequest) (*model.UserProfileResponse, error) {
-	s.log.Info("Updating user profile", zap.String("userID", userID))
-
-	// TODO: Implement actual Cosmos DB update
-	// For now, return a mock response with updated data
-	profile := &model.UserProfileResponse{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Email:     req.Email,
-		Phone:     req.Phone,
-		Address:   req.Address,
-		CreatedAt: time.Now().Add(-365 * 24 * time.Hour),
-		UpdatedAt: time.Now(),
-	}
-
-	// Invalidate cache if enabled
-	if s.cfg.Enabled {
-		cacheKey := fmt.Sprintf("profile:%s", userID)
-		if err := s.cache.Delete(ctx, cacheKey); err != nil {
-			s.log.Warn("Failed to invalidate profile cache", zap.Error(err))
+	reqctx.Logger(ctx, s.log).Info("Updating user profile", zap.String("userID", userID))
+
+	now := time.Now().UTC()
+	profile, err := s.loadProfile(ctx, userID)
+	isNew := errors.Is(err, ErrProfileNotFound)
+	if err != nil && !isNew {
+		return nil, err
+	}
+	var before *model.UserProfile
+	action := model.AuditActionUpdate
+	if isNew {
+		profile = &model.UserProfile{
+			ID:        userID,
+			UserID:    userID,
+			CreatedAt: now,
+		}
+		action = model.AuditActionCreate
+	} else {
+		snapshot := *profile
+		before = &snapshot
+	}
+
+	if req.FirstName != "" {
+		profile.FirstName = req.FirstName
+	}
+	if req.LastName != "" {
+		profile.LastName = req.LastName
+	}
+	if req.Address != nil {
+		profile.Address = req.Address
+	}
+	profile.UpdatedAt = now
+
+	// Verifications start before anything is stored, so that a code that cannot be sent fails the whole update.
+	// A pending change left by a later failure is harmless: it is only applied once its code is verified.
+	var pending []model.PendingContactChange
+	if req.Email != "" && req.Email != profile.Email {
+		change, err := s.verification.StartVerification(ctx, userID, model.ChannelEmail, req.Email)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, *change)
+	}
+	if req.Phone != "" && req.Phone != profile.Phone {
+		change, err := s.verification.StartVerification(ctx, userID, model.ChannelPhone, req.Phone)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, *change)
+	}
+
+	if isNew {
+		err = s.repo.CreateProfile(ctx, userID, profile)
+	} else {
+		err = s.repo.UpdateProfile(ctx, userID, profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, userID, action, AuditResourceProfile, before, profile)
+
+	// Invalidate cache, including a cached "not found" for newly created profiles
+	s.invalidateCache(ctx, userID)
+
+	response := toProfileResponse(profile)
+	response.PendingVerifications = pending
+	return response, nil
+}
+
+// VerifyContact confirms a pending email or phone change and returns the updated profile
+func (s *UserProfileService) VerifyContact(ctx context.Context, userID string, req *model.VerifyContactRequest) (*model.UserProfileResponse, error) {
+	profile, err := s.verification.VerifyContact(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCache(ctx, userID)
+	return toProfileResponse(profile), nil
+}
+
+// loadProfile reads the stored profile document
+func (s *UserProfileService) loadProfile(ctx context.Context, userID string) (*model.UserProfile, error) {
+	data, _, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrProfileNotFound
 		}
+		return nil, err
+	}
+
+	var profile model.UserProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal profile: %w", err)
+	}
+	return &profile, nil
+}
+
+func toProfileResponse(p *model.UserProfile) *model.UserProfileResponse {
+	return &model.UserProfileResponse{
+		ID:        p.ID,
+		UserID:    p.UserID,
+		FirstName: p.FirstName,
+		LastName:  p.LastName,
+		Email:     p.Email,
+		Phone:     p.Phone,
+		Address:   p.Address,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
 	}
+}
+
+// cacheState classifies a cached profile by age
+type cacheState int
+
+const (
+	cacheStateMissing cacheState = iota
+	cacheStateFresh
+	cacheStateStale
+	cacheStateExpired
+)
+
+// cachedProfile is the cache envelope of a profile lookup
+type cachedProfile struct {
+	Profile    *model.UserProfileResponse `json:"profile,omitempty"`
+	NotFound   bool                       `json:"notFound,omitempty"`
+	FreshUntil time.Time                  `json:"freshUntil"`
+	StaleUntil time.Time                  `json:"staleUntil"`
+}
+
+func (e *cachedProfile) state(now time.Time) cacheState {
+	switch {
+	case e == nil:
+		return cacheStateMissing
+	case now.Before(e.FreshUntil):
+		return cacheStateFresh
+	case e.NotFound:
+		return cacheStateMissing
+	case now.Before(e.StaleUntil):
+		return cacheStateStale
+	default:
+		return cacheStateExpired
+	}
+}
 
-	return profile, nil
+func profileCacheKey(userID string) string {
+	return fmt.Sprintf("profile:%s", userID)
 }
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/internal/repository"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/cache"
+	"go.uber.org/zap"
+)
+
+func TestCachedProfileState(t *testing.T) {
+	now := time.Now()
+	profile := &model.UserProfileResponse{UserID: "user-001"}
+
+	tests := []struct {
+		name  string
+		entry *cachedProfile
+		want  cacheState
+	}{
+		{"missing", nil, cacheStateMissing},
+		{"fresh", &cachedProfile{Profile: profile, FreshUntil: now.Add(time.Minute), StaleUntil: now.Add(time.Hour)}, cacheStateFresh},
+		{"stale", &cachedProfile{Profile: profile, FreshUntil: now.Add(-time.Minute), StaleUntil: now.Add(time.Hour)}, cacheStateStale},
+		{"expired", &cachedProfile{Profile: profile, FreshUntil: now.Add(-time.Hour), StaleUntil: now.Add(-time.Minute)}, cacheStateExpired},
+		{"negative", &cachedProfile{NotFound: true, FreshUntil: now.Add(time.Minute), StaleUntil: now.Add(time.Minute)}, cacheStateFresh},
+		{"negative expired", &cachedProfile{NotFound: true, FreshUntil: now.Add(-time.Second), StaleUntil: now.Add(-time.Second)}, cacheStateMissing},
+	}
+
+	for _, tt := range tests {
+		if got := tt.entry.state(now); got != tt.want {
+			t.Errorf("%s: expected state %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestLoadOverlappingUpdateIsNotCached(t *testing.T) {
+	doc, _ := json.Marshal(&model.UserProfile{ID: "user-001", UserID: "user-001", FirstName: "Mario"})
+	reading, release := make(chan struct{}), make(chan struct{})
+	var reads atomic.Int32
+	fake := &fakeCosmos{doc: doc, afterRead: func() {
+		// Hold the first read, which has already copied the old profile, until the update is done
+		if reads.Add(1) == 1 {
+			close(reading)
+			<-release
+		}
+	}}
+	client := newFakeCosmosClient(t, fake)
+
+	s := NewUserProfileService(
+		repository.NewUserProfileRepository(client, "db"),
+		nil,
+		NewAuditService(repository.NewAuditRepository(client, "db"), zap.NewNop()),
+		cache.NewMemoryCache(10),
+		config.CacheConfig{TTL: 300, SoftTTL: 60},
+		zap.NewNop(),
+	)
+	ctx := context.Background()
+
+	loaded := make(chan *model.UserProfileResponse)
+	go func() {
+		profile, err := s.GetUserProfile(ctx, "user-001")
+		if err != nil {
+			t.Error(err)
+		}
+		loaded <- profile
+	}()
+
+	<-reading
+	if _, err := s.UpdateUserProfile(ctx, "user-001", &model.UpdateUserProfileRequest{FirstName: "Luigi"}); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if profile := <-loaded; profile == nil || profile.FirstName != "Mario" {
+		t.Fatalf("expected the load to return the profile it read, got %+v", profile)
+	}
+
+	if entry := s.readCache(ctx, "user-001"); entry != nil {
+		t.Fatalf("expected the profile read before the update not to be cached, got %+v", entry.Profile)
+	}
+	profile, err := s.GetUserProfile(ctx, "user-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.FirstName != "Luigi" {
+		t.Errorf("expected the updated first name, got %q", profile.FirstName)
+	}
+}
+
+// failingSender refuses every verification code
+type failingSender struct{}
+
+func (failingSender) SendVerificationCode(ctx context.Context, channel model.ContactChannel, destination, code string) error {
+	return errors.New("notification service unavailable")
+}
+
+func TestUpdateIsNotStoredWhenVerificationFails(t *testing.T) {
+	doc, _ := json.Marshal(&model.UserProfile{ID: "user-001", UserID: "user-001", FirstName: "Mario", Email: "old@example.com"})
+	fake := &fakeCosmos{doc: doc}
+	cosmos := newFakeCosmosClient(t, fake)
+
+	audit := NewAuditService(repository.NewAuditRepository(cosmos, "db"), zap.NewNop())
+	verification := NewContactVerificationService(
+		repository.NewContactVerificationRepository(cosmos, "db"),
+		repository.NewUserProfileRepository(cosmos, "db"),
+		failingSender{},
+		audit,
+		&config.Config{Verification: config.VerificationConfig{CodeLength: 6, CodeTTL: 600, MaxAttempts: 3}},
+		zap.NewNop(),
+	)
+	s := NewUserProfileService(
+		repository.NewUserProfileRepository(cosmos, "db"),
+		verification,
+		audit,
+		cache.NewMemoryCache(10),
+		config.CacheConfig{TTL: 300, SoftTTL: 60},
+		zap.NewNop(),
+	)
+
+	_, err := s.UpdateUserProfile(context.Background(), "user-001", &model.UpdateUserProfileRequest{FirstName: "Luigi", Email: "new@example.com"})
+	if err == nil {
+		t.Fatal("expected the update to fail when the verification code cannot be sent")
+	}
+	if fake.replaces != 0 {
+		t.Errorf("the profile should not be stored when the update fails, got %d replaces", fake.replaces)
+	}
+}
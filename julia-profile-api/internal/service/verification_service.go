@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/client"
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/internal/repository"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"go.uber.org/zap"
+)
+
+// ContactVerificationService handles the proof of ownership of changed email and phone contacts
+type ContactVerificationService struct {
+	repo        *repository.ContactVerificationRepository
+	profileRepo *repository.UserProfileRepository
+	sender      client.VerificationSender
+	audit       *AuditService
+	cfg         config.VerificationConfig
+	log         *zap.Logger
+}
+
+// NewContactVerificationService creates a new ContactVerificationService
+func NewContactVerificationService(repo *repository.ContactVerificationRepository, profileRepo *repository.UserProfileRepository, sender client.VerificationSender, audit *AuditService, cfg *config.Config, log *zap.Logger) *ContactVerificationService {
+	return &ContactVerificationService{
+		repo:        repo,
+		profileRepo: profileRepo,
+		sender:      sender,
+		audit:       audit,
+		cfg:         cfg.Verification,
+		log:         log,
+	}
+}
+
+// StartVerification stores a pending contact change and sends a one-time code to the new contact.
+// A new request for the same channel replaces any previous pending change.
+func (s *ContactVerificationService) StartVerification(ctx context.Context, userID string, channel model.ContactChannel, value string) (*model.PendingContactChange, error) {
+	code, err := generateCode(s.cfg.CodeLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate verification code: %w", err)
+	}
+
+	now := time.Now().UTC()
+	verification := &model.ContactVerification{
+		ID:        verificationID(userID, channel),
+		UserID:    userID,
+		Channel:   channel,
+		Value:     value,
+		CodeHash:  hashCode(userID, channel, code),
+		ExpiresAt: now.Add(time.Duration(s.cfg.CodeTTL) * time.Second),
+		CreatedAt: now,
+		TTL:       s.cfg.CodeTTL,
+	}
+
+	if err := s.repo.UpsertVerification(ctx, userID, verification); err != nil {
+		return nil, err
+	}
+
+	if err := s.sender.SendVerificationCode(ctx, channel, value, code); err != nil {
+		return nil, fmt.Errorf("failed to send verification code: %w", err)
+	}
+
+	reqctx.Logger(ctx, s.log).Info("Contact verification started",
+		zap.String("userID", userID),
+		zap.String("channel", string(channel)),
+	)
+
+	return &model.PendingContactChange{
+		Channel:   channel,
+		Value:     value,
+		ExpiresAt: verification.ExpiresAt,
+	}, nil
+}
+
+// maxVerifyConflicts bounds the re-reads of a verification or profile changed by concurrent requests
+const maxVerifyConflicts = 5
+
+// VerifyContact checks the submitted code and, if it matches, promotes the pending value into the profile.
+// Every submission, right or wrong, counts as an attempt, and is counted with a conditional replace before the
+// code is compared: concurrent submissions are serialized by the ETag and cannot exceed MaxAttempts.
+func (s *ContactVerificationService) VerifyContact(ctx context.Context, userID string, req *model.VerifyContactRequest) (*model.UserProfile, error) {
+	id := verificationID(userID, req.Channel)
+
+	verification, err := s.countAttempt(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := []byte(verification.CodeHash)
+	actual := []byte(hashCode(userID, req.Channel, req.Code))
+	if subtle.ConstantTimeCompare(expected, actual) != 1 {
+		reqctx.Logger(ctx, s.log).Warn("Invalid verification code",
+			zap.String("userID", userID),
+			zap.String("channel", string(req.Channel)),
+			zap.Int("attempts", verification.Attempts),
+		)
+		if verification.Attempts >= s.cfg.MaxAttempts {
+			return nil, ErrVerificationAttemptsExceeded
+		}
+		return nil, ErrInvalidVerificationCode
+	}
+
+	profile, err := s.promote(ctx, userID, verification)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.DeleteVerification(ctx, userID, id); err != nil {
+		reqctx.Logger(ctx, s.log).Warn("Failed to delete completed verification", zap.Error(err))
+	}
+
+	reqctx.Logger(ctx, s.log).Info("Contact verified",
+		zap.String("userID", userID),
+		zap.String("channel", string(req.Channel)),
+	)
+
+	return profile, nil
+}
+
+// countAttempt reads the pending verification and stores it back with one more attempt, as long as it is
+// neither expired nor out of attempts. A replace losing against a concurrent one reads the verification again.
+func (s *ContactVerificationService) countAttempt(ctx context.Context, userID, id string) (*model.ContactVerification, error) {
+	for conflicts := 0; conflicts < maxVerifyConflicts; conflicts++ {
+		data, etag, err := s.repo.GetVerification(ctx, userID, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrVerificationNotFound
+			}
+			return nil, err
+		}
+
+		var verification model.ContactVerification
+		if err := json.Unmarshal(data, &verification); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal verification: %w", err)
+		}
+
+		if time.Now().After(verification.ExpiresAt) {
+			if err := s.repo.DeleteVerification(ctx, userID, id); err != nil {
+				reqctx.Logger(ctx, s.log).Warn("Failed to delete expired verification", zap.Error(err))
+			}
+			return nil, ErrVerificationExpired
+		}
+
+		if verification.Attempts >= s.cfg.MaxAttempts {
+			return nil, ErrVerificationAttemptsExceeded
+		}
+
+		verification.Attempts++
+		err = s.repo.ReplaceVerification(ctx, userID, id, &verification, etag)
+		switch {
+		case err == nil:
+			return &verification, nil
+		case errors.Is(err, repository.ErrPreconditionFailed):
+			continue
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, ErrVerificationNotFound
+		default:
+			return nil, err
+		}
+	}
+	return nil, ErrVerificationConflict
+}
+
+// promote writes the verified contact value into the profile document; the caller invalidates the cached profile.
+// The replace is conditional on the ETag read, so a concurrent profile update is read again rather than overwritten.
+func (s *ContactVerificationService) promote(ctx context.Context, userID string, verification *model.ContactVerification) (*model.UserProfile, error) {
+	for conflicts := 0; conflicts < maxVerifyConflicts; conflicts++ {
+		data, etag, err := s.profileRepo.GetProfile(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, ErrProfileNotFound
+			}
+			return nil, err
+		}
+
+		var profile model.UserProfile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal profile: %w", err)
+		}
+
+		before := profile
+		switch verification.Channel {
+		case model.ChannelEmail:
+			profile.Email = verification.Value
+		case model.ChannelPhone:
+			profile.Phone = verification.Value
+		}
+		profile.UpdatedAt = time.Now().UTC()
+
+		err = s.profileRepo.ReplaceProfile(ctx, userID, &profile, etag)
+		switch {
+		case err == nil:
+			s.audit.Record(ctx, userID, model.AuditActionVerify, AuditResourceProfile, &before, &profile)
+			return &profile, nil
+		case errors.Is(err, repository.ErrPreconditionFailed):
+			continue
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, ErrProfileNotFound
+		default:
+			return nil, err
+		}
+	}
+	return nil, ErrVerificationConflict
+}
+
+func verificationID(userID string, channel model.ContactChannel) string {
+	return fmt.Sprintf("%s:%s", userID, channel)
+}
+
+// hashCode binds the code to the user and channel so stored hashes cannot be replayed elsewhere
+func hashCode(userID string, channel model.ContactChannel, code string) string {
+	sum := sha256.Sum256([]byte(userID + "|" + string(channel) + "|" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateCode returns a numeric one-time code of the given length
+func generateCode(length int) (string, error) {
+	digits := make([]byte, length)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/model"
+	"github.com/comune-roma/bff-julia-profile-api/internal/repository"
+	"go.uber.org/zap"
+)
+
+// fakeCosmos serves a single document with Cosmos DB ETag semantics: reads return the ETag,
+// conditional replaces with a stale If-Match fail with 412. Creates, e.g. audit entries, are accepted and dropped.
+type fakeCosmos struct {
+	mu       sync.Mutex
+	doc      []byte
+	version  int
+	replaces int
+	// afterRead, if set, runs once a read has taken its copy of the document and before it is answered
+	afterRead func()
+}
+
+func (f *fakeCosmos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/docs/") {
+		f.mu.Lock()
+		doc, etag := f.doc, fmt.Sprintf(`"%d"`, f.version)
+		afterRead := f.afterRead
+		f.mu.Unlock()
+		if afterRead != nil {
+			afterRead()
+		}
+		w.Header().Set("etag", etag)
+		w.Write(doc)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.Contains(r.URL.Path, "/docs") {
+		// Account properties read by the client on start
+		json.NewEncoder(w).Encode(map[string]any{"id": "fake", "writableLocations": []any{}, "readableLocations": []any{}})
+		return
+	}
+	etag := fmt.Sprintf(`"%d"`, f.version)
+	switch r.Method {
+	case http.MethodPost:
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	case http.MethodPut:
+		if match := r.Header.Get("If-Match"); match != "" && match != etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.doc, _ = io.ReadAll(r.Body)
+		f.version++
+		f.replaces++
+		w.Header().Set("etag", fmt.Sprintf(`"%d"`, f.version))
+		w.Write(f.doc)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// newFakeCosmosClient returns a Cosmos DB client talking to fake
+func newFakeCosmosClient(t *testing.T, fake *fakeCosmos) *azcosmos.Client {
+	t.Helper()
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+
+	key := base64.StdEncoding.EncodeToString([]byte("key"))
+	client, err := azcosmos.NewClientFromConnectionString("AccountEndpoint="+server.URL+"/;AccountKey="+key+";",
+		&azcosmos.ClientOptions{ClientOptions: azcore.ClientOptions{Transport: server.Client()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestVerifyContactCountsConcurrentAttempts(t *testing.T) {
+	const maxAttempts = 3
+	doc, _ := json.Marshal(model.ContactVerification{
+		ID:        verificationID("user-001", model.ChannelEmail),
+		UserID:    "user-001",
+		Channel:   model.ChannelEmail,
+		Value:     "new@example.com",
+		CodeHash:  hashCode("user-001", model.ChannelEmail, "123456"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	fake := &fakeCosmos{doc: doc}
+	client := newFakeCosmosClient(t, fake)
+	s := &ContactVerificationService{
+		repo: repository.NewContactVerificationRepository(client, "db"),
+		cfg:  config.VerificationConfig{MaxAttempts: maxAttempts},
+		log:  zap.NewNop(),
+	}
+
+	// Many wrong guesses at once, followed by the right code
+	const guesses = 20
+	var wg sync.WaitGroup
+	results := make(chan error, guesses)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.VerifyContact(context.Background(), "user-001", &model.VerifyContactRequest{Channel: model.ChannelEmail, Code: fmt.Sprintf("%06d", i)})
+			results <- err
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+
+	invalid := 0
+	for err := range results {
+		switch {
+		case errors.Is(err, ErrInvalidVerificationCode):
+			invalid++
+		case errors.Is(err, ErrVerificationAttemptsExceeded), errors.Is(err, ErrVerificationConflict):
+		default:
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if fake.replaces != maxAttempts || invalid != maxAttempts-1 {
+		t.Errorf("%d attempts counted and %d answered invalid, want %d and %d", fake.replaces, invalid, maxAttempts, maxAttempts-1)
+	}
+
+	_, err := s.VerifyContact(context.Background(), "user-001", &model.VerifyContactRequest{Channel: model.ChannelEmail, Code: "123456"})
+	if !errors.Is(err, ErrVerificationAttemptsExceeded) {
+		t.Errorf("the right code after the attempts are used up should be refused, got %v", err)
+	}
+}
+
+func TestPromoteKeepsConcurrentProfileUpdate(t *testing.T) {
+	doc, _ := json.Marshal(&model.UserProfile{ID: "user-001", UserID: "user-001", FirstName: "Mario", Email: "old@example.com"})
+	updated, _ := json.Marshal(&model.UserProfile{ID: "user-001", UserID: "user-001", FirstName: "Luigi", Email: "old@example.com"})
+	fake := &fakeCosmos{doc: doc}
+	var once sync.Once
+	fake.afterRead = func() {
+		// Another request updates the profile after the first read
+		once.Do(func() {
+			fake.mu.Lock()
+			fake.doc = updated
+			fake.version++
+			fake.mu.Unlock()
+		})
+	}
+	client := newFakeCosmosClient(t, fake)
+	s := &ContactVerificationService{
+		profileRepo: repository.NewUserProfileRepository(client, "db"),
+		audit:       NewAuditService(repository.NewAuditRepository(client, "db"), zap.NewNop()),
+		log:         zap.NewNop(),
+	}
+
+	profile, err := s.promote(context.Background(), "user-001", &model.ContactVerification{Channel: model.ChannelEmail, Value: "new@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.FirstName != "Luigi" || profile.Email != "new@example.com" {
+		t.Errorf("expected the verified email on top of the concurrent update, got %+v", profile)
+	}
+
+	var stored model.UserProfile
+	json.Unmarshal(fake.doc, &stored)
+	if stored.FirstName != "Luigi" || stored.Email != "new@example.com" || fake.replaces != 1 {
+		t.Errorf("unexpected stored profile %+v after %d replaces", stored, fake.replaces)
+	}
+}
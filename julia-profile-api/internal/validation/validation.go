@@ -0,0 +1,111 @@
+package validation
+
+import (
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultCountryCode is the calling code applied to phone numbers written without an international prefix
+const DefaultCountryCode = "39"
+
+var (
+	e164Pattern       = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	capPattern        = regexp.MustCompile(`^[0-9]{5}$`)
+	phoneStripPattern = regexp.MustCompile(`[\s\-\.\(\)/]`)
+)
+
+// italianCountries lists the country values that identify an Italian address
+var italianCountries = map[string]bool{
+	"it":     true,
+	"ita":    true,
+	"italia": true,
+	"italy":  true,
+}
+
+// Register registers the custom validation rules and makes violations report JSON field names
+func Register(v *validator.Validate) error {
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	if err := v.RegisterValidation("rfc5322", validateRFC5322Email); err != nil {
+		return err
+	}
+	if err := v.RegisterValidation("phone_e164", validateE164Phone); err != nil {
+		return err
+	}
+	return v.RegisterValidation("cap_it", validateItalianPostalCode)
+}
+
+// NormalizePhone converts a phone number into E.164 form, assuming the Italian region when no prefix is present.
+// Values that cannot be normalized are returned trimmed so that validation reports them.
+func NormalizePhone(raw string) string {
+	phone := phoneStripPattern.ReplaceAllString(strings.TrimSpace(raw), "")
+	if phone == "" {
+		return ""
+	}
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		return phone
+	case strings.HasPrefix(phone, "00"):
+		return "+" + strings.TrimPrefix(phone, "00")
+	default:
+		return "+" + DefaultCountryCode + phone
+	}
+}
+
+// NormalizeEmail trims the address and lowercases its domain part
+func NormalizeEmail(raw string) string {
+	email := strings.TrimSpace(raw)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return email[:at] + "@" + strings.ToLower(email[at+1:])
+}
+
+// IsItalianCountry reports whether the given country value identifies Italy
+func IsItalianCountry(country string) bool {
+	return italianCountries[strings.ToLower(strings.TrimSpace(country))]
+}
+
+// validateRFC5322Email accepts a bare RFC 5322 address (no display name)
+func validateRFC5322Email(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return false
+	}
+	return addr.Name == "" && addr.Address == value
+}
+
+func validateE164Phone(fl validator.FieldLevel) bool {
+	return e164Pattern.MatchString(fl.Field().String())
+}
+
+// validateItalianPostalCode enforces the five digit CAP format when the sibling Country field is Italian
+func validateItalianPostalCode(fl validator.FieldLevel) bool {
+	parent := fl.Parent()
+	if parent.Kind() == reflect.Ptr {
+		parent = parent.Elem()
+	}
+
+	country := parent.FieldByName("Country")
+	if !country.IsValid() || country.Kind() != reflect.String || !IsItalianCountry(country.String()) {
+		return true
+	}
+
+	return capPattern.MatchString(fl.Field().String())
+}
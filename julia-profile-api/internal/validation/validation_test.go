@@ -0,0 +1,92 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	cases := map[string]string{
+		"":                    "",
+		"333 123 4567":        "+393331234567",
+		"+39 06-1234.5678":    "+390612345678",
+		"0039 333 1234567":    "+393331234567",
+		" +44 (20) 7946 0958": "+442079460958",
+	}
+
+	for in, expected := range cases {
+		if got := NormalizePhone(in); got != expected {
+			t.Errorf("NormalizePhone(%q): expected %q, got %q", in, expected, got)
+		}
+	}
+}
+
+type testAddress struct {
+	PostalCode string `json:"postalCode" binding:"cap_it"`
+	Country    string `json:"country"`
+}
+
+type testRequest struct {
+	Email   string       `json:"email" binding:"omitempty,rfc5322"`
+	Phone   string       `json:"phone" binding:"omitempty,phone_e164"`
+	Address *testAddress `json:"address"`
+}
+
+func newTestValidator(t *testing.T) *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	if err := Register(v); err != nil {
+		t.Fatalf("Expected no error registering rules, got %v", err)
+	}
+	return v
+}
+
+func TestRulesAcceptValidRequest(t *testing.T) {
+	v := newTestValidator(t)
+
+	req := testRequest{
+		Email:   "mario.rossi@example.com",
+		Phone:   NormalizePhone("333 123 4567"),
+		Address: &testAddress{PostalCode: "00184", Country: "Italia"},
+	}
+	if err := v.Struct(req); err != nil {
+		t.Fatalf("Expected valid request, got %v", err)
+	}
+
+	// Foreign addresses are not bound to the CAP format
+	req.Address = &testAddress{PostalCode: "SW1A 1AA", Country: "UK"}
+	if err := v.Struct(req); err != nil {
+		t.Fatalf("Expected valid foreign address, got %v", err)
+	}
+}
+
+func TestRulesRejectInvalidFields(t *testing.T) {
+	v := newTestValidator(t)
+
+	req := testRequest{
+		Email:   "Mario Rossi <mario.rossi@example.com>",
+		Phone:   "+39abc",
+		Address: &testAddress{PostalCode: "0018", Country: "IT"},
+	}
+
+	err := v.Struct(req)
+	validationErrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+
+	expected := map[string]string{
+		"testRequest.email":              "rfc5322",
+		"testRequest.phone":              "phone_e164",
+		"testRequest.address.postalCode": "cap_it",
+	}
+	if len(validationErrs) != len(expected) {
+		t.Fatalf("Expected %d violations, got %d: %v", len(expected), len(validationErrs), validationErrs)
+	}
+	for _, fe := range validationErrs {
+		if expected[fe.Namespace()] != fe.Tag() {
+			t.Errorf("Unexpected violation %s on %s", fe.Tag(), fe.Namespace())
+		}
+	}
+}
@@ -6,16 +6,22 @@ import (
 
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
 	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
 )
 
-// NewCosmosClient creates a new Cosmos DB client
-func NewCosmosClient(cfg *config.Config) (*azcosmos.Client, error) {
+// NewCosmosClient creates a new Cosmos DB client whose requests go through the given resilience policy
+func NewCosmosClient(cfg *config.Config, resiliencePolicy *resilience.Policy) (*azcosmos.Client, error) {
 	cred, err := azcosmos.NewKeyCredential(cfg.CosmosDB.Key)
 	if err != nil {
 		return nil, err
 	}
 
 	clientOptions := &azcosmos.ClientOptions{}
+	clientOptions.Retry.MaxRetries = -1
+	clientOptions.PerCallPolicies = append(clientOptions.PerCallPolicies,
+		&tracingPipelinePolicy{},
+		&resiliencePipelinePolicy{policy: resiliencePolicy},
+	)
 
 	// Disable SSL verification for emulator
 	if cfg.CosmosDB.Emulator {
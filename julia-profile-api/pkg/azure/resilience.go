@@ -0,0 +1,103 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
+)
+
+// transientStatusError reports a response status worth retrying
+type transientStatusError struct {
+	statusCode int
+}
+
+func (e *transientStatusError) Error() string {
+	return fmt.Sprintf("transient status code %d", e.statusCode)
+}
+
+// resiliencePipelinePolicy runs every Cosmos DB request through a resilience policy.
+// It replaces the SDK generic retry policy, so retries are not compounded.
+// Requests that are not idempotent, such as creates, are only retried on 429: after a timeout, a transport
+// error or a 5xx the write may have been applied, and a second attempt would apply it again or fail with 409.
+type resiliencePipelinePolicy struct {
+	policy *resilience.Policy
+}
+
+func (p *resiliencePipelinePolicy) Do(req *policy.Request) (*http.Response, error) {
+	idempotent := isIdempotent(req.Raw())
+	// failed stops the retries once a request that is not idempotent may have reached Cosmos DB
+	failed := func(err error) error {
+		if idempotent {
+			return err
+		}
+		return resilience.NoRetry(err)
+	}
+
+	var resp *http.Response
+	err := p.policy.Execute(req.Raw().Context(), func(ctx context.Context) error {
+		if err := req.RewindBody(); err != nil {
+			return resilience.Permanent(err)
+		}
+
+		r, err := req.Clone(ctx).Next()
+		if err != nil {
+			return failed(err)
+		}
+
+		// Buffer the body: the attempt context is cancelled as soon as this function returns
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return failed(err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		resp = r
+
+		if isTransientStatus(r.StatusCode) {
+			statusErr := &transientStatusError{statusCode: r.StatusCode}
+			if r.StatusCode == http.StatusTooManyRequests {
+				// Throttled requests are rejected before being applied
+				return statusErr
+			}
+			return failed(statusErr)
+		}
+		return nil
+	})
+
+	// Hand the last response back to the SDK so it builds its usual ResponseError
+	var statusErr *transientStatusError
+	if err == nil || errors.As(err, &statusErr) {
+		return resp, nil
+	}
+	return nil, err
+}
+
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isIdempotent reports whether sending req again cannot apply it twice.
+// POST is used both for creates and for queries and upserts, which Cosmos DB marks with headers.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return strings.EqualFold(req.Header.Get("x-ms-documentdb-query"), "true") ||
+			strings.EqualFold(req.Header.Get("x-ms-documentdb-is-upsert"), "true")
+	}
+	return false
+}
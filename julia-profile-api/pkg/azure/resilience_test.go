@@ -0,0 +1,32 @@
+package azure
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header string
+		want   bool
+	}{
+		{"read", http.MethodGet, "", true},
+		{"replace", http.MethodPut, "", true},
+		{"delete", http.MethodDelete, "", true},
+		{"create", http.MethodPost, "", false},
+		{"query", http.MethodPost, "x-ms-documentdb-query", true},
+		{"upsert", http.MethodPost, "x-ms-documentdb-is-upsert", true},
+		{"patch", http.MethodPatch, "", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "https://cosmos.example/dbs/db/colls/c/docs", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, "True")
+		}
+		if got := isIdempotent(req); got != tt.want {
+			t.Errorf("%s: isIdempotent = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
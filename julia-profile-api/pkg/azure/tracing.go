@@ -0,0 +1,36 @@
+package azure
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/telemetry"
+	"github.com/comune-roma/bff-julia-shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// tracingPipelinePolicy wraps every Cosmos DB call, including its retries, in a client span
+type tracingPipelinePolicy struct{}
+
+func (p *tracingPipelinePolicy) Do(req *policy.Request) (*http.Response, error) {
+	raw := req.Raw()
+	ctx, span := tracing.StartClientSpan(raw.Context(), telemetry.Tracer(), fmt.Sprintf("cosmosdb %s", raw.Method),
+		semconv.DBSystemCosmosDB,
+		semconv.HTTPRequestMethodKey.String(raw.Method),
+		attribute.String("db.cosmosdb.resource", raw.URL.Path),
+	)
+
+	resp, err := req.Clone(ctx).Next()
+	if resp != nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+			err = fmt.Errorf("cosmos db returned status %d", resp.StatusCode)
+			tracing.EndSpan(span, err)
+			return resp, nil
+		}
+	}
+	tracing.EndSpan(span, err)
+	return resp, err
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
+	"go.uber.org/zap"
+)
+
+// ErrCacheMiss is returned by Get when the key is not present or has expired
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache interface for generic caching.
+// Set stores a new value written by this instance; Fill stores a value just read from the backing store,
+// which cannot make copies held by other instances stale.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Fill(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// New creates the cache backend selected by configuration.
+// A Redis client is only created for the "redis" and "tiered" backends; its commands go through redisPolicy.
+func New(cfg config.CacheConfig, redisCfg config.RedisConfig, redisPolicy *resilience.Policy, log *zap.Logger) (Cache, error) {
+	switch cfg.Backend {
+	case config.CacheBackendNone:
+		return NewNoopCache(), nil
+	case config.CacheBackendMemory:
+		return NewMemoryCache(cfg.LocalMaxEntries), nil
+	case config.CacheBackendRedis:
+		return NewRedisCache(redisCfg, redisPolicy), nil
+	case config.CacheBackendTiered:
+		local := NewMemoryCache(cfg.LocalMaxEntries)
+		remote := NewRedisCache(redisCfg, redisPolicy)
+		return NewTieredCache(local, remote, time.Duration(cfg.LocalTTL)*time.Second, cfg.InvalidationChannel, log), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %s", cfg.Backend)
+	}
+}
+
+// NoopCache is used when caching is disabled: every lookup is a miss
+type NoopCache struct{}
+
+// NewNoopCache creates a new NoopCache
+func NewNoopCache() *NoopCache {
+	return &NoopCache{}
+}
+
+func (c *NoopCache) Get(ctx context.Context, key string) (string, error) {
+	return "", ErrCacheMiss
+}
+
+func (c *NoopCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return nil
+}
+
+func (c *NoopCache) Fill(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return nil
+}
+
+func (c *NoopCache) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (c *NoopCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (c *NoopCache) Close() error {
+	return nil
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultMaxEntries = 10000
+
+// memoryEntry is an element of the LRU list
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time // zero means no expiration
+}
+
+// MemoryCache is an in-process LRU cache with per-entry TTL.
+// When full, the least recently used entry is evicted.
+type MemoryCache struct {
+	maxEntries int
+	items      map[string]*list.Element
+	lru        *list.List
+	mu         sync.Mutex
+}
+
+// NewMemoryCache creates a new MemoryCache holding at most maxEntries keys
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return "", ErrCacheMiss
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.removeElement(elem)
+		return "", ErrCacheMiss
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry.value, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = time.Now().Add(expiration)
+	}
+	stored := toString(value)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.value = stored
+		entry.expiresAt = expiresAt
+		c.lru.MoveToFront(elem)
+		return nil
+	}
+
+	c.items[key] = c.lru.PushFront(&memoryEntry{key: key, value: stored, expiresAt: expiresAt})
+	for c.lru.Len() > c.maxEntries {
+		c.removeElement(c.lru.Back())
+	}
+	return nil
+}
+
+// Fill is the same as Set: the cache is local to this instance
+func (c *MemoryCache) Fill(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.Set(ctx, key, value, expiration)
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	return nil
+}
+
+func (c *MemoryCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
+
+// Len returns the number of entries currently held, including expired ones not yet evicted
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// clear drops every entry
+func (c *MemoryCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+func (c *MemoryCache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*memoryEntry).key)
+}
+
+// toString mirrors how Redis stores values, so both backends return the same string
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2)
+
+	_ = c.Set(ctx, "a", "1", 0)
+	_ = c.Set(ctx, "b", "2", 0)
+	if _, err := c.Get(ctx, "a"); err != nil {
+		t.Fatalf("expected hit for a, got %v", err)
+	}
+	_ = c.Set(ctx, "c", "3", 0)
+
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("expected b to be evicted, got %v", err)
+	}
+	if val, err := c.Get(ctx, "a"); err != nil || val != "1" {
+		t.Errorf("expected a=1, got %q, %v", val, err)
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestMemoryCacheExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(10)
+
+	_ = c.Set(ctx, "k", []byte("v"), 10*time.Millisecond)
+	if val, err := c.Get(ctx, "k"); err != nil || val != "v" {
+		t.Fatalf("expected k=v, got %q, %v", val, err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("expected expired entry to miss, got %v", err)
+	}
+}
@@ -2,27 +2,27 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/resilience"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/telemetry"
+	"github.com/comune-roma/bff-julia-shared/tracing"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-// Cache interface for generic caching
-type Cache interface {
-	Get(ctx context.Context, key string) (string, error)
-	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
-	Delete(ctx context.Context, key string) error
-	Ping(ctx context.Context) error
-}
-
-// RedisCache implementation of Cache interface
+// RedisCache implementation of Cache interface.
+// Commands go through the Redis resilience policy; pub/sub used by TieredCache does not.
 type RedisCache struct {
 	client *redis.Client
+	policy *resilience.Policy
 }
 
 // NewRedisCache creates a new RedisCache
-func NewRedisCache(cfg config.RedisConfig) *RedisCache {
+func NewRedisCache(cfg config.RedisConfig, policy *resilience.Policy) *RedisCache {
 	client := redis.NewClient(&redis.Options{
 		Addr:     cfg.Addr,
 		Password: cfg.Password,
@@ -31,21 +31,62 @@ func NewRedisCache(cfg config.RedisConfig) *RedisCache {
 
 	return &RedisCache{
 		client: client,
+		policy: policy,
 	}
 }
 
-func (c *RedisCache) Get(ctx context.Context, key string) (string, error) {
-	return c.client.Get(ctx, key).Result()
+func (c *RedisCache) Get(ctx context.Context, key string) (val string, err error) {
+	ctx, span := tracing.StartClientSpan(ctx, telemetry.Tracer(), "redis GET", semconv.DBSystemRedis, semconv.DBOperationName("GET"))
+	defer func() { tracing.EndSpan(span, err) }()
+
+	miss := false
+	err = c.policy.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		val, err = c.client.Get(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			miss = true
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if miss {
+		span.SetAttributes(attribute.Bool("cache.hit", false))
+		return "", ErrCacheMiss
+	}
+	return val, nil
 }
 
 func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return c.client.Set(ctx, key, value, expiration).Err()
+	ctx, span := tracing.StartClientSpan(ctx, telemetry.Tracer(), "redis SET", semconv.DBSystemRedis, semconv.DBOperationName("SET"))
+	err := c.policy.Execute(ctx, func(ctx context.Context) error {
+		return c.client.Set(ctx, key, value, expiration).Err()
+	})
+	tracing.EndSpan(span, err)
+	return err
+}
+
+// Fill is the same as Set: Redis is shared by every instance
+func (c *RedisCache) Fill(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.Set(ctx, key, value, expiration)
 }
 
 func (c *RedisCache) Delete(ctx context.Context, key string) error {
-	return c.client.Del(ctx, key).Err()
+	ctx, span := tracing.StartClientSpan(ctx, telemetry.Tracer(), "redis DEL", semconv.DBSystemRedis, semconv.DBOperationName("DEL"))
+	err := c.policy.Execute(ctx, func(ctx context.Context) error {
+		return c.client.Del(ctx, key).Err()
+	})
+	tracing.EndSpan(span, err)
+	return err
 }
 
+// Ping bypasses the policy so health checks report the real connection state
 func (c *RedisCache) Ping(ctx context.Context) error {
 	return c.client.Ping(ctx).Err()
 }
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
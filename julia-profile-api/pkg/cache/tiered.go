@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// TieredCache combines a local in-process cache (L1) with Redis (L2).
+// Reads are served from L1 when possible; writes and deletes go to both tiers and are
+// broadcast over Redis pub/sub so that other instances drop their stale L1 copy.
+// Read-through fills are not broadcast.
+type TieredCache struct {
+	local      *MemoryCache
+	remote     *RedisCache
+	localTTL   time.Duration
+	channel    string
+	instanceID string
+	cancel     context.CancelFunc
+	done       chan struct{}
+	log        *zap.Logger
+}
+
+// NewTieredCache creates a new TieredCache and starts listening for invalidations
+func NewTieredCache(local *MemoryCache, remote *RedisCache, localTTL time.Duration, channel string, log *zap.Logger) *TieredCache {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &TieredCache{
+		local:      local,
+		remote:     remote,
+		localTTL:   localTTL,
+		channel:    channel,
+		instanceID: newInstanceID(),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		log:        log,
+	}
+	go c.listenInvalidations(ctx)
+	return c
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string) (string, error) {
+	if val, err := c.local.Get(ctx, key); err == nil {
+		return val, nil
+	}
+
+	val, err := c.remote.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	// L1 lifetime is capped so that a missed invalidation cannot serve stale data for long
+	_ = c.local.Set(ctx, key, val, c.localTTL)
+	return val, nil
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := c.Fill(ctx, key, value, expiration); err != nil {
+		return err
+	}
+
+	c.publishInvalidation(ctx, key)
+	return nil
+}
+
+// Fill stores a read-through result in both tiers without broadcasting an invalidation:
+// the value comes from the backing store, so the other instances' L1 copies are not made stale by it
+func (c *TieredCache) Fill(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := c.remote.Set(ctx, key, value, expiration); err != nil {
+		return err
+	}
+
+	localTTL := c.localTTL
+	if expiration > 0 && expiration < localTTL {
+		localTTL = expiration
+	}
+	_ = c.local.Set(ctx, key, value, localTTL)
+	return nil
+}
+
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	_ = c.local.Delete(ctx, key)
+	if err := c.remote.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	c.publishInvalidation(ctx, key)
+	return nil
+}
+
+func (c *TieredCache) Ping(ctx context.Context) error {
+	return c.remote.Ping(ctx)
+}
+
+// Close stops the invalidation listener and closes the Redis client
+func (c *TieredCache) Close() error {
+	c.cancel()
+	<-c.done
+	return c.remote.Close()
+}
+
+// publishInvalidation tells the other instances to drop key from their L1
+func (c *TieredCache) publishInvalidation(ctx context.Context, key string) {
+	if err := c.remote.client.Publish(ctx, c.channel, c.instanceID+"|"+key).Err(); err != nil {
+		c.log.Warn("Failed to publish cache invalidation", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (c *TieredCache) listenInvalidations(ctx context.Context) {
+	defer close(c.done)
+
+	for ctx.Err() == nil {
+		sub := c.remote.client.Subscribe(ctx, c.channel)
+		c.consume(ctx, sub)
+		sub.Close()
+
+		if ctx.Err() == nil {
+			// The subscription dropped: L1 may have missed invalidations while disconnected
+			c.log.Warn("Cache invalidation subscription lost, resubscribing")
+			c.local.clear()
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// consume applies invalidations from other instances until the subscription fails
+func (c *TieredCache) consume(ctx context.Context, sub *redis.PubSub) {
+	for {
+		msg, err := sub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.log.Warn("Failed to receive cache invalidation", zap.Error(err))
+			}
+			return
+		}
+
+		instanceID, key, err := parseInvalidation(msg.Payload)
+		if err != nil {
+			c.log.Warn("Ignoring cache invalidation", zap.String("payload", msg.Payload), zap.Error(err))
+			continue
+		}
+		if instanceID == c.instanceID {
+			continue
+		}
+		_ = c.local.Delete(ctx, key)
+	}
+}
+
+// newInstanceID returns a random identifier used to ignore our own invalidation messages
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// parseInvalidation splits an "instanceID|key" message
+func parseInvalidation(payload string) (instanceID, key string, err error) {
+	parts := strings.SplitN(payload, "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", errors.New("malformed invalidation message")
+	}
+	return parts[0], parts[1], nil
+}
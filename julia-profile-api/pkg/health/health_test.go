@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestReadiness(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("unreachable") }
+
+	tests := []struct {
+		name      string
+		critical  CheckFunc
+		optional  CheckFunc
+		want      Status
+		wantReady bool
+	}{
+		{"all up", up, up, StatusUp, true},
+		{"optional down", up, down, StatusDegraded, true},
+		{"critical down", down, up, StatusDown, false},
+	}
+
+	for _, tt := range tests {
+		r := NewRegistry("test", time.Minute, time.Second, zap.NewNop())
+		r.Register("database", true, tt.critical)
+		r.Register("cache", false, tt.optional)
+		r.runChecks(context.Background())
+
+		report, ready := r.Readiness()
+		if report.Status != tt.want || ready != tt.wantReady {
+			t.Errorf("%s: expected %s (ready=%v), got %s (ready=%v)", tt.name, tt.want, tt.wantReady, report.Status, ready)
+		}
+	}
+}
+
+func TestReadinessBeforeFirstCheck(t *testing.T) {
+	r := NewRegistry("test", time.Minute, time.Second, zap.NewNop())
+	r.Register("database", true, func(ctx context.Context) error { return nil })
+
+	if _, ready := r.Readiness(); ready {
+		t.Error("expected not ready before the first check completes")
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	r := NewRegistry("test", time.Minute, 10*time.Millisecond, zap.NewNop())
+	r.Register("slow", true, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	r.runChecks(context.Background())
+
+	report, _ := r.Readiness()
+	if report.Components["slow"].Status != StatusDown {
+		t.Errorf("expected timed out check to be down, got %s", report.Components["slow"].Status)
+	}
+}
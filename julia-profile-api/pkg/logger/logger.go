@@ -1,32 +1,43 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// level is shared by the loggers built here so that it can be changed at runtime
+var level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
 // NewLogger creates a new zap logger
 func NewLogger() *zap.Logger {
 	config := zap.NewProductionConfig()
 	config.EncoderConfig.TimeKey = "timestamp"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 
-	logLevel := os.Getenv("LOG_LEVEL")
-	switch logLevel {
+	// The configuration is not loaded yet: start from LOG_LEVEL (info when unknown), SetLevel applies the configured one
+	_ = SetLevel(os.Getenv("LOG_LEVEL"))
+	config.Level = level
+
+	logger, _ := config.Build()
+	return logger
+}
+
+// SetLevel changes the level of the loggers created by NewLogger: debug, info, warn or error
+func SetLevel(name string) error {
+	switch name {
 	case "debug":
-		config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+		level.SetLevel(zapcore.DebugLevel)
 	case "info":
-		config.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+		level.SetLevel(zapcore.InfoLevel)
 	case "warn":
-		config.Level = zap.NewAtomicLevelAt(zapcore.WarnLevel)
+		level.SetLevel(zapcore.WarnLevel)
 	case "error":
-		config.Level = zap.NewAtomicLevelAt(zapcore.ErrorLevel)
+		level.SetLevel(zapcore.ErrorLevel)
 	default:
-		config.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+		return fmt.Errorf("unknown log level %q", name)
 	}
-
-	logger, _ := config.Build()
-	return logger
+	return nil
 }
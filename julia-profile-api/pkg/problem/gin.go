@@ -0,0 +1,31 @@
+package problem
+
+import (
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/gin-gonic/gin"
+)
+
+// Respond aborts the request with the problem response of err.
+// Errors that are not an *Error are reported as internal errors without exposing their message.
+func Respond(c *gin.Context, err error) {
+	p := From(err)
+	body := Problem{
+		Type:       TypeURI(p.Code),
+		Title:      Title(p.Kind, c.GetHeader("Accept-Language")),
+		Status:     p.Status(),
+		Detail:     p.Detail,
+		Instance:   c.Request.URL.Path,
+		Code:       p.Code,
+		RequestID:  reqctx.RequestID(c.Request.Context()),
+		Violations: p.Violations,
+	}
+
+	// gin only sets the JSON content type when none is set yet
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(body.Status, body)
+}
+
+// NoRoute answers requests that match no route
+func NoRoute(c *gin.Context) {
+	Respond(c, New(KindNotFound, CodeRouteNotFound, "No route matches the request path"))
+}
@@ -0,0 +1,142 @@
+// Package problem implements the RFC 7807 (application/problem+json) error model shared by the API handlers
+package problem
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// typePrefix namespaces the problem type URIs; the suffix is derived from the error code
+const typePrefix = "urn:julia:problem:"
+
+// Kind classifies an error and determines its HTTP status
+type Kind string
+
+// Error kinds
+const (
+	KindValidation            Kind = "validation"
+	KindUnprocessable         Kind = "unprocessable"
+	KindUnauthorized          Kind = "unauthorized"
+	KindForbidden             Kind = "forbidden"
+	KindNotFound              Kind = "not_found"
+	KindConflict              Kind = "conflict"
+	KindGone                  Kind = "gone"
+	KindTooManyRequests       Kind = "too_many_requests"
+	KindDependencyUnavailable Kind = "dependency_unavailable"
+	KindInternal              Kind = "internal"
+)
+
+var kindStatus = map[Kind]int{
+	KindValidation:            http.StatusBadRequest,
+	KindUnprocessable:         http.StatusUnprocessableEntity,
+	KindUnauthorized:          http.StatusUnauthorized,
+	KindForbidden:             http.StatusForbidden,
+	KindNotFound:              http.StatusNotFound,
+	KindConflict:              http.StatusConflict,
+	KindGone:                  http.StatusGone,
+	KindTooManyRequests:       http.StatusTooManyRequests,
+	KindDependencyUnavailable: http.StatusServiceUnavailable,
+	KindInternal:              http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status code of the kind
+func (k Kind) Status() int {
+	if status, ok := kindStatus[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Generic error codes. Domain packages define their own, more specific codes.
+const (
+	CodeMalformedBody         = "MALFORMED_BODY"
+	CodeValidationFailed      = "VALIDATION_FAILED"
+	CodeUnauthorized          = "UNAUTHORIZED"
+	CodeForbidden             = "FORBIDDEN"
+	CodeRouteNotFound         = "ROUTE_NOT_FOUND"
+	CodeDependencyUnavailable = "DEPENDENCY_UNAVAILABLE"
+	CodeInternal              = "INTERNAL_ERROR"
+)
+
+// Violation describes a single field that failed validation
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is a classified error that can be rendered as a problem response.
+// Detail is shown to clients; the wrapped cause is only ever logged.
+type Error struct {
+	Kind       Kind
+	Code       string
+	Detail     string
+	Violations []Violation
+	cause      error
+}
+
+// New creates an Error with a client-facing detail message
+func New(kind Kind, code, detail string) *Error {
+	return &Error{Kind: kind, Code: code, Detail: detail}
+}
+
+// Wrap creates an Error carrying an internal cause
+func Wrap(kind Kind, code, detail string, cause error) *Error {
+	return &Error{Kind: kind, Code: code, Detail: detail, cause: cause}
+}
+
+// Validation creates a validation Error listing the offending fields
+func Validation(detail string, violations []Violation) *Error {
+	return &Error{Kind: KindValidation, Code: CodeValidationFailed, Detail: detail, Violations: violations}
+}
+
+func (e *Error) Error() string {
+	msg := e.Code
+	if e.Detail != "" {
+		msg = fmt.Sprintf("%s: %s", e.Code, e.Detail)
+	}
+	if e.cause != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.cause)
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Status returns the HTTP status code of the error
+func (e *Error) Status() int {
+	return e.Kind.Status()
+}
+
+// From returns the Error carried by err, or an internal error wrapping it
+func From(err error) *Error {
+	var p *Error
+	if errors.As(err, &p) {
+		return p
+	}
+	return Wrap(KindInternal, CodeInternal, "", err)
+}
+
+// Problem is the RFC 7807 response body
+type Problem struct {
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Status     int         `json:"status"`
+	Detail     string      `json:"detail,omitempty"`
+	Instance   string      `json:"instance,omitempty"`
+	Code       string      `json:"code"`
+	RequestID  string      `json:"requestId,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// TypeURI returns the problem type URI of an error code (e.g. urn:julia:problem:profile-not-found)
+func TypeURI(code string) string {
+	return typePrefix + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultMaxKeys = 100000
+
+// memoryBucket is an element of the LRU list
+type memoryBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// MemoryStore keeps buckets in process, so limits apply per instance.
+// When full, the least recently used bucket is dropped: that client simply starts again with a full bucket.
+type MemoryStore struct {
+	maxKeys int
+	buckets map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewMemoryStore creates a new MemoryStore tracking at most maxKeys buckets
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	if maxKeys <= 0 {
+		maxKeys = defaultMaxKeys
+	}
+	return &MemoryStore{
+		maxKeys: maxKeys,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var bucket *memoryBucket
+	if elem, ok := s.buckets[key]; ok {
+		s.lru.MoveToFront(elem)
+		bucket = elem.Value.(*memoryBucket)
+		bucket.tokens = refill(bucket.tokens, bucket.last, now, limit)
+	} else {
+		bucket = &memoryBucket{key: key, tokens: float64(limit.Burst)}
+		s.buckets[key] = s.lru.PushFront(bucket)
+		for s.lru.Len() > s.maxKeys {
+			oldest := s.lru.Back()
+			s.lru.Remove(oldest)
+			delete(s.buckets, oldest.Value.(*memoryBucket).key)
+		}
+	}
+	bucket.last = now
+
+	var result Result
+	bucket.tokens, result = take(bucket.tokens, limit)
+	return result, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,62 @@
+// Package ratelimit implements token bucket rate limiting with in-memory and Redis stores
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+)
+
+// Limit describes a token bucket: it holds up to Burst tokens and is refilled at Rate tokens per second.
+// Every request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed    bool
+	Remaining  int           // whole tokens left in the bucket
+	RetryAfter time.Duration // when not allowed, time until the next token is available
+}
+
+// Store keeps the buckets
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	Close() error
+}
+
+// New creates the store selected by configuration
+func New(cfg config.RateLimitConfig, redisCfg config.RedisConfig) (Store, error) {
+	switch cfg.Store {
+	case config.RateLimitStoreMemory:
+		return NewMemoryStore(cfg.MaxKeys), nil
+	case config.RateLimitStoreRedis:
+		return NewRedisStore(redisCfg, cfg.KeyPrefix), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store: %s", cfg.Store)
+	}
+}
+
+// refill returns the tokens in a bucket last updated at last, capped at the burst
+func refill(tokens float64, last, now time.Time, limit Limit) float64 {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(limit.Burst), tokens+elapsed*limit.Rate)
+}
+
+// take removes one token if available and returns the remaining tokens and the outcome
+func take(tokens float64, limit Limit) (float64, Result) {
+	if tokens >= 1 {
+		tokens--
+		return tokens, Result{Allowed: true, Remaining: int(tokens)}
+	}
+	wait := (1 - tokens) / limit.Rate
+	return tokens, Result{RetryAfter: time.Duration(math.Ceil(wait * float64(time.Second)))}
+}
@@ -0,0 +1,32 @@
+package reqctx
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewIDIsUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := NewID()
+		if seen[id] {
+			t.Fatalf("duplicate ID generated: %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestLoggerAddsIDs(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := WithCorrelationID(WithRequestID(context.Background(), "req-1"), "corr-1")
+
+	Logger(ctx, zap.New(core)).Info("hello")
+
+	fields := logs.All()[0].ContextMap()
+	if fields["requestID"] != "req-1" || fields["correlationID"] != "corr-1" {
+		t.Errorf("expected request and correlation IDs in log fields, got %v", fields)
+	}
+}
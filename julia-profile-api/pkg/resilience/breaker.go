@@ -3,20 +3,38 @@ package resilience
 import (
 	"time"
 
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/metrics"
 	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
 )
 
-// NewCircuitBreaker creates a configured circuit breaker
-func NewCircuitBreaker(name string) *gobreaker.CircuitBreaker {
+// NewCircuitBreaker creates a circuit breaker from the policy configuration.
+// State changes are logged and exported through the resilience_circuit_breaker_state gauge.
+func NewCircuitBreaker(name string, cfg config.ResiliencePolicyConfig, log *zap.Logger) *gobreaker.CircuitBreaker {
 	settings := gobreaker.Settings{
 		Name:        name,
-		MaxRequests: 3,
-		Interval:    5 * time.Second,
-		Timeout:     30 * time.Second,
+		MaxRequests: uint32(cfg.BreakerMaxRequests),
+		Interval:    time.Duration(cfg.BreakerInterval) * time.Second,
+		Timeout:     time.Duration(cfg.BreakerTimeout) * time.Second,
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
 			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
-			return counts.Requests >= 5 && failureRatio >= 0.6
+			return counts.Requests >= uint32(cfg.BreakerMinRequests) && failureRatio >= cfg.BreakerFailureRatio
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+			log.Warn("Circuit breaker state changed",
+				zap.String("name", name),
+				zap.String("from", from.String()),
+				zap.String("to", to.String()),
+			)
+		},
+		// Permanent errors (bad requests, cancelled callers) say nothing about the dependency health
+		IsSuccessful: func(err error) bool {
+			return err == nil || IsPermanent(err)
 		},
 	}
+
+	metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
 	return gobreaker.NewCircuitBreaker(settings)
 }
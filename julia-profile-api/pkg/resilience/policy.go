@@ -0,0 +1,215 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/comune-roma/bff-julia-profile-api/internal/metrics"
+	"github.com/comune-roma/bff-julia-profile-api/pkg/reqctx"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+// ErrBulkheadFull is returned when the dependency already has the maximum number of calls in flight
+var ErrBulkheadFull = errors.New("bulkhead full")
+
+// permanentError marks a failure that must not be retried nor counted against the circuit breaker
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the policy returns it immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var perm *permanentError
+	return errors.As(err, &perm)
+}
+
+// noRetryError marks a dependency failure that must not be retried, e.g. because the call may have had effect
+type noRetryError struct {
+	err error
+}
+
+func (e *noRetryError) Error() string { return e.err.Error() }
+func (e *noRetryError) Unwrap() error { return e.err }
+
+// NoRetry wraps err so that the policy returns it immediately. Unlike Permanent, the failure still
+// counts against the circuit breaker.
+func NoRetry(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &noRetryError{err: err}
+}
+
+// IsRejected reports whether err means the call was not attempted because the
+// circuit breaker is open or the bulkhead is full
+func IsRejected(err error) bool {
+	return errors.Is(err, ErrBulkheadFull) || errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+}
+
+// Policy applies bulkhead, retry with exponential backoff and jitter, per-attempt timeout
+// and circuit breaker to the calls made to one dependency
+type Policy struct {
+	name     string
+	cfg      config.ResiliencePolicyConfig
+	breaker  *gobreaker.CircuitBreaker
+	bulkhead chan struct{}
+	log      *zap.Logger
+}
+
+// NewPolicy creates a new Policy for the named dependency
+func NewPolicy(name string, cfg config.ResiliencePolicyConfig, log *zap.Logger) *Policy {
+	p := &Policy{
+		name:    name,
+		cfg:     cfg,
+		breaker: NewCircuitBreaker(name, cfg, log),
+		log:     log,
+	}
+	if cfg.MaxConcurrent > 0 {
+		p.bulkhead = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return p
+}
+
+// Name returns the dependency name
+func (p *Policy) Name() string {
+	return p.name
+}
+
+// State returns the current circuit breaker state
+func (p *Policy) State() gobreaker.State {
+	return p.breaker.State()
+}
+
+// Check reports an error while the circuit breaker is open, for use as a health check
+func (p *Policy) Check(ctx context.Context) error {
+	if state := p.breaker.State(); state == gobreaker.StateOpen {
+		return fmt.Errorf("circuit breaker %s is %s", p.name, state)
+	}
+	return nil
+}
+
+// Execute runs fn under the policy. fn receives a context bounded by the per-attempt timeout
+// and may return Permanent errors to stop retrying.
+func (p *Policy) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.bulkhead != nil {
+		select {
+		case p.bulkhead <- struct{}{}:
+			defer func() { <-p.bulkhead }()
+		default:
+			metrics.ResilienceRejections.WithLabelValues(p.name, "bulkhead").Inc()
+			return ErrBulkheadFull
+		}
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = p.attempt(ctx, fn)
+		if err == nil || attempt >= p.cfg.MaxAttempts || !p.retryable(ctx, err) {
+			break
+		}
+
+		metrics.ResilienceRetries.WithLabelValues(p.name).Inc()
+		reqctx.Logger(ctx, p.log).Debug("Retrying call",
+			zap.String("name", p.name),
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+		if !sleep(ctx, p.backoff(attempt)) {
+			break
+		}
+	}
+
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return perm.err
+	}
+	var noRetry *noRetryError
+	if errors.As(err, &noRetry) {
+		return noRetry.err
+	}
+	return err
+}
+
+// ExecuteWithFallback runs fn under the policy and calls fallback with the final error if it fails
+func (p *Policy) ExecuteWithFallback(ctx context.Context, fn func(ctx context.Context) error, fallback func(ctx context.Context, err error) error) error {
+	if err := p.Execute(ctx, fn); err != nil {
+		return fallback(ctx, err)
+	}
+	return nil
+}
+
+func (p *Policy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := p.breaker.Execute(func() (interface{}, error) {
+		attemptCtx := ctx
+		if p.cfg.AttemptTimeout > 0 {
+			var cancel context.CancelFunc
+			attemptCtx, cancel = context.WithTimeout(ctx, time.Duration(p.cfg.AttemptTimeout)*time.Millisecond)
+			defer cancel()
+		}
+
+		err := fn(attemptCtx)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up: not a dependency failure
+			return nil, Permanent(err)
+		}
+		return nil, err
+	})
+
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		metrics.ResilienceRejections.WithLabelValues(p.name, "circuit_open").Inc()
+	}
+	return err
+}
+
+func (p *Policy) retryable(ctx context.Context, err error) bool {
+	var noRetry *noRetryError
+	if ctx.Err() != nil || IsPermanent(err) || errors.As(err, &noRetry) {
+		return false
+	}
+	return !errors.Is(err, gobreaker.ErrOpenState) && !errors.Is(err, gobreaker.ErrTooManyRequests)
+}
+
+// backoff returns the delay before the next attempt: exponential, capped, with equal jitter
+func (p *Policy) backoff(attempt int) time.Duration {
+	delay := time.Duration(p.cfg.InitialBackoff) * time.Millisecond << (attempt - 1)
+	maxDelay := time.Duration(p.cfg.MaxBackoff) * time.Millisecond
+	if delay > maxDelay || delay <= 0 {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
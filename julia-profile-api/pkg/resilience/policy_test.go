@@ -0,0 +1,134 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/comune-roma/bff-julia-profile-api/internal/config"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+func testPolicyConfig() config.ResiliencePolicyConfig {
+	return config.ResiliencePolicyConfig{
+		MaxAttempts:         3,
+		InitialBackoff:      1,
+		MaxBackoff:          2,
+		BreakerMaxRequests:  1,
+		BreakerInterval:     60,
+		BreakerTimeout:      60,
+		BreakerMinRequests:  3,
+		BreakerFailureRatio: 0.5,
+	}
+}
+
+func TestPolicyRetriesUntilSuccess(t *testing.T) {
+	p := NewPolicy("test-retry", testPolicyConfig(), zap.NewNop())
+
+	calls := 0
+	err := p.Execute(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestPolicyDoesNotRetryPermanentErrors(t *testing.T) {
+	p := NewPolicy("test-permanent", testPolicyConfig(), zap.NewNop())
+	errBad := errors.New("bad request")
+
+	calls := 0
+	err := p.Execute(context.Background(), func(ctx context.Context) error {
+		calls++
+		return Permanent(errBad)
+	})
+	if err != errBad {
+		t.Errorf("expected the unwrapped error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+	if p.State() != gobreaker.StateClosed {
+		t.Errorf("permanent errors must not trip the breaker")
+	}
+}
+
+func TestPolicyDoesNotRetryNoRetryErrors(t *testing.T) {
+	cfg := testPolicyConfig()
+	p := NewPolicy("test-no-retry", cfg, zap.NewNop())
+	errDown := errors.New("down")
+
+	calls := 0
+	for i := 0; i < cfg.BreakerMinRequests; i++ {
+		err := p.Execute(context.Background(), func(ctx context.Context) error {
+			calls++
+			return NoRetry(errDown)
+		})
+		if err != errDown {
+			t.Errorf("expected the unwrapped error, got %v", err)
+		}
+	}
+	if calls != cfg.BreakerMinRequests {
+		t.Errorf("expected %d attempts, got %d", cfg.BreakerMinRequests, calls)
+	}
+	if p.State() != gobreaker.StateOpen {
+		t.Errorf("errors that are not retried must still trip the breaker, got %s", p.State())
+	}
+}
+
+func TestPolicyOpensBreaker(t *testing.T) {
+	cfg := testPolicyConfig()
+	cfg.MaxAttempts = 1
+	p := NewPolicy("test-breaker", cfg, zap.NewNop())
+
+	for i := 0; i < 3; i++ {
+		_ = p.Execute(context.Background(), func(ctx context.Context) error { return errors.New("down") })
+	}
+	if p.State() != gobreaker.StateOpen {
+		t.Fatalf("expected open breaker, got %s", p.State())
+	}
+
+	err := p.Execute(context.Background(), func(ctx context.Context) error {
+		t.Error("call must not reach the dependency while the breaker is open")
+		return nil
+	})
+	if !errors.Is(err, gobreaker.ErrOpenState) {
+		t.Errorf("expected ErrOpenState, got %v", err)
+	}
+}
+
+func TestPolicyBulkheadRejectsWhenFull(t *testing.T) {
+	cfg := testPolicyConfig()
+	cfg.MaxConcurrent = 1
+	p := NewPolicy("test-bulkhead", cfg, zap.NewNop())
+
+	err := p.Execute(context.Background(), func(ctx context.Context) error {
+		return p.Execute(ctx, func(ctx context.Context) error { return nil })
+	})
+	if !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("expected ErrBulkheadFull, got %v", err)
+	}
+}
+
+func TestPolicyFallback(t *testing.T) {
+	cfg := testPolicyConfig()
+	cfg.MaxAttempts = 1
+	p := NewPolicy("test-fallback", cfg, zap.NewNop())
+
+	err := p.ExecuteWithFallback(context.Background(),
+		func(ctx context.Context) error { return errors.New("down") },
+		func(ctx context.Context, err error) error { return nil },
+	)
+	if err != nil {
+		t.Errorf("expected fallback to recover, got %v", err)
+	}
+}
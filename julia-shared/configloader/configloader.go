@@ -0,0 +1,382 @@
+// Package configloader fills a configuration struct from layered sources.
+// Precedence, lowest first: the defaults held by the struct, the YAML file, secret providers and environment variables.
+package configloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// Sources of a configuration value, as reported by Source and Dump
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Key describes a configuration value of the target struct.
+// Struct tags drive the mapping:
+//   - mapstructure: key name in the file, defaults to the field name; "-" leaves the field to the defaults
+//   - env: comma-separated variable names, first set wins; on a struct or map field it prefixes its children.
+//     Without a tag the name is derived from the key, e.g. telemetry.sampleRatio -> TELEMETRY_SAMPLERATIO
+//   - secret: the value is redacted by Dump and may come from secret providers under SecretName, either the
+//     tag value or, with secret:"true", the first env name in lower case with dashes (COSMOS_DB_KEY -> cosmos-db-key)
+//   - reload:"true": the value may change at runtime through Reload
+//
+// secret and reload set on a struct or map field apply to all its children.
+type Key struct {
+	Path       string
+	Env        []string
+	Secret     bool
+	SecretName string
+	Reload     bool
+	value      interface{}
+}
+
+// Provider supplies values from an external store such as App Configuration or Key Vault
+type Provider interface {
+	Name() string
+	// Values returns the values the store holds for the given keys, indexed by Key.Path; unknown keys are omitted
+	Values(ctx context.Context, keys []Key) (map[string]string, error)
+}
+
+// Options configures a Loader
+type Options struct {
+	File      string   // explicit file, which must exist; when empty Name is looked up in Paths
+	Name      string   // file name without extension, e.g. "application"
+	Paths     []string // directories searched for Name, the file is optional
+	Providers []Provider
+	// RefreshInterval makes Watch reload the sources periodically, so that rotated secrets are picked up
+	RefreshInterval time.Duration
+}
+
+// Loader fills configuration structs from its sources and remembers where each value came from
+type Loader struct {
+	opts Options
+
+	mu       sync.RWMutex
+	v        *viper.Viper
+	keys     []Key
+	provided map[string]string // lower-cased key -> provider name
+}
+
+// New creates a new Loader
+func New(opts Options) *Loader {
+	return &Loader{opts: opts}
+}
+
+// Load fills cfg, a pointer to a struct holding the defaults, from the file, the providers and the environment
+func (l *Loader) Load(ctx context.Context, cfg interface{}) error {
+	v, keys, provided, err := l.load(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.v, l.keys, l.provided = v, keys, provided
+	l.mu.Unlock()
+	return nil
+}
+
+// Reload loads the sources again into next, a pointer to a struct holding the defaults like the one given to Load.
+// check runs on the fresh configuration, e.g. to resolve derived values and validate it. The change is rejected
+// when check fails or when a key not tagged reload:"true" differs from current; otherwise the loader adopts the
+// fresh sources and returns the keys whose value changed.
+func (l *Loader) Reload(ctx context.Context, current, next interface{}, check func(*Loader) error) ([]string, error) {
+	v, keys, provided, err := l.load(ctx, next)
+	if err != nil {
+		return nil, err
+	}
+	fresh := &Loader{opts: l.opts, v: v, keys: keys, provided: provided}
+	if err := check(fresh); err != nil {
+		return nil, err
+	}
+
+	before, err := Keys(current)
+	if err != nil {
+		return nil, err
+	}
+	after, err := Keys(next)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]Key, len(before))
+	for _, k := range before {
+		values[strings.ToLower(k.Path)] = k
+	}
+
+	var changed, restart []string
+	for _, k := range after {
+		old, ok := values[strings.ToLower(k.Path)]
+		if ok && reflect.DeepEqual(old.value, k.value) {
+			continue
+		}
+		if k.Reload {
+			changed = append(changed, k.Path)
+		} else {
+			restart = append(restart, k.Path)
+		}
+	}
+	if len(restart) > 0 {
+		return nil, fmt.Errorf("changes to %s require a restart", strings.Join(restart, ", "))
+	}
+
+	l.mu.Lock()
+	l.v, l.keys, l.provided = v, keys, provided
+	l.mu.Unlock()
+	return changed, nil
+}
+
+func (l *Loader) load(ctx context.Context, cfg interface{}) (*viper.Viper, []Key, map[string]string, error) {
+	keys, err := Keys(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	v := viper.New()
+	for _, k := range keys {
+		v.SetDefault(k.Path, k.value)
+		if len(k.Env) > 0 {
+			if err := v.BindEnv(append([]string{k.Path}, k.Env...)...); err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to bind %s: %w", k.Path, err)
+			}
+		}
+	}
+
+	if err := l.readFile(v); err != nil {
+		return nil, nil, nil, err
+	}
+
+	provided := make(map[string]string)
+	for _, p := range l.opts.Providers {
+		values, err := p.Values(ctx, keys)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load configuration from %s: %w", p.Name(), err)
+		}
+		nested := make(map[string]interface{})
+		for path, value := range values {
+			setNested(nested, strings.Split(strings.ToLower(path), "."), value)
+			provided[strings.ToLower(path)] = p.Name()
+		}
+		if err := v.MergeConfigMap(nested); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to merge configuration from %s: %w", p.Name(), err)
+		}
+	}
+
+	// Unknown keys in the file are reported rather than silently ignored
+	if err := v.UnmarshalExact(cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		stringToListHook,
+	))); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+	return v, keys, provided, nil
+}
+
+// readFile reads the configuration file; only an explicit file is required to exist
+func (l *Loader) readFile(v *viper.Viper) error {
+	if l.opts.File != "" {
+		v.SetConfigFile(l.opts.File)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", l.opts.File, err)
+		}
+		return nil
+	}
+	if l.opts.Name == "" {
+		return nil
+	}
+
+	v.SetConfigName(l.opts.Name)
+	v.SetConfigType("yaml")
+	for _, path := range l.opts.Paths {
+		v.AddConfigPath(path)
+	}
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	return nil
+}
+
+// File returns the configuration file in use, empty when none was found
+func (l *Loader) File() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.v == nil {
+		return ""
+	}
+	return l.v.ConfigFileUsed()
+}
+
+// Source reports where the value of a key came from: SourceEnv, a provider name, SourceFile or SourceDefault
+func (l *Loader) Source(path string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	lower := strings.ToLower(path)
+	for _, k := range l.keys {
+		if strings.ToLower(k.Path) != lower {
+			continue
+		}
+		for _, name := range k.Env {
+			if os.Getenv(name) != "" {
+				return SourceEnv
+			}
+		}
+	}
+	if name, ok := l.provided[lower]; ok {
+		return name
+	}
+	if l.v != nil && l.v.InConfig(lower) {
+		return SourceFile
+	}
+	return SourceDefault
+}
+
+// Dump returns one "key=value (source)" line per value of cfg, sorted by key, with secrets redacted
+func (l *Loader) Dump(cfg interface{}) []string {
+	keys, err := Keys(cfg)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%s (%s)", k.Path, formatValue(k), l.Source(k.Path)))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// formatValue renders a value for Dump, hiding secrets while still showing whether they are set
+func formatValue(k Key) string {
+	value := fmt.Sprint(k.value)
+	if s, ok := k.value.([]string); ok {
+		value = strings.Join(s, ",")
+	}
+	if k.Secret && value != "" {
+		return "******"
+	}
+	return value
+}
+
+// Keys lists the configuration values of cfg, a struct or a pointer to one, in field order
+func Keys(cfg interface{}) ([]Key, error) {
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("configuration must be a struct, got %s", v.Kind())
+	}
+	var keys []Key
+	walk(v, Key{}, "", &keys)
+	return keys, nil
+}
+
+// walk collects the leaves of a struct; parent carries the path, env prefix and inherited tags
+func walk(v reflect.Value, parent Key, envPrefix string, keys *[]Key) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("mapstructure")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		secret := field.Tag.Get("secret")
+		k := Key{
+			Path:   joinPath(parent.Path, name),
+			Secret: parent.Secret || secret != "",
+			Reload: parent.Reload || field.Tag.Get("reload") == "true",
+		}
+		if secret != "" && secret != "true" {
+			k.SecretName = secret
+		}
+		env := field.Tag.Get("env")
+		fv := v.Field(i)
+
+		switch {
+		case fv.Kind() == reflect.Struct:
+			walk(fv, k, joinEnv(envPrefix, env), keys)
+		case fv.Kind() == reflect.Map && fv.Type().Elem().Kind() == reflect.Struct:
+			mapKeys := fv.MapKeys()
+			sort.Slice(mapKeys, func(a, b int) bool { return mapKeys[a].String() < mapKeys[b].String() })
+			for _, mk := range mapKeys {
+				entry := k
+				entry.Path = joinPath(k.Path, mk.String())
+				walk(fv.MapIndex(mk), entry, joinEnv(joinEnv(envPrefix, env), strings.ToUpper(mk.String())), keys)
+			}
+		default:
+			switch {
+			case env == "-":
+			case env != "":
+				for _, name := range strings.Split(env, ",") {
+					k.Env = append(k.Env, joinEnv(envPrefix, strings.TrimSpace(name)))
+				}
+			default:
+				k.Env = []string{strings.ToUpper(strings.ReplaceAll(k.Path, ".", "_"))}
+			}
+			if k.Secret && k.SecretName == "" && len(k.Env) > 0 {
+				k.SecretName = strings.ToLower(strings.ReplaceAll(k.Env[0], "_", "-"))
+			}
+			k.value = fv.Interface()
+			*keys = append(*keys, k)
+		}
+	}
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func joinEnv(prefix, name string) string {
+	if prefix == "" || name == "" {
+		return prefix + name
+	}
+	return prefix + "_" + name
+}
+
+// setNested stores value at path in a nested map, as expected by viper.MergeConfigMap
+func setNested(m map[string]interface{}, path []string, value string) {
+	for _, part := range path[:len(path)-1] {
+		child, ok := m[part].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[part] = child
+		}
+		m = child
+	}
+	m[path[len(path)-1]] = value
+}
+
+// stringToListHook decodes comma-separated strings into string slices, trimming blanks and dropping empty items
+func stringToListHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf([]string{}) {
+		return data, nil
+	}
+	var items []string
+	for _, item := range strings.Split(data.(string), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,173 @@
+package configloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testGroup struct {
+	Rate  float64 `env:"RATE"`
+	Burst int     `env:"BURST"`
+}
+
+type testConfig struct {
+	Port     string               `env:"TEST_PORT"`
+	Password string               `env:"TEST_PASSWORD" secret:"true"`
+	Origins  []string             `env:"TEST_ORIGINS"`
+	Timeout  time.Duration        `mapstructure:"timeout"`
+	Level    string               `env:"TEST_LEVEL" reload:"true"`
+	Groups   map[string]testGroup `env:"TEST_LIMIT" reload:"true"`
+	Ignored  map[string]bool      `mapstructure:"-"`
+}
+
+func defaults() *testConfig {
+	return &testConfig{
+		Port:    "8080",
+		Timeout: time.Second,
+		Level:   "info",
+		Groups:  map[string]testGroup{"read": {Rate: 5, Burst: 30}},
+		Ignored: map[string]bool{"camelCase": true},
+	}
+}
+
+func writeFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "application.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "port: \"9000\"\ntimeout: 5s\npassword: from-file\ngroups:\n  read:\n    burst: 40\n")
+	t.Setenv("TEST_PASSWORD", "from-env")
+	t.Setenv("TEST_ORIGINS", " https://a.example , ,https://b.example")
+	t.Setenv("TEST_LIMIT_READ_RATE", "0.5")
+
+	l := New(Options{File: path})
+	cfg := defaults()
+	if err := l.Load(context.Background(), cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Port != "9000" || cfg.Timeout != 5*time.Second {
+		t.Errorf("file values not applied: port=%s timeout=%s", cfg.Port, cfg.Timeout)
+	}
+	if cfg.Password != "from-env" {
+		t.Errorf("env should override the file, got %q", cfg.Password)
+	}
+	if strings.Join(cfg.Origins, "|") != "https://a.example|https://b.example" {
+		t.Errorf("unexpected list decoding: %q", cfg.Origins)
+	}
+	if got := cfg.Groups["read"]; got.Rate != 0.5 || got.Burst != 40 {
+		t.Errorf("map entry should merge default, file and env values, got %+v", got)
+	}
+	if !cfg.Ignored["camelCase"] {
+		t.Error("fields tagged mapstructure:\"-\" must keep their defaults")
+	}
+
+	for path, want := range map[string]string{"port": SourceFile, "password": SourceEnv, "level": SourceDefault} {
+		if got := l.Source(path); got != want {
+			t.Errorf("Source(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownKeysAndBadValues(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "prot: \"9000\"\n")
+	if err := New(Options{File: path}).Load(context.Background(), defaults()); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+
+	t.Setenv("TEST_LIMIT_READ_BURST", "many")
+	if err := New(Options{}).Load(context.Background(), defaults()); err == nil {
+		t.Error("expected an error for a malformed number")
+	}
+}
+
+func TestOptionalFile(t *testing.T) {
+	l := New(Options{Name: "application", Paths: []string{t.TempDir()}})
+	if err := l.Load(context.Background(), defaults()); err != nil {
+		t.Fatalf("a missing optional file must not fail: %v", err)
+	}
+	if l.File() != "" {
+		t.Errorf("File() = %q, want empty", l.File())
+	}
+
+	if err := New(Options{File: filepath.Join(t.TempDir(), "missing.yaml")}).Load(context.Background(), defaults()); err == nil {
+		t.Error("a missing explicit file must fail")
+	}
+}
+
+type staticProvider map[string]string
+
+func (p staticProvider) Name() string { return "static" }
+
+func (p staticProvider) Values(context.Context, []Key) (map[string]string, error) { return p, nil }
+
+func TestProviderSitsBetweenFileAndEnv(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "password: from-file\nport: \"9000\"\n")
+	t.Setenv("TEST_PORT", "9100")
+
+	l := New(Options{File: path, Providers: []Provider{staticProvider{"password": "from-vault", "port": "9200"}}})
+	cfg := defaults()
+	if err := l.Load(context.Background(), cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Password != "from-vault" || l.Source("password") != "static" {
+		t.Errorf("provider should override the file, got %q from %s", cfg.Password, l.Source("password"))
+	}
+	if cfg.Port != "9100" {
+		t.Errorf("env should override the provider, got %q", cfg.Port)
+	}
+}
+
+func TestDumpRedactsSecrets(t *testing.T) {
+	t.Setenv("TEST_PASSWORD", "s3cret")
+	l := New(Options{})
+	cfg := defaults()
+	if err := l.Load(context.Background(), cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	dump := strings.Join(l.Dump(cfg), "\n")
+	if strings.Contains(dump, "s3cret") {
+		t.Errorf("secret leaked in dump:\n%s", dump)
+	}
+	for _, want := range []string{"Password=****** (env)", "Port=8080 (default)", "Groups.read.Rate=5 (default)"} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump misses %q:\n%s", want, dump)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "level: info\n")
+	l := New(Options{File: path})
+	current := defaults()
+	if err := l.Load(context.Background(), current); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	accept := func(*Loader) error { return nil }
+
+	writeFile(t, dir, "level: debug\ngroups:\n  read:\n    rate: 1\n")
+	next := defaults()
+	changed, err := l.Reload(context.Background(), current, next, accept)
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if strings.Join(changed, ",") != "Level,Groups.read.Rate" {
+		t.Errorf("changed = %v", changed)
+	}
+
+	writeFile(t, dir, "level: debug\nport: \"9000\"\n")
+	if _, err := l.Reload(context.Background(), next, defaults(), accept); err == nil || !strings.Contains(err.Error(), "Port") {
+		t.Errorf("expected the port change to require a restart, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package configloader
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Variables selecting the secret providers; they are read before the configuration itself
+const (
+	SecretsDirEnv             = "SECRETS_DIR"              // directory of mounted secrets, one file per secret (Kubernetes CSI style)
+	SecretsFileEnv            = "SECRETS_FILE"             // local encrypted file, see SealSecrets
+	SecretsKeyEnv             = "SECRETS_KEY"              // base64 AES-256 key of SECRETS_FILE
+	SecretsRefreshIntervalEnv = "SECRETS_REFRESH_INTERVAL" // e.g. 5m; 0 or empty disables the periodic refresh
+)
+
+// SecretProvidersFromEnv builds the secret providers selected by the SECRETS_* variables, lowest precedence first:
+// secret-named environment variables, the encrypted file, then the mounted directory.
+// It also returns the refresh interval to put in Options.RefreshInterval.
+func SecretProvidersFromEnv() ([]Provider, time.Duration, error) {
+	providers := []Provider{EnvSecrets{}}
+
+	if path := os.Getenv(SecretsFileEnv); path != "" {
+		key, err := base64.StdEncoding.DecodeString(os.Getenv(SecretsKeyEnv))
+		if err != nil || len(key) != 32 {
+			return nil, 0, fmt.Errorf("%s must be a base64 encoded 32 byte key when %s is set", SecretsKeyEnv, SecretsFileEnv)
+		}
+		providers = append(providers, EncryptedFileSecrets{Path: path, Key: key})
+	}
+	if dir := os.Getenv(SecretsDirEnv); dir != "" {
+		providers = append(providers, FileSecrets{Dir: dir})
+	}
+
+	var interval time.Duration
+	if value := os.Getenv(SecretsRefreshIntervalEnv); value != "" {
+		var err error
+		if interval, err = time.ParseDuration(value); err != nil || interval < 0 {
+			return nil, 0, fmt.Errorf("%s must be a non-negative duration such as 5m", SecretsRefreshIntervalEnv)
+		}
+	}
+	return providers, interval, nil
+}
+
+// secretKeys returns the keys tagged secret, which are the only ones secret providers may set
+func secretKeys(keys []Key) []Key {
+	var secrets []Key
+	for _, k := range keys {
+		if k.SecretName != "" {
+			secrets = append(secrets, k)
+		}
+	}
+	return secrets
+}
+
+// FileSecrets reads each secret from a file named after it in Dir, as mounted by the Secrets Store CSI driver
+// or by a Kubernetes Secret volume. Missing files are skipped; trailing newlines are trimmed.
+type FileSecrets struct {
+	Dir string
+}
+
+// Name implements Provider
+func (p FileSecrets) Name() string {
+	return "secrets-dir"
+}
+
+// Values implements Provider
+func (p FileSecrets) Values(_ context.Context, keys []Key) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, k := range secretKeys(keys) {
+		data, err := os.ReadFile(filepath.Join(p.Dir, k.SecretName))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", k.SecretName, err)
+		}
+		values[k.Path] = strings.TrimRight(string(data), "\r\n")
+	}
+	return values, nil
+}
+
+// EnvSecrets reads each secret from the environment variable named after it, e.g. cosmos-db-key from COSMOS_DB_KEY,
+// as injected by platforms that expose secret references as variables
+type EnvSecrets struct{}
+
+// Name implements Provider
+func (p EnvSecrets) Name() string {
+	return "secrets-env"
+}
+
+// Values implements Provider
+func (p EnvSecrets) Values(_ context.Context, keys []Key) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, k := range secretKeys(keys) {
+		if value := os.Getenv(secretEnvName(k.SecretName)); value != "" {
+			values[k.Path] = value
+		}
+	}
+	return values, nil
+}
+
+func secretEnvName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// EncryptedFileSecrets reads secrets from a local file written by SealSecrets, so that developers can keep
+// real credentials on disk without storing them in clear text
+type EncryptedFileSecrets struct {
+	Path string
+	Key  []byte // AES-256 key
+}
+
+// Name implements Provider
+func (p EncryptedFileSecrets) Name() string {
+	return "secrets-file"
+}
+
+// Values implements Provider
+func (p EncryptedFileSecrets) Values(_ context.Context, keys []Key) (map[string]string, error) {
+	secrets, err := OpenSecretsFile(p.Path, p.Key)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, k := range secretKeys(keys) {
+		if value, ok := secrets[k.SecretName]; ok {
+			values[k.Path] = value
+		}
+	}
+	return values, nil
+}
+
+// SealSecrets encrypts secrets by name with AES-256-GCM; the result is base64 text holding the nonce and ciphertext
+func SealSecrets(key []byte, secrets map[string]string) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode secrets: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return []byte(base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// OpenSecretsFile decrypts a file written by SealSecrets
+func OpenSecretsFile(path string, key []byte) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode secrets file: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("secrets file is truncated")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file, wrong key or corrupted file: %w", err)
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,117 @@
+package configloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type secretConfig struct {
+	DBKey  string `env:"TEST_DB_KEY" secret:"true"`
+	HubKey string `env:"TEST_HUB_CONNECTION" secret:"hub-connection-string"`
+	Plain  string `env:"TEST_PLAIN"`
+}
+
+func TestSecretNames(t *testing.T) {
+	keys, err := Keys(secretConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"DBKey": "test-db-key", "HubKey": "hub-connection-string", "Plain": ""}
+	for _, k := range keys {
+		if k.SecretName != want[k.Path] {
+			t.Errorf("%s: secret name %q, want %q", k.Path, k.SecretName, want[k.Path])
+		}
+	}
+}
+
+func TestFileSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test-db-key"), []byte("mounted\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// A file named after a non-secret key must be ignored
+	if err := os.WriteFile(filepath.Join(dir, "test-plain"), []byte("nope"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &secretConfig{}
+	if err := New(Options{Providers: []Provider{FileSecrets{Dir: dir}}}).Load(context.Background(), cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DBKey != "mounted" || cfg.Plain != "" || cfg.HubKey != "" {
+		t.Errorf("unexpected values: %+v", cfg)
+	}
+}
+
+func TestEncryptedFileSecrets(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	sealed, err := SealSecrets(key, map[string]string{"hub-connection-string": "Endpoint=sb://x;SharedAccessKey=k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("SharedAccessKey")) {
+		t.Fatal("sealed file contains the secret in clear text")
+	}
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	if err := os.WriteFile(path, sealed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(SecretsFileEnv, path)
+	t.Setenv(SecretsKeyEnv, base64.StdEncoding.EncodeToString(key))
+	t.Setenv(SecretsRefreshIntervalEnv, "5m")
+	providers, interval, err := SecretProvidersFromEnv()
+	if err != nil {
+		t.Fatalf("SecretProvidersFromEnv: %v", err)
+	}
+	if interval.Minutes() != 5 {
+		t.Errorf("interval = %s", interval)
+	}
+
+	l := New(Options{Providers: providers})
+	cfg := &secretConfig{}
+	if err := l.Load(context.Background(), cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HubKey != "Endpoint=sb://x;SharedAccessKey=k" || l.Source("HubKey") != "secrets-file" {
+		t.Errorf("got %q from %s", cfg.HubKey, l.Source("HubKey"))
+	}
+
+	if _, err := OpenSecretsFile(path, bytes.Repeat([]byte{8}, 32)); err == nil {
+		t.Error("expected an error with the wrong key")
+	}
+}
+
+func TestEnvSecretsBelowFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hub-connection-string"), []byte("from-dir"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HUB_CONNECTION_STRING", "from-secret-env")
+	t.Setenv("TEST_DB_KEY", "") // the plain env layer stays unset
+	t.Setenv(SecretsDirEnv, dir)
+
+	providers, _, err := SecretProvidersFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &secretConfig{}
+	if err := New(Options{Providers: providers}).Load(context.Background(), cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HubKey != "from-dir" {
+		t.Errorf("mounted secrets should override secret-named variables, got %q", cfg.HubKey)
+	}
+}
+
+func TestSecretProvidersFromEnvRejectsBadKey(t *testing.T) {
+	t.Setenv(SecretsFileEnv, "secrets.enc")
+	t.Setenv(SecretsKeyEnv, "short")
+	if _, _, err := SecretProvidersFromEnv(); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+}
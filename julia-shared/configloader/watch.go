@@ -0,0 +1,88 @@
+package configloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce groups the bursts of events editors and Kubernetes volume updates produce for one change
+const watchDebounce = 500 * time.Millisecond
+
+// Watch calls onChange after the configuration file changes and, when Options.RefreshInterval is set, at every
+// interval so that providers are read again; calls never overlap. It stops when ctx is done.
+// The directory is watched rather than the file so that atomic replacements (rename, ConfigMap symlink swaps)
+// are seen. It does nothing when there is neither a file nor a refresh interval.
+func (l *Loader) Watch(ctx context.Context, onChange func()) error {
+	var (
+		events <-chan fsnotify.Event
+		errs   <-chan error
+		file   string
+	)
+	if used := l.File(); used != "" {
+		var err error
+		if file, err = filepath.Abs(used); err != nil {
+			return fmt.Errorf("failed to resolve config file path: %w", err)
+		}
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create config file watcher: %w", err)
+		}
+		dir := filepath.Dir(file)
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		go func() {
+			<-ctx.Done()
+			watcher.Close()
+		}()
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	var refresh <-chan time.Time
+	if l.opts.RefreshInterval > 0 {
+		ticker := time.NewTicker(l.opts.RefreshInterval)
+		go func() {
+			<-ctx.Done()
+			ticker.Stop()
+		}()
+		refresh = ticker.C
+	}
+
+	if events == nil && refresh == nil {
+		return nil
+	}
+
+	go func() {
+		var pending <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				// ..data is the symlink Kubernetes swaps when a mounted ConfigMap or Secret changes
+				if filepath.Clean(event.Name) == file || filepath.Base(event.Name) == "..data" {
+					pending = time.After(watchDebounce)
+				}
+			case <-pending:
+				pending = nil
+				onChange()
+			case <-refresh:
+				onChange()
+			case _, ok := <-errs:
+				if !ok {
+					errs = nil
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,39 @@
+// Package pushtags defines the Notification Hub tags put on app installations. The profile API writes them when
+// an installation is registered and the notification worker targets them, so both use this package.
+package pushtags
+
+import (
+	"sort"
+	"strings"
+)
+
+// Prefixes of the installation tags
+const (
+	TopicPrefix    = "topic_"
+	LanguagePrefix = "lang_"
+)
+
+// Topic returns the tag of the installations subscribed to a notification topic
+func Topic(id string) string {
+	return TopicPrefix + id
+}
+
+// Language returns the tag of the installations using a language. Only the primary language subtag is kept,
+// lower case, so that "it-IT" and "it" give the same tag.
+func Language(code string) string {
+	primary, _, _ := strings.Cut(code, "-")
+	return LanguagePrefix + strings.ToLower(primary)
+}
+
+// InstallationTags returns the tags of an installation using language and subscribed to topics, sorted
+func InstallationTags(language string, topics []string) []string {
+	tags := make([]string, 0, len(topics)+1)
+	if language != "" {
+		tags = append(tags, Language(language))
+	}
+	for _, topic := range topics {
+		tags = append(tags, Topic(topic))
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,25 @@
+package pushtags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	for code, want := range map[string]string{"it": "lang_it", "it-IT": "lang_it", "EN": "lang_en", "zh-Hant-TW": "lang_zh"} {
+		if got := Language(code); got != want {
+			t.Errorf("Language(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestInstallationTags(t *testing.T) {
+	got := InstallationTags("it-IT", []string{"tari", "rifiuti"})
+	want := []string{"lang_it", "topic_rifiuti", "topic_tari"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InstallationTags = %v, want %v", got, want)
+	}
+	if got := InstallationTags("", nil); len(got) != 0 {
+		t.Errorf("expected no tags, got %v", got)
+	}
+}